@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// originLocal is the origin of the mutations made on this node.
+const originLocal = "local"
+
+// AuditEntry is a mutation recorded by the audit log, see Config.AuditLogSize.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Group string    `json:"group"`
+	Key   string    `json:"key"`
+	Op    string    `json:"op"`
+	// "local" for the mutations made on this node, including expiry and eviction,
+	// or the address of the peer that pushed the set or the delete
+	Origin string `json:"origin"`
+}
+
+// auditLog keeps the last mutations of every group in a ring buffer.
+type auditLog struct {
+	mtx     sync.Mutex
+	entries []AuditEntry
+	// 다음에 쓸 위치, 한 바퀴 돈 뒤에는 가장 오래된 entry
+	next int
+	full bool
+}
+
+func newAuditLog(size int) *auditLog {
+	return &auditLog{entries: make([]AuditEntry, size)}
+}
+
+func (l *auditLog) record(group, key string, op Op, origin string) {
+	e := AuditEntry{Time: time.Now(), Group: group, Key: key, Op: op.String(), Origin: origin}
+	if e.Origin == "" {
+		e.Origin = originLocal
+	}
+	l.mtx.Lock()
+	l.entries[l.next] = e
+	l.next++
+	if l.next == len(l.entries) {
+		l.next, l.full = 0, true
+	}
+	l.mtx.Unlock()
+}
+
+// recent returns the recorded mutations, newest first.
+func (l *auditLog) recent() []AuditEntry {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	entries := make([]AuditEntry, 0, n)
+	for i := range n {
+		entries = append(entries, l.entries[(l.next-1-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+func (c *cache) AuditLog() []AuditEntry {
+	if c.audit == nil {
+		return nil
+	}
+	return c.audit.recent()
+}
+
+// peerOrigin returns the origin of the mutations pushed by the peer sending r.
+func peerOrigin(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditHandler serves the audit log, newest first, filtered by the group and
+// key query parameters when given.
+func (c *cache) auditHandler(w http.ResponseWriter, r *http.Request) {
+	group, key := r.URL.Query().Get("group"), r.URL.Query().Get("key")
+	entries := make([]AuditEntry, 0)
+	for _, e := range c.AuditLog() {
+		if (group == "" || e.Group == group) && (key == "" || e.Key == key) {
+			entries = append(entries, e)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
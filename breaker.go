@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling the getter while the group's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a group's circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *BreakerState) UnmarshalText(text []byte) error {
+	for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		if state.String() == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown breaker state %q", text)
+}
+
+// WithCircuitBreaker opens the group's circuit after threshold consecutive getter
+// failures within window. While open, misses fail fast with ErrCircuitOpen instead
+// of calling the getter. After cooldown a single getter call is let through
+// (half-open); its success closes the circuit and its failure opens it again.
+// With WithStaleIfError, misses of recently expired keys return the expired value
+// while the circuit is open.
+func WithCircuitBreaker(threshold int, window, cooldown time.Duration) GroupOption {
+	return func(g *group) {
+		g.breaker = &breaker{
+			threshold: threshold,
+			window:    window,
+			cooldown:  cooldown,
+		}
+	}
+}
+
+type breaker struct {
+	mtx       sync.Mutex
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	state        BreakerState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	// half-open 상태에서 확인용 getter 호출이 진행 중
+	probing bool
+}
+
+// allow reports whether a getter call may be made now.
+func (b *breaker) allow(now time.Time) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the result of a getter call.
+func (b *breaker) record(err error, now time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.probing = false
+	// 호출자가 취소한 경우는 성공도 실패도 아니므로 상태를 유지한다
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+		b.openedAt = now
+		return
+	}
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+		b.failures = 0
+	}
+}
+
+func (b *breaker) State() BreakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithSizer sets the function measuring the bytes of an entry, reported as
+// Stats.Bytes and counted against Config.MaxTotalBytes. It is called without
+// the group locked each time an entry is stored, before the entry is stored,
+// except for Update, which stores the entry first. Groups of a cache with
+// MaxTotalBytes are measured by defaultSizer when it is not set.
+func WithSizer(fn func(key string, val any) int64) GroupOption {
+	return func(g *group) {
+		g.sizer = fn
+	}
+}
+
+// defaultSizer measures strings and byte slices by their length, and other
+// values by the length of their encoding with codec.
+func defaultSizer(codec Codec) func(key string, val any) int64 {
+	return func(key string, val any) int64 {
+		switch v := val.(type) {
+		case nil:
+			return int64(len(key))
+		case string:
+			return int64(len(key) + len(v))
+		case []byte:
+			return int64(len(key) + len(v))
+		}
+		dat, _ := codec.Marshal(val)
+		return int64(len(key) + len(dat))
+	}
+}
+
+// sizeOf measures val with the sizer of the group, 0 without one. Call it
+// without g.mtx held: defaultSizer encodes the value.
+func (g *group) sizeOf(key string, val any) int64 {
+	if g.sizer == nil {
+		return 0
+	}
+	return g.sizer(key, val)
+}
+
+// resize sets the size of the entry of key stored with generation, which
+// Update stores before measuring it. Must be called without g.mtx held.
+func (g *group) resize(key string, generation uint64, val any) {
+	if g.sizer == nil {
+		return
+	}
+	size := g.sizeOf(key, val)
+	g.mtx.Lock()
+	if cur, ok := g.data[key]; ok && cur.generation == generation {
+		g.addBytes(size - cur.size)
+		cur.size = size
+		g.data[key] = cur
+	}
+	g.mtx.Unlock()
+}
+
+// byteBudget is the Config.MaxTotalBytes shared by the groups of a cache.
+type byteBudget struct {
+	max  int64
+	used atomic.Int64
+	// group 목록, c.mtx 를 잡는다
+	groups func() []*group
+	// 여러 group 이 동시에 evict 하지 않도록
+	mtx sync.Mutex
+}
+
+// addBytes adds n to the bytes of the group and of the cache. Must be called with g.mtx held.
+func (g *group) addBytes(n int64) {
+	g.bytes += n
+	if g.budget != nil {
+		g.budget.used.Add(n)
+	}
+}
+
+// enforceBudget evicts entries until the cache fits Config.MaxTotalBytes, from
+// the group holding the most bytes first, each group choosing its entries with
+// its eviction policy. Must be called without g.mtx held.
+func (g *group) enforceBudget() {
+	b := g.budget
+	if b == nil || b.used.Load() <= b.max {
+		return
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	groups := b.groups()
+	for b.used.Load() > b.max {
+		var largest *group
+		var most int64
+		for _, g := range groups {
+			g.mtx.RLock()
+			bytes := g.bytes
+			g.mtx.RUnlock()
+			if bytes > most {
+				largest, most = g, bytes
+			}
+		}
+		if largest == nil {
+			return
+		}
+		evicted := largest.evictBytes(b.used.Load() - b.max)
+		if len(evicted) == 0 {
+			return
+		}
+		largest.notifyEvicted(evicted)
+	}
+}
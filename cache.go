@@ -1,36 +1,156 @@
 package cache
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	crand "crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	defttl                              = time.Hour
-	defaultCacheClearInterval           = time.Duration(0) // infinite
+	defaultCacheClearInterval           = time.Duration(0) // adaptive, see nextCleanupInterval
+	defaultAdaptiveCleanupInterval      = time.Minute
+	minAdaptiveCleanupInterval          = time.Second
+	maxAdaptiveCleanupInterval          = 10 * time.Minute
 	defaultHeadlessServiceWatchInterval = time.Second
+	defaultChangeEventBufferSize        = 1024
+	defaultSetEventBufferSize           = 1024
+	defaultPeerResolveTimeout           = 3 * time.Second
+	defaultDeleteQueueSize              = 1024
+	defaultDeleteWorkers                = 4
+	defaultMaxRequestBytes              = 10 << 20 // 10MiB
+	defaultPeerRequestTimeout           = 2 * time.Second
+	defaultMaxIdleConnsPerHost          = 4
+	defaultMaxConcurrentPeerRequests    = 16
+	defaultPeerFetchStagger             = 50 * time.Millisecond
+	defaultCompactRatio                 = 0.25
+)
+
+var (
+	// ErrTooManyGroups is returned by CreateGroup once Config.MaxGroups groups exist.
+	ErrTooManyGroups = errors.New("too many groups")
+	// ErrReservedGroupName is returned by CreateGroup for an empty name or a name
+	// starting with "_", which is reserved for the internal http endpoints (/_cache/...).
+	ErrReservedGroupName = errors.New("reserved group name")
 )
 
 type deleteEvent struct {
 	group string
 	key   string
+	// DelBatch 로 함께 삭제된 key 목록
+	keys []string
+}
+
+// setEntry is an entry pushed to peers by set propagation.
+type setEntry struct {
+	Key   string        `json:"key"`
+	Value any           `json:"value"`
+	TTL   time.Duration `json:"ttl"`
+	// 0 이면 local 에서 새로 발급
+	Generation uint64 `json:"generation"`
+	Priority   int    `json:"priority,omitempty"`
+	// SetWithDependencies 로 설정된 key 목록
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// 값을 설정한 node 의 group schema version
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+	// Value 가 MarshalBinary 의 결과, WithBinaryValues 참고
+	Binary bool `json:"binary,omitempty"`
+
+	// getter 호출을 시작한 시각, local fill 에만 설정
+	started time.Time
+	fill    *fillSink
+	// set 을 보낸 peer, audit log 에 기록
+	origin string
+	// peer fetch 에서 peer 가 값을 처음 cache 한 시각, PeerConflictPolicy 에 사용
+	createdAt time.Time
+	// sizer 로 측정한 byte 수, setBatch 가 lock 을 잡기 전에 측정
+	size int64
+}
+
+type setEvent struct {
+	group   string
+	entries []setEntry
+}
+
+// Op is the kind of local cache mutation reported to OnChange subscribers.
+type Op int
+
+const (
+	OpSet Op = iota
+	OpDelete
+	OpExpire
+	// removed to make room when the group reached its maximum number of entries
+	OpEvict
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpExpire:
+		return "expire"
+	case OpEvict:
+		return "evict"
+	}
+	return fmt.Sprintf("Op(%d)", int(o))
+}
+
+type changeEvent struct {
+	group string
+	key   string
+	op    Op
+	val   any
 }
 
 type cache struct {
 	// Peer 목록
 
 	addr string
+	// peer 요청의 origin 으로 사용하는 node 식별자
+	nodeID string
+
+	// reverse proxy 하위 경로에 mount 될 때 사용하는 prefix (e.g. /cache)
+	pathPrefix string
 
 	peerAddresses []string
 
 	// Headless Service
-	headlessServiceName string
-	headlessServicePort int
+	headlessServiceNames []string
+	headlessServicePort  int
+	// service 별 마지막 조회 결과
+	servicePeers map[string][]string
+	// 조회한 peer 주소를 변환, nil 이면 그대로 사용
+	peerAddressMapper func(peers []string) []string
+	// 마지막 조회 시각과 오류, c.mtx 로 보호
+	resolvedAt time.Time
+	resolveErr error
+	// Config.SelfAddr, 없으면 조회 결과 중 local ip 의 주소 (c.mtx 로 보호)
+	configSelf   string
+	resolvedSelf string
+	// test 에서 교체, nil 이면 net.DefaultResolver.LookupHost 와 getLocalIPs
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	localIPs   func() map[string]struct{}
 
 	// 동기화
 	mtx sync.RWMutex
@@ -42,16 +162,119 @@ type cache struct {
 
 	// ttl 이 지난 cache 삭제 주기
 	ttlCleanupInterval time.Duration
+	// 삭제 주기가 설정되지 않으면 삭제 비율에 따라 주기를 조절
+	adaptiveCleanup bool
+	// 0 이면 주기적으로 compact 하지 않음
+	compactInterval time.Duration
+	// WithAutoCompact 가 없는 group 의 compact 기준
+	compactRatio float64
 
 	// headless service 목록에서 peer 변경 감지를 확인하는 주기
 	headlessServiceWatchInterval time.Duration
+	// PauseDiscovery 중에는 peer 목록을 갱신하지 않음
+	discoveryPaused atomic.Bool
+	// SetMaintenanceMode 중에는 모든 group 이 getter 를 호출하지 않음
+	maintenance atomic.Bool
+	// readiness 에 보고하는 http server 와 Close 상태
+	listening atomic.Bool
+	draining  atomic.Bool
 
 	// group data
 	group map[string]*group
 
-	httpServ *http.Server
+	// group 에 codec 이 지정되지 않으면 사용
+	codec        Codec
+	legacyCodecs []Codec
+
+	httpServ  *http.Server
+	tlsConfig *tls.Config
+	// http server 와 peer 연결에 사용, "tcp" 또는 "unix"
+	network string
+	// peerAddresses 로 만든 consistent hash ring, 변경 시 다시 생성
+	ring *hashRing
+	// 마지막 membership 변경 전의 ring 과 변경 시각, MembershipSettleSec 동안 사용
+	prevRing         *hashRing
+	ringChangedAt    time.Time
+	membershipSettle time.Duration
+	// PeerTransport 사용 시 listen 대신 등록, Close 에서 해제
+	unregister func()
+
+	// peer 요청에 공유하는 client 와 동시 요청 수 제한
+	peerClient *http.Client
+	peerSem    chan struct{}
+	// peer 별 마지막 요청 결과
+	healthMtx  sync.Mutex
+	peerHealth map[string]PeerHealth
+	// http handler 동시 실행 수 제한, nil 이면 제한 없음
+	handlerSem chan struct{}
+	// background 작업의 오류 전달
+	onError func(err error)
+	logger  *slog.Logger
 
-	deleteChan chan deleteEvent
+	// 0 이면 제한 없음
+	maxGroups int
+	// MaxTotalBytes 가 없으면 nil
+	budget *byteBudget
+
+	// GET /metrics 제공 여부
+	metrics bool
+	// 새로 발견된 peer 에게 미리 연결
+	warmUpPeers bool
+	// Close 에서 peer 에게 entry 를 넘기는 최대 시간, 0 이면 넘기지 않음
+	handoffTimeout time.Duration
+	// 0 이면 모든 peer 에게 delete 전파
+	deleteReplicas int
+	// hash ring 에서 node 별 가중치, nil 이면 모두 1
+	peerWeight func(addr string) int
+	// nil 이면 deleteReplicas 와 hash ring 순서를 사용
+	peerSelector PeerSelector
+	// 관리용 endpoint 인증 token
+	adminToken string
+	// false 를 반환한 key 는 peer 의 delete 를 거부
+	deleteFilter func(group, key string) bool
+	// peer 로 보내는 모든 요청에 추가
+	peerRequestHeaders map[string]string
+	// write handler 의 request body 최대 크기
+	maxRequestBytes int64
+
+	// GET /{groupName}/{key} miss 응답, 0 이면 404
+	missStatusCode int
+	missHandler    func(w http.ResponseWriter, r *http.Request, reason MissReason)
+	errorBody      func(status int, message string, reason MissReason) any
+
+	deleteChan  chan deleteEvent
+	dropDeletes bool
+	readOnly    bool
+	peerFetch   bool
+	// 첫 peer 가 응답하지 않을 때 나머지 peer 에게 요청하기까지의 대기 시간
+	peerFetchStagger time.Duration
+	// Config.PeerConflictPolicy
+	peerConflictPolicy PeerConflictPolicy
+	// Config.MaxClockSkewMs
+	maxClockSkew time.Duration
+	// Config.AuditLogSize 가 0 이면 nil
+	audit *auditLog
+	// PropagateSets 가 설정된 경우에만 생성
+	setChan chan setEvent
+
+	// local 변경 이벤트 구독
+	changeChan  chan changeEvent
+	changeFuncs []func(group, key string, op Op, val any)
+
+	// Close 시작 시 역순으로 실행
+	closeHooks []func()
+
+	// spawn 으로 시작한 goroutine, Close 가 기다리기 시작하면 closing
+	spawnMtx   sync.Mutex
+	closing    bool
+	goroutines atomic.Int64
+
+	// Config.SnapshotFile, snapshot 은 group 이 생성될 때까지 보관하는 entry
+	snapshotFile string
+	snapshot     map[string][]snapshotEntry
+
+	// Config.EncryptionKey 로 peer 전송과 snapshot file 을 암호화, 없으면 nil
+	encryption *encryption
 }
 
 type Getter interface {
@@ -64,10 +287,99 @@ func (f GetterFunc) Get(ctx context.Context, key string, dest Sink) error {
 	return f(ctx, key, dest)
 }
 
+// getterChain tries its getters in order until one of them sets the key.
+type getterChain []Getter
+
+func (chain getterChain) Get(ctx context.Context, key string, dest Sink) error {
+	sink, _ := dest.(*fillSink)
+	var errs []error
+	for _, getter := range chain {
+		err := getter.Get(ctx, key, dest)
+		if err == nil && (sink == nil || sink.filled) {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
 type Cache interface {
-	NewGroup(name string, getter Getter) Group
-	NewGroupWithTTL(name string, getter Getter, ttl time.Duration) Group
+	// NewGroup creates a group filled by getter on a miss.
+	// A nil getter makes a cache-only group whose misses return ErrNotFound.
+	NewGroup(name string, getter Getter, opts ...GroupOption) Group
+	NewGroupWithTTL(name string, getter Getter, ttl time.Duration, opts ...GroupOption) Group
+	// NewGroupWithGetters creates a group that, on a miss, tries getters in order
+	// until one of them sets the key without an error, e.g. a fast replica before
+	// the authoritative source. The chain runs as a single getter call, once for
+	// the concurrent misses of a key on this node like any getter, and Get
+	// returns the errors of every getter joined when none of them sets the key.
+	NewGroupWithGetters(name string, getters ...Getter) Group
+	// CreateGroup is NewGroupWithTTL that returns an error instead of creating
+	// the group: ErrTooManyGroups once Config.MaxGroups is reached, and
+	// ErrReservedGroupName for a name that would collide with the http routes.
+	// NewGroup and NewGroupWithTTL log the error and return nil in those cases.
+	CreateGroup(name string, getter Getter, ttl time.Duration, opts ...GroupOption) (Group, error)
+	// GroupCount returns the number of groups.
+	GroupCount() int
+	// Owner returns the node that owns key of group on the consistent hash ring
+	// of this node and its peers, and whether it is this node.
+	// A single node cache owns every key.
+	Owner(group, key string) (addr string, isLocal bool)
+	// NumGoroutines returns the number of running background goroutines of the
+	// cache, 0 once Close has returned.
+	NumGoroutines() int
+	// AuditLog returns the last mutations of every group, newest first, with
+	// Config.AuditLogSize. It returns nil otherwise.
+	AuditLog() []AuditEntry
+	// Ring returns the hash ring of this node and its peers: each node with its
+	// weight, its number of points on the ring and the ranges of key hashes it owns.
+	Ring() RingState
+	// GetGroup returns the named group, or a nil interface when it does not exist.
+	// Prefer GetGroupOK, which reports existence explicitly:
+	//
+	//	if g, ok := c.GetGroupOK("name"); ok {
+	//		g.Get(ctx, key)
+	//	}
 	GetGroup(name string) Group
+	GetGroupOK(name string) (Group, bool)
+	// PauseDiscovery freezes the peers resolved from the headless services, e.g.
+	// during maintenance or a partition test, until ResumeDiscovery is called.
+	// The watcher keeps running but skips its lookups. It has no effect on static PeerAddresses.
+	PauseDiscovery()
+	ResumeDiscovery()
+	// SetMaintenanceMode stops every group from calling its getter while enabled,
+	// e.g. during a backend outage: misses that the store and the peers cannot
+	// serve return ErrNotFound instead, and are counted in Stats.MaintenanceMisses.
+	// Unlike Config.ReadOnly it can be toggled at runtime.
+	SetMaintenanceMode(enabled bool)
+	MaintenanceMode() bool
+	// GetGroupOrCreate returns the named group, creating it when it does not exist.
+	// Concurrent callers all receive the same group; getter and ttl are ignored
+	// when it already exists. It returns nil when the group is refused, see CreateGroup.
+	GetGroupOrCreate(name string, getter Getter, ttl time.Duration) Group
+	// OnChange registers fn to be called on every local Set, Delete, Expire and Evict.
+	// Callbacks run on a separate goroutine outside of any lock; events are
+	// buffered and dropped when a slow subscriber lets the buffer fill up.
+	OnChange(fn func(group, key string, op Op, val any))
+	// RegisterCloseHook registers fn to run at the start of Close, before the
+	// handoff, the SnapshotFile and the background goroutines are stopped, so
+	// that the values a hook writes are still handed off and snapshotted. Hooks
+	// run in LIFO order.
+	RegisterCloseHook(fn func())
+	// PeerHealth returns the health of the current peers as observed from the
+	// requests this node sent them. Peers that were never contacted are healthy.
+	PeerHealth() []PeerHealth
+	// Cleanup removes the expired entries of every group immediately and
+	// returns the number of removed entries.
+	Cleanup() int
+	// ResetStats calls Group.ResetStats on every group and returns the stats
+	// keyed by group name.
+	ResetStats() map[string]Stats
 	Close()
 }
 
@@ -75,6 +387,16 @@ func NewCache(config *Config) Cache {
 	cache := new(cache)
 	cache.group = make(map[string]*group)
 	cache.ctx, cache.cancel = context.WithCancel(context.Background())
+	cache.nodeID = newNodeID()
+	cache.onError = config.OnError
+	cache.maxGroups = config.MaxGroups
+	if config.MaxTotalBytes > 0 {
+		cache.budget = &byteBudget{max: config.MaxTotalBytes, groups: cache.groups}
+	}
+	cache.logger = config.Logger
+	if cache.logger == nil {
+		cache.logger = slog.Default()
+	}
 
 	if config.CacheCleanupIntervalSec <= 0 {
 		cache.ttlCleanupInterval = defaultCacheClearInterval
@@ -88,7 +410,92 @@ func NewCache(config *Config) Cache {
 		cache.headlessServiceWatchInterval = time.Duration(config.HeadlessServiceWatchIntervalSec) * time.Second
 	}
 
-	cache.headlessServiceName = config.HeadlessServiceName
+	cache.configSelf = config.SelfAddr
+	cache.headlessServiceNames = slices.Clone(config.HeadlessServiceNames)
+	if config.HeadlessServiceName != "" && !slices.Contains(cache.headlessServiceNames, config.HeadlessServiceName) {
+		cache.headlessServiceNames = append([]string{config.HeadlessServiceName}, cache.headlessServiceNames...)
+	}
+	cache.servicePeers = make(map[string][]string)
+	cache.peerAddressMapper = config.PeerAddressMapper
+	cache.pathPrefix = normalizePathPrefix(config.PathPrefix)
+
+	cache.readOnly = config.ReadOnly
+
+	maxIdleConnsPerHost := config.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	cache.peerClient = newPeerClient(maxIdleConnsPerHost)
+	cache.network = cmp.Or(config.Network, "tcp")
+	if cache.network == "unix" {
+		cache.peerClient.Transport.(*http.Transport).DialContext = dialUnix
+	}
+	cache.tlsConfig = config.TLSConfig
+	if cache.tlsConfig != nil {
+		cache.peerClient.Transport.(*http.Transport).TLSClientConfig = cache.tlsConfig.Clone()
+	}
+	if config.PeerTransport != nil {
+		cache.peerClient.Transport = config.PeerTransport
+	}
+
+	maxConcurrentPeerRequests := config.MaxConcurrentPeerRequests
+	if maxConcurrentPeerRequests <= 0 {
+		maxConcurrentPeerRequests = defaultMaxConcurrentPeerRequests
+	}
+	cache.peerSem = make(chan struct{}, maxConcurrentPeerRequests)
+	if config.MaxConcurrentRequests > 0 {
+		cache.handlerSem = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+
+	cache.adminToken = config.AdminToken
+	cache.deleteFilter = config.DeleteFilter
+	cache.deleteReplicas = config.DeleteReplicas
+	cache.peerSelector = config.PeerSelector
+	cache.peerWeight = config.PeerWeight
+	cache.handoffTimeout = time.Duration(config.HandoffTimeoutSec) * time.Second
+	cache.metrics = config.Metrics
+	cache.warmUpPeers = config.WarmUpPeers
+	if len(config.EncryptionKey) != 0 {
+		enc, err := newEncryption(config.EncryptionKey, config.DecryptionKeys)
+		if err != nil {
+			// 평문으로 보내지 않도록 암호화가 필요한 전송은 모두 실패
+			cache.logger.Error("invalid EncryptionKey, values are not sent to peers", "err", err)
+			enc = &encryption{}
+		}
+		cache.encryption = enc
+	}
+	cache.snapshotFile = config.SnapshotFile
+	if cache.snapshotFile != "" {
+		cache.loadSnapshotFile()
+	}
+	cache.peerRequestHeaders = maps.Clone(config.PeerRequestHeaders)
+	cache.maxRequestBytes = config.MaxRequestBytes
+	if cache.maxRequestBytes <= 0 {
+		cache.maxRequestBytes = defaultMaxRequestBytes
+	}
+	cache.missStatusCode = config.MissStatusCode
+	cache.missHandler = config.MissHandler
+	cache.errorBody = config.ErrorBody
+	cache.peerFetch = config.PeerFetch
+	cache.peerConflictPolicy = config.PeerConflictPolicy
+	if config.PeerFetchStaggerMs <= 0 {
+		cache.peerFetchStagger = defaultPeerFetchStagger
+	} else {
+		cache.peerFetchStagger = time.Duration(config.PeerFetchStaggerMs) * time.Millisecond
+	}
+	if config.AuditLogSize > 0 {
+		cache.audit = newAuditLog(config.AuditLogSize)
+	}
+	if config.MaxClockSkewMs > 0 {
+		cache.maxClockSkew = time.Duration(config.MaxClockSkewMs) * time.Millisecond
+	}
+	cache.membershipSettle = time.Duration(config.MembershipSettleSec) * time.Second
+
+	cache.codec = config.Codec
+	if cache.codec == nil {
+		cache.codec = JSONCodec{}
+	}
+	cache.legacyCodecs = slices.Clone(config.LegacyCodecs)
 
 	if config.HeadlessServicePort < 4000 {
 		cache.headlessServicePort = 4567
@@ -96,14 +503,42 @@ func NewCache(config *Config) Cache {
 		cache.headlessServicePort = config.HeadlessServicePort
 	}
 
+	cache.changeChan = make(chan changeEvent, defaultChangeEventBufferSize)
+	cache.spawn(cache.changeEventWorker)
+	if config.WebhookURL != "" {
+		cache.startWebhook(config.WebhookURL, config.WebhookQueueSize)
+	}
+	if config.FlushSignal != nil {
+		cache.watchFlushSignal(config.FlushSignal)
+	}
+
+	if config.LazyCleanupOnly {
+		cache.ttlCleanupInterval = 0
+		cache.logger.Info("lazy cleanup only: expired entries are removed only when they are accessed")
+	} else if cache.ttlCleanupInterval == 0 {
+		cache.adaptiveCleanup = true
+		cache.ttlCleanupInterval = defaultAdaptiveCleanupInterval
+	}
+
 	if cache.ttlCleanupInterval != 0 {
-		cache.wg.Add(1)
-		go cache.ttlCleanUp()
+		cache.spawn(cache.ttlCleanUp)
+	}
+
+	if config.CompactIntervalSec > 0 {
+		cache.compactInterval = time.Duration(config.CompactIntervalSec) * time.Second
+		cache.compactRatio = cmp.Or(config.CompactRatio, defaultCompactRatio)
+		cache.spawn(cache.compactLoop)
 	}
 
-	if cache.headlessServiceName != "" {
-		cache.wg.Add(1)
-		go cache.watchHeadlessService()
+	if config.SingleNode {
+		cache.logger.Info("single node: no peers, deletes are not propagated")
+	} else if len(cache.headlessServiceNames) != 0 {
+		// 서비스를 시작하기 전에 peer 목록을 먼저 조회
+		ctx, cancel := context.WithTimeout(cache.ctx, defaultPeerResolveTimeout)
+		cache.peerAddresses = cache.getCurrentPeers(ctx)
+		cancel()
+
+		cache.spawn(cache.watchHeadlessService)
 		cache.addr = fmt.Sprintf(":%d", cache.headlessServicePort)
 		cache.newHTTPServer(cache.addr)
 	} else if len(config.PeerAddresses) != 0 && config.Addr != "" {
@@ -118,70 +553,305 @@ func NewCache(config *Config) Cache {
 	}
 
 	if cache.httpServ != nil {
-		cache.deleteChan = make(chan deleteEvent)
-		cache.wg.Add(1)
-		go cache.deleteEventWorker()
-		cache.wg.Add(1)
-		go cache.startHTTPServer()
+		queueSize := config.DeleteQueueSize
+		if queueSize <= 0 {
+			queueSize = defaultDeleteQueueSize
+		}
+		cache.deleteChan = make(chan deleteEvent, queueSize)
+		cache.dropDeletes = config.DropDeletesWhenFull
+		workers := config.DeleteWorkers
+		if workers <= 0 {
+			workers = defaultDeleteWorkers
+		}
+		for range workers {
+			cache.spawn(cache.deleteEventWorker)
+		}
+		if config.PropagateSets {
+			cache.setChan = make(chan setEvent, defaultSetEventBufferSize)
+			cache.spawn(cache.setEventWorker)
+		}
+		if config.PeerTransport != nil {
+			cache.unregister = config.PeerTransport.Register(cache.addr, cache.httpServ.Handler)
+		} else {
+			cache.spawn(cache.startHTTPServer)
+		}
 	}
 
 	return cache
 }
 
-func (c *cache) NewGroup(name string, getter Getter) Group {
-	return c.NewGroupWithTTL(name, getter, defttl)
+func (c *cache) NewGroup(name string, getter Getter, opts ...GroupOption) Group {
+	return c.NewGroupWithTTL(name, getter, defttl, opts...)
 }
 
-func (c *cache) NewGroupWithTTL(name string, getter Getter, ttl time.Duration) Group {
-	group := newGroup(name, getter, ttl, c.deleteChan)
+func (c *cache) NewGroupWithGetters(name string, getters ...Getter) Group {
+	return c.NewGroup(name, getterChain(getters))
+}
+
+func (c *cache) NewGroupWithTTL(name string, getter Getter, ttl time.Duration, opts ...GroupOption) Group {
+	group, err := c.CreateGroup(name, getter, ttl, opts...)
+	if err != nil {
+		c.logger.Error("new group refused", "group", name, "err", err)
+		return nil
+	}
+	return group
+}
+
+func (c *cache) CreateGroup(name string, getter Getter, ttl time.Duration, opts ...GroupOption) (Group, error) {
+	if reservedGroupName(name) {
+		return nil, fmt.Errorf("group '%s': %w", name, ErrReservedGroupName)
+	}
+	group, err := c.registerGroup(c.buildGroup(name, getter, ttl, opts...), true)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (c *cache) GetGroupOrCreate(name string, getter Getter, ttl time.Duration) Group {
+	c.mtx.RLock()
+	group, ok := c.group[name]
+	c.mtx.RUnlock()
+	if ok {
+		return group
+	}
+
+	if reservedGroupName(name) {
+		c.logger.Error("new group refused", "group", name, "err", ErrReservedGroupName)
+		return nil
+	}
+	registered, err := c.registerGroup(c.buildGroup(name, getter, ttl), false)
+	if err != nil {
+		c.logger.Error("new group refused", "group", name, "err", err)
+		return nil
+	}
+	return registered
+}
+
+// registerGroup adds g under its name and restores its entries from the
+// SnapshotFile. An existing group of the name is replaced when replace is set,
+// and returned instead of g otherwise.
+func (c *cache) registerGroup(g *group, replace bool) (*group, error) {
 	c.mtx.Lock()
-	c.group[name] = group
+	cur, ok := c.group[g.name]
+	switch {
+	case ok && !replace:
+		c.mtx.Unlock()
+		return cur, nil
+	// 같은 이름의 group 을 교체하는 경우는 제한에 포함하지 않음
+	case !ok && c.maxGroups > 0 && len(c.group) >= c.maxGroups:
+		c.mtx.Unlock()
+		return nil, fmt.Errorf("group '%s': %w", g.name, ErrTooManyGroups)
+	}
+	c.group[g.name] = g
 	c.mtx.Unlock()
+	if c.snapshot != nil {
+		c.restoreSnapshot(g)
+	}
+	return g, nil
+}
+
+func reservedGroupName(name string) bool {
+	return name == "" || strings.HasPrefix(name, "_")
+}
+
+// buildGroup creates a group wired to the cache without registering it.
+func (c *cache) buildGroup(name string, getter Getter, ttl time.Duration, opts ...GroupOption) *group {
+	group := newGroup(name, getter, ttl, c.deleteChan)
+	group.changeChan = c.changeChan
+	group.setChan = c.setChan
+	group.dropDeletes = c.dropDeletes
+	group.readOnly = c.readOnly
+	group.maintenance = &c.maintenance
+	if c.peerFetch && c.httpServ != nil {
+		group.peerFetch = func(ctx context.Context, key string) (setEntry, error) {
+			return c.fetchFromPeers(ctx, group, key)
+		}
+	}
+	if c.deleteChan != nil {
+		group.peerDelete = func(keys []string) DeleteResult {
+			if len(keys) == 1 {
+				return c.propagateDelete(name, keys[0])
+			}
+			return c.propagateDeleteBatch(name, keys)
+		}
+	}
+	group.done = c.ctx.Done()
+	group.codec = c.codec
+	group.legacyCodecs = c.legacyCodecs
+	group.logger = c.logger
+	group.reportError = c.reportError
+	group.spawn = c.spawn
+	group.audit = c.audit
+	for _, opt := range opts {
+		opt(group)
+	}
+	if c.budget != nil {
+		group.budget = c.budget
+		if group.sizer == nil {
+			group.sizer = defaultSizer(group.codec)
+		}
+	}
 	return group
 }
 
-func (c *cache) GetGroup(name string) Group {
+// groups returns the groups sorted by name, so that the SnapshotFile of the same
+// entries is written the same way every time.
+func (c *cache) groups() []*group {
 	c.mtx.RLock()
-	g, ok := c.group[name]
+	groups := make([]*group, 0, len(c.group))
+	for _, g := range c.group {
+		groups = append(groups, g)
+	}
+	c.mtx.RUnlock()
+
+	slices.SortFunc(groups, func(a, b *group) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return groups
+}
+
+func (c *cache) GroupCount() int {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return len(c.group)
+}
+
+func (c *cache) GetGroup(name string) Group {
+	g, ok := c.GetGroupOK(name)
 	if !ok {
-		c.mtx.RUnlock()
+		// typed nil 이 아닌 nil interface 를 반환
 		return nil
 	}
-	c.mtx.RUnlock()
 	return g
 }
 
+func (c *cache) GetGroupOK(name string) (Group, bool) {
+	g, err := c.getGroupByName(name)
+	if err != nil {
+		return nil, false
+	}
+	return g, true
+}
+
+func (c *cache) OnChange(fn func(group, key string, op Op, val any)) {
+	c.mtx.Lock()
+	c.changeFuncs = append(c.changeFuncs, fn)
+	c.mtx.Unlock()
+}
+
+func (c *cache) changeEventWorker() {
+	for {
+		select {
+		case event := <-c.changeChan:
+			c.mtx.RLock()
+			funcs := c.changeFuncs
+			c.mtx.RUnlock()
+			for _, fn := range funcs {
+				fn(event.group, event.key, event.op, event.val)
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
 func (c *cache) getGroupByName(name string) (*group, error) {
-	g := c.GetGroup(name)
-	if g == nil {
+	c.mtx.RLock()
+	g, ok := c.group[name]
+	c.mtx.RUnlock()
+	if !ok || g == nil {
 		return nil, fmt.Errorf("group '%s' not found", name)
 	}
-	return g.(*group), nil
+	return g, nil
 }
 
 func (c *cache) ttlCleanUp() {
-	defer c.wg.Done()
+	interval := c.ttlCleanupInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			scanned, removed := c.cleanupGroups(time.Now())
+			if c.adaptiveCleanup {
+				interval = nextCleanupInterval(interval, scanned, removed)
+			}
+			timer.Reset(interval)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
 
-	ticker := time.NewTicker(c.ttlCleanupInterval)
+func (c *cache) compactLoop() {
+	ticker := time.NewTicker(c.compactInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			now := time.Now()
-			c.mtx.Lock()
-			for _, group := range c.group {
-				group.ttlCleanUp(now)
-			}
-			c.mtx.Unlock()
+			c.compactGroups()
 		case <-c.ctx.Done():
 			return
 		}
 	}
 }
 
+// compactGroups compacts the groups holding fewer entries than their compact
+// ratio of their peak, see Config.CompactIntervalSec.
+func (c *cache) compactGroups() {
+	for _, g := range c.groups() {
+		g.autoCompact(0, cmp.Or(g.compactRatio, c.compactRatio))
+	}
+}
+
+func (c *cache) ResetStats() map[string]Stats {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	stats := make(map[string]Stats, len(c.group))
+	for name, g := range c.group {
+		stats[name] = g.ResetStats()
+	}
+	return stats
+}
+
+func (c *cache) Cleanup() int {
+	_, removed := c.cleanupGroups(time.Now())
+	return removed
+}
+
+func (c *cache) cleanupGroups(now time.Time) (scanned, removed int) {
+	// onExpire 와 dependent 삭제가 c.mtx 를 잡는 worker 를 기다릴 수 있으므로
+	// cache lock 없이 sweep
+	for _, group := range c.groups() {
+		s, r := group.ttlCleanUp(now)
+		scanned += s
+		removed += r
+	}
+	return scanned, removed
+}
+
+// nextCleanupInterval shortens the interval when a sweep removes many expired
+// entries (at least a quarter of the scanned ones) and backs off when it removes none.
+func nextCleanupInterval(interval time.Duration, scanned, removed int) time.Duration {
+	switch {
+	case removed == 0:
+		return min(interval*2, maxAdaptiveCleanupInterval)
+	case removed*4 >= scanned:
+		return max(interval/2, minAdaptiveCleanupInterval)
+	}
+	return interval
+}
+
 func (c *cache) watchHeadlessService() {
-	defer c.wg.Done()
+	// 모든 pod 가 동시에 조회하지 않도록 시작 시점을 분산
+	select {
+	case <-time.After(rand.N(c.headlessServiceWatchInterval)):
+	case <-c.ctx.Done():
+		return
+	}
 
 	ticker := time.NewTicker(c.headlessServiceWatchInterval)
 	defer ticker.Stop()
@@ -189,34 +859,70 @@ func (c *cache) watchHeadlessService() {
 	for {
 		select {
 		case <-ticker.C:
-			newPeers := c.getCurrentPeers() // 최신 peers 조회
-			c.mtx.Lock()                    // 동기화
-
-			// 삭제된 노드 확인
-			for _, oldPeer := range c.peerAddresses {
-				found := slices.Contains(newPeers, oldPeer)
-				if !found {
-					fmt.Printf("node %s has been removed.\n", oldPeer)
-				}
-			}
-
-			// 추가된 노드 확인
-			for _, newPeer := range newPeers {
-				found := slices.Contains(c.peerAddresses, newPeer)
-				if !found {
-					fmt.Printf("node %s has been added.\n", newPeer)
-				}
-			}
-
-			// c.peerAddresses를 newPeers로 업데이트
-			c.peerAddresses = newPeers
-			c.mtx.Unlock()
+			c.updatePeers()
 		case <-c.ctx.Done():
 			return
 		}
 	}
 }
 
+// updatePeers replaces peerAddresses with the peers currently resolved from the
+// headless services, unless discovery is paused.
+func (c *cache) updatePeers() {
+	if c.discoveryPaused.Load() {
+		return
+	}
+	newPeers := c.getCurrentPeers(c.ctx) // 최신 peers 조회
+	c.mtx.Lock()                         // 동기화
+	defer c.mtx.Unlock()
+
+	// 삭제된 노드 확인
+	for _, oldPeer := range c.peerAddresses {
+		found := slices.Contains(newPeers, oldPeer)
+		if !found {
+			c.logger.Info("node has been removed", "peer", oldPeer)
+		}
+	}
+
+	// 추가된 노드 확인
+	for _, newPeer := range newPeers {
+		found := slices.Contains(c.peerAddresses, newPeer)
+		if !found {
+			c.logger.Info("node has been added", "peer", newPeer)
+			if c.warmUpPeers {
+				c.spawn(func() { c.warmUp(newPeer) })
+			}
+		}
+	}
+
+	// c.peerAddresses를 newPeers로 업데이트
+	c.peerAddresses = newPeers
+}
+
+func (c *cache) SetMaintenanceMode(enabled bool) {
+	if c.maintenance.Swap(enabled) != enabled {
+		c.logger.Warn("cache maintenance mode changed", "enabled", enabled)
+	}
+}
+
+func (c *cache) MaintenanceMode() bool {
+	return c.maintenance.Load()
+}
+
+func (c *cache) PauseDiscovery() {
+	c.discoveryPaused.Store(true)
+}
+
+func (c *cache) ResumeDiscovery() {
+	c.discoveryPaused.Store(false)
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func getLocalIPs() map[string]struct{} {
 	localIPs := make(map[string]struct{})
 
@@ -250,67 +956,463 @@ func getLocalIPs() map[string]struct{} {
 	return localIPs
 }
 
-func (c *cache) getCurrentPeers() []string {
-	addrs, err := net.LookupHost(c.headlessServiceName)
-	if err != nil {
-		return nil
+func (c *cache) getCurrentPeers(ctx context.Context) []string {
+	lookupHost, localIPsOf := c.lookupHost, c.localIPs
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+	if localIPsOf == nil {
+		localIPsOf = getLocalIPs
 	}
+	localIPs := localIPsOf() // 현재 노드의 IP 목록 가져오기
+	var peers []string
+	var self string
+	var errs []error
 
-	localIPs := getLocalIPs() // 현재 노드의 IP 목록 가져오기
-	peers := make([]string, 0, len(addrs))
+	for _, name := range c.headlessServiceNames {
+		addrs, err := lookupHost(ctx, name)
+		if err != nil {
+			err = fmt.Errorf("resolve headless service %s: %w", name, err)
+			c.reportError(err)
+			errs = append(errs, err)
+			// 조회에 실패한 service 는 이전 결과를 유지
+			peers = append(peers, c.servicePeers[name]...)
+			continue
+		}
+
+		resolved := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			if _, exists := localIPs[addr]; exists {
+				// 현재 노드의 IP는 제외하고, 다른 node 가 부르는 이 node 의 주소로 사용
+				self = cmp.Or(self, fmt.Sprintf("%s:%d", addr, c.headlessServicePort))
+				continue
+			}
+			resolved = append(resolved, fmt.Sprintf("%s:%d", addr, c.headlessServicePort))
+		}
+		c.servicePeers[name] = resolved
+		peers = append(peers, resolved...)
+	}
 
-	for _, addr := range addrs {
-		if _, exists := localIPs[addr]; exists {
-			continue // 현재 노드의 IP는 제외
+	if c.peerAddressMapper != nil {
+		peers = c.peerAddressMapper(peers)
+		// 다른 node 의 목록에 있는 이 node 의 주소도 같은 방식으로 변환
+		if mapped := c.peerAddressMapper([]string{self}); self != "" && len(mapped) != 0 {
+			self = mapped[0]
+			// 다른 ip 가 이 node 의 주소로 변환된 경우도 제외
+			peers = slices.DeleteFunc(peers, func(peer string) bool { return peer == self })
 		}
-		peers = append(peers, fmt.Sprintf("%s:%d", addr, c.headlessServicePort))
 	}
-	return peers
+
+	c.mtx.Lock()
+	if c.resolveErr = errors.Join(errs...); c.resolveErr == nil {
+		c.resolvedAt = time.Now()
+	}
+	if self != "" {
+		c.resolvedSelf = self
+	}
+	c.mtx.Unlock()
+
+	// 여러 service 에 중복된 peer 제거
+	slices.Sort(peers)
+	return slices.Compact(peers)
 }
 
 func (c *cache) startHTTPServer() {
-	defer c.wg.Done()
-	if err := c.httpServ.ListenAndServe(); err != nil {
-		panic(err)
+	ln, err := c.listen()
+	if err == nil {
+		c.listening.Store(true)
+		defer c.listening.Store(false)
+		if c.httpServ.TLSConfig != nil {
+			err = c.httpServ.ServeTLS(ln, "", "")
+		} else {
+			err = c.httpServ.Serve(ln)
+		}
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		c.reportError(fmt.Errorf("http server %s: %w", c.httpServ.Addr, err))
 	}
 }
 
+// deleteEventWorker propagates queued deletes. Several workers drain deleteChan
+// concurrently, so deletes may reach a peer in a different order than they were
+// queued; deletes are idempotent and nothing relies on their order.
 func (c *cache) deleteEventWorker() {
 	for {
 		select {
-		case event, ok := <-c.deleteChan:
-			if !ok {
-				return
+		case event := <-c.deleteChan:
+			if event.keys != nil {
+				c.propagateDeleteBatch(event.group, event.keys)
+			} else {
+				c.propagateDelete(event.group, event.key)
 			}
-			c.propagateDelete(event.group, event.key)
 		case <-c.ctx.Done():
 			return
 		}
 	}
 }
 
-func (c *cache) propagateDelete(group, key string) {
-	for _, peer := range c.peerAddresses {
-		url := fmt.Sprintf("http://%s/%s/%s", peer, group, key)
-		req, err := http.NewRequest("DELETE", url, nil)
+// deleteTargets returns the peers a delete of key is sent to, see Config.DeleteReplicas.
+func (c *cache) deleteTargets(group, key string) []string {
+	if c.peerSelector != nil {
+		return c.selectPeers(group, key)
+	}
+	if c.deleteReplicas <= 0 {
+		c.mtx.RLock()
+		defer c.mtx.RUnlock()
+		return slices.Clone(c.peerAddresses)
+	}
+	ring := c.hashRing()
+	nodes := ring.order(group, key)
+	nodes = nodes[:min(c.deleteReplicas, len(nodes))]
+	return slices.DeleteFunc(nodes, func(node string) bool {
+		return node == ring.self
+	})
+}
+
+func (c *cache) propagateDelete(group, key string) DeleteResult {
+	var result DeleteResult
+	for _, peer := range c.deleteTargets(group, key) {
+		req, err := c.newPeerRequest(context.Background(), "DELETE", c.peerURL(peer, group, key), nil)
 		if err != nil {
+			result.add(peer, err)
 			continue
 		}
-		client := &http.Client{Timeout: 2 * time.Second}
-		resp, err := client.Do(req)
+		result.add(peer, deleteAcknowledged(c.doPeerRequest(req)))
+	}
+	return result
+}
+
+// deleteAcknowledged returns the error of a delete request sent to a peer. A peer
+// without the group has nothing to delete, so its 404 is not an error.
+func deleteAcknowledged(resp *http.Response, _ []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// propagateDeleteBatch sends keys to the peers in a single request per peer.
+func (c *cache) propagateDeleteBatch(group string, keys []string) DeleteResult {
+	peerKeys := make(map[string][]string)
+	if c.deleteReplicas <= 0 && c.peerSelector == nil {
+		for _, peer := range c.deleteTargets(group, "") {
+			peerKeys[peer] = keys
+		}
+	} else {
+		for _, key := range keys {
+			for _, peer := range c.deleteTargets(group, key) {
+				peerKeys[peer] = append(peerKeys[peer], key)
+			}
+		}
+	}
+
+	var result DeleteResult
+	for peer, keys := range peerKeys {
+		body, err := json.Marshal(keys)
+		if err != nil {
+			result.add(peer, err)
+			continue
+		}
+		req, err := c.newPeerRequest(context.Background(), "DELETE", c.peerURL(peer, group), bytes.NewReader(body))
 		if err != nil {
+			result.add(peer, err)
 			continue
 		}
-		defer resp.Body.Close()
+		req.Header.Set("Content-Type", "application/json")
+		result.add(peer, deleteAcknowledged(c.doPeerRequest(req)))
 	}
+	return result
+}
+
+func (c *cache) setEventWorker() {
+	for {
+		select {
+		case event := <-c.setChan:
+			c.propagateSet(event.group, event.entries)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// propagateSet pushes entries to every peer in a single request per peer.
+func (c *cache) propagateSet(groupName string, entries []setEntry) {
+	g, err := c.getGroupByName(groupName)
+	if err != nil {
+		return
+	}
+	c.pushEntries(context.Background(), g, entries)
+}
+
+func (c *cache) pushEntries(ctx context.Context, g *group, entries []setEntry) {
+	entries, err := g.encodeBinaryEntries(entries)
+	if err != nil {
+		c.reportError(fmt.Errorf("push %s: %w", g.name, err))
+		return
+	}
+	body, err := g.codec.Marshal(entries)
+	if err != nil {
+		return
+	}
+	if body, err = c.seal(body); err != nil {
+		c.reportError(fmt.Errorf("push %s: %w", g.name, err))
+		return
+	}
+
+	c.mtx.RLock()
+	peers := slices.Clone(c.peerAddresses)
+	c.mtx.RUnlock()
+	for _, peer := range peers {
+		req, err := c.newPeerRequest(ctx, "PUT", c.peerURL(peer, g.name), bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", g.codec.ContentType())
+		c.setEncrypted(req.Header)
+		c.doPeerRequest(req)
+	}
+}
+
+// fetchFromPeers asks the peers for a locally cached value of key.
+// The most likely owner is asked first; when it does not answer within the
+// stagger delay (or fails) the remaining peers are asked at once, and the
+// first successful response wins and cancels the others.
+func (c *cache) fetchFromPeers(ctx context.Context, g *group, key string) (setEntry, error) {
+	var peers []string
+	first := 1
+	if c.peerSelector != nil {
+		peers = c.selectPeers(g.name, key)
+	} else {
+		ring := c.hashRing()
+		peers = slices.DeleteFunc(ring.order(g.name, key), func(peer string) bool {
+			return peer == ring.self
+		})
+		// ring 이 바뀐 직후에는 이전 owner 에게도 먼저 요청
+		if prev := c.previousOwner(ring, g.name, key); prev != "" {
+			i := slices.Index(peers, prev)
+			peers = slices.Delete(peers, i, i+1)
+			peers = slices.Insert(peers, min(1, len(peers)), prev)
+			first = 2
+		}
+	}
+
+	notFound := fmt.Errorf("%s %w in peers", key, ErrNotFound)
+	if len(peers) == 0 {
+		return setEntry{}, notFound
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if c.peerConflictPolicy != FirstResponse {
+		entry, ok, err := c.fetchNewestFromPeers(ctx, g, key, peers)
+		if !ok && err == nil {
+			err = notFound
+		}
+		return entry, err
+	}
+
+	type result struct {
+		entry setEntry
+		err   error
+	}
+	results := make(chan result, len(peers))
+	next, pending := 0, 0
+	launch := func(n int) {
+		for ; next < len(peers) && n > 0; next, n = next+1, n-1 {
+			pending++
+			go func(peer string) {
+				entry, err := c.fetchFromPeer(ctx, g, peer, key)
+				results <- result{entry, err}
+			}(peers[next])
+		}
+	}
+
+	launch(first)
+	timer := time.NewTimer(c.peerFetchStagger)
+	defer timer.Stop()
+
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.entry, nil
+			}
+			launch(len(peers))
+		case <-timer.C:
+			launch(len(peers))
+		case <-ctx.Done():
+			return setEntry{}, ctx.Err()
+		}
+	}
+	return setEntry{}, notFound
+}
+
+func (c *cache) fetchFromPeer(ctx context.Context, g *group, peer, key string) (setEntry, error) {
+	req, err := c.newPeerRequest(ctx, "GET", c.peerURL(peer, g.name, key)+"?local=true", nil)
+	if err != nil {
+		return setEntry{}, err
+	}
+	var accept []string
+	if g.newBinaryValue != nil {
+		accept = append(accept, binaryContentType)
+	}
+	for _, codec := range g.codecs() {
+		accept = append(accept, codec.ContentType())
+	}
+	req.Header.Set("Accept", strings.Join(accept, ", "))
+	resp, body, err := c.doPeerRequest(req)
+	if err != nil {
+		return setEntry{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return setEntry{}, fmt.Errorf("peer %s responded %s", peer, resp.Status)
+	}
+	if body, err = c.open(resp.Header, body); err != nil {
+		return setEntry{}, fmt.Errorf("peer %s: %w", peer, err)
+	}
+
+	entry := setEntry{Key: key}
+	if slices.Contains(mediaTypes(resp.Header.Get("Content-Type")), binaryContentType) {
+		entry.Value, err = g.unmarshalBinary(body)
+	} else {
+		err = g.unmarshal(resp.Header.Get("Content-Type"), body, &entry.Value)
+	}
+	if err != nil {
+		return setEntry{}, err
+	}
+	entry.TTL, err = time.ParseDuration(resp.Header.Get(headerCacheTTL))
+	if err != nil || entry.TTL <= 0 {
+		entry.TTL = g.defaultTTL()
+	}
+	entry.Generation, _ = strconv.ParseUint(resp.Header.Get(headerGeneration), 10, 64)
+	entry.createdAt, _ = time.Parse(time.RFC3339Nano, resp.Header.Get(headerCreatedAt))
+	// schema version 을 보내지 않는 peer 는 version 0
+	entry.SchemaVersion, _ = strconv.Atoi(resp.Header.Get(headerSchemaVersion))
+	if entry.SchemaVersion != g.schemaVersion {
+		return setEntry{}, fmt.Errorf("peer %s has schema version %d of %s, want %d", peer, entry.SchemaVersion, key, g.schemaVersion)
+	}
+	return entry, nil
+}
+
+// propagateFlush sends the flush of group to every peer at once and reports
+// which peers applied it.
+func (c *cache) propagateFlush(group string) DeleteResult {
+	c.mtx.RLock()
+	peers := slices.Clone(c.peerAddresses)
+	c.mtx.RUnlock()
+
+	var result DeleteResult
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := c.newPeerRequest(context.Background(), "POST", c.peerURL(peer, group, "_flush"), nil)
+			if err == nil {
+				req.Header.Set("Authorization", "Bearer "+c.adminToken)
+				err = deleteAcknowledged(c.doPeerRequest(req))
+			}
+			mtx.Lock()
+			result.add(peer, err)
+			mtx.Unlock()
+		}()
+	}
+	wg.Wait()
+	return result
+}
+
+func newPeerClient(maxIdleConnsPerHost int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return &http.Client{
+		Timeout:   defaultPeerRequestTimeout,
+		Transport: transport,
+	}
+}
+
+// doPeerRequest sends req with the shared peer client, bounded by the peer request
+// concurrency limit. The response body is read and closed before returning.
+func (c *cache) doPeerRequest(req *http.Request) (*http.Response, []byte, error) {
+	select {
+	case c.peerSem <- struct{}{}:
+		defer func() { <-c.peerSem }()
+	case <-req.Context().Done():
+		return nil, nil, req.Context().Err()
+	}
+
+	start := time.Now()
+	resp, err := c.peerClient.Do(req)
+	c.recordPeerResult(peerOfHost(req.URL.Host), resp, err)
+	if err == nil && c.maxClockSkew > 0 {
+		c.recordClockSkew(peerOfHost(req.URL.Host), resp, start, time.Now())
+	}
+	if err != nil {
+		c.reportError(fmt.Errorf("peer request %s %s: %w", req.Method, req.URL, err))
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		c.reportError(fmt.Errorf("peer request %s %s: %s", req.Method, req.URL, resp.Status))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// reportError passes a non-fatal background error to Config.OnError, or logs it when no handler is set.
+func (c *cache) reportError(err error) {
+	if c.onError != nil {
+		c.onError(err)
+		return
+	}
+	c.logger.Error(err.Error())
+}
+
+func (c *cache) RegisterCloseHook(fn func()) {
+	c.mtx.Lock()
+	c.closeHooks = append(c.closeHooks, fn)
+	c.mtx.Unlock()
 }
 
 func (c *cache) Close() {
+	c.draining.Store(true)
+	// hook 이 마지막으로 쓴 값도 handoff 와 snapshot 에 포함
+	c.mtx.RLock()
+	hooks := c.closeHooks
+	c.mtx.RUnlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+
+	if c.handoffTimeout > 0 && !c.readOnly {
+		c.handoff(c.handoffTimeout)
+	}
+	if c.snapshotFile != "" {
+		if err := c.writeSnapshotFile(); err != nil {
+			c.reportError(fmt.Errorf("snapshot file %s: %w", c.snapshotFile, err))
+		}
+	}
+
 	c.cancel()
+	if c.unregister != nil {
+		c.unregister()
+	}
 	if c.httpServ != nil {
-		close(c.deleteChan)
 		c.httpServ.Shutdown(c.ctx)
 		c.httpServ.Close()
 	}
+	c.spawnMtx.Lock()
+	c.closing = true
+	c.spawnMtx.Unlock()
 	c.wg.Wait()
 }
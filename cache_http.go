@@ -1,57 +1,369 @@
 package cache
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// newHTTPServer registers the routes of the peer http server. Group names
+// starting with "_" are rejected by CreateGroup, so the internal endpoints
+// live under /_cache/ without colliding with /{groupName}.
 func (c *cache) newHTTPServer(addr string) {
 	r := chi.NewRouter()
-	r.Delete("/{groupName}/{key}", c.deleteHandler)
+	r.Use(sendTime)
+	// probe 는 요청이 몰려도 응답하도록 동시 요청 제한에서 제외
+	r.Get("/_cache/healthz", healthzHandler)
+	r.Get("/_cache/readyz", c.readyzHandler)
 
-	// use debug
-	r.Get("/{groupName}", c.getGroupHandler)
-	r.Get("/{groupName}/{key}", c.getHandler)
+	r.Group(func(r chi.Router) {
+		if c.handlerSem != nil {
+			r.Use(c.concurrencyLimit)
+		}
+		r.With(c.loopGuard).Delete("/{groupName}/{key}", c.deleteHandler)
+		r.With(c.loopGuard).Delete("/{groupName}", c.deleteBatchHandler)
+		r.With(c.loopGuard).Put("/{groupName}", c.setHandler)
+		r.With(c.adminAuth, c.loopGuard).Post("/{groupName}/_flush", c.flushHandler)
+
+		if c.metrics {
+			r.Get("/_cache/metrics", c.metricsHandler)
+		}
+		r.Get("/_cache/stats", c.statsHandler)
+		r.Get("/_cache/ring", c.ringHandler)
+		r.With(c.adminAuth).Get("/_cache/audit", c.auditHandler)
+
+		// use debug
+		r.Get("/{groupName}", c.getGroupHandler)
+		r.Get("/{groupName}/{key}", c.getHandler)
+	})
+
+	var handler http.Handler = r
+	if c.pathPrefix != "" {
+		root := chi.NewRouter()
+		root.Mount(c.pathPrefix, r)
+		handler = root
+	}
 
 	c.httpServ = &http.Server{
-		Addr:    addr,
-		Handler: r,
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: c.tlsConfig,
+	}
+}
+
+// normalizePathPrefix returns prefix with a leading slash and without a trailing slash.
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// newPeerRequest creates a request to a peer, marked with this node as its origin.
+// Config.PeerRequestHeaders cannot override the headers used by the loop guard.
+func (c *cache) newPeerRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.peerRequestHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set(headerOrigin, c.nodeID)
+	req.Header.Set(headerHops, "1")
+	return req, nil
+}
+
+// loopGuard ignores peer events that originated from this node
+// or that went through more than maxPropagationHops peers.
+func (c *cache) loopGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get(headerOrigin); origin != "" && origin == c.nodeID {
+			c.writeJSONError(w, http.StatusLoopDetected, "event originated from this node")
+			return
+		}
+		if hops, err := strconv.Atoi(r.Header.Get(headerHops)); err == nil && hops > maxPropagationHops {
+			c.writeJSONError(w, http.StatusLoopDetected, fmt.Sprintf("event exceeded %d hops", maxPropagationHops))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// urlParam returns the decoded url parameter. chi matches the escaped path
+// when the request path contains escaped characters such as %2F.
+func urlParam(r *http.Request, name string) string {
+	param := chi.URLParam(r, name)
+	if r.URL.RawPath == "" {
+		return param
+	}
+	if unescaped, err := url.PathUnescape(param); err == nil {
+		return unescaped
+	}
+	return param
+}
+
+// peerURL returns the url of the peer endpoint for the given path segments.
+// Each segment is escaped, so group names and keys may contain '/', '?', '#' or spaces.
+func (c *cache) peerURL(peer string, segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	scheme := "http"
+	if c.tlsConfig != nil {
+		scheme = "https"
+	}
+	if c.network == "unix" {
+		peer = unixHost(peer)
+	}
+	return fmt.Sprintf("%s://%s%s/%s", scheme, peer, c.pathPrefix, strings.Join(escaped, "/"))
+}
+
+const (
+	// headerTime carries the clock of the node sending a response, in unix nanoseconds.
+	headerTime = "X-Cache-Time"
+	// headerCacheTTL carries the remaining ttl of a value served to a peer.
+	headerCacheTTL = "X-Cache-TTL"
+	// headerGeneration carries the generation of a value served to a peer.
+	headerGeneration = "X-Cache-Generation"
+	// headerCreatedAt carries the time a value served to a peer was first cached.
+	headerCreatedAt = "X-Cache-Created-At"
+	// headerSchemaVersion carries the schema version of a value served to a peer.
+	headerSchemaVersion = "X-Cache-Schema-Version"
+	// headerOrigin is the node id of the node that originated a peer request.
+	headerOrigin = "X-Cache-Origin"
+	// headerHops is the number of peers a request went through.
+	headerHops = "X-Cache-Hops"
+	// headerCacheBypass set to "true" makes GET /{groupName}/{key} refresh the
+	// value from the getter. It requires the admin token.
+	headerCacheBypass = "X-Cache-Bypass"
+
+	// peer 요청은 origin 에서 직접 전달되며 다시 전파되지 않는다
+	maxPropagationHops = 1
+)
+
+type valueResponse struct {
+	Key        string    `json:"key"`
+	Value      any       `json:"value"`
+	TTLSeconds float64   `json:"ttl_seconds"`
+	CreatedAt  time.Time `json:"created_at"`
+	Generation uint64    `json:"generation"`
+}
+
+// wantsJSON reports whether the client asked for the JSON representation
+// with "?format=json" or an "Accept: application/json" header.
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// MissReason tells why GET /{groupName}/{key} did not return a value.
+type MissReason string
+
+const (
+	MissGroupNotFound MissReason = "group_not_found"
+	// the key was never cached, or the getter did not find it
+	MissNotFound MissReason = "not_found"
+	// the key was cached but expired, and no getter refilled it
+	MissExpired MissReason = "expired"
+)
+
+type missResponse struct {
+	Error  string     `json:"error"`
+	Reason MissReason `json:"reason"`
+}
+
+// writeMiss writes the response of a get miss with Config.MissHandler or Config.MissStatusCode.
+func (c *cache) writeMiss(w http.ResponseWriter, r *http.Request, reason MissReason, message string) {
+	if c.missHandler != nil {
+		c.missHandler(w, r, reason)
+		return
+	}
+
+	status := cmp.Or(c.missStatusCode, http.StatusNotFound)
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(c.errorResponseBody(status, message, reason))
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) {
+// errorResponseBody returns the JSON body of an error response, built by
+// Config.ErrorBody when set. reason is empty except for get misses.
+func (c *cache) errorResponseBody(status int, message string, reason MissReason) []byte {
+	var body any = errorResponse{Error: message}
+	switch {
+	case c.errorBody != nil:
+		body = c.errorBody(status, message, reason)
+	case reason != "":
+		body = missResponse{Error: message, Reason: reason}
+	}
+	dat, err := json.Marshal(body)
+	if err != nil {
+		dat, _ = json.Marshal(errorResponse{Error: message})
+	}
+	return dat
+}
+
+func (c *cache) writeJSONError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	fmt.Fprintf(w, `{"error": "%s"}`, message)
+	w.Write(c.errorResponseBody(status, message, ""))
 }
 
 func (c *cache) deleteHandler(w http.ResponseWriter, r *http.Request) {
-	groupName := chi.URLParam(r, "groupName")
-	key := chi.URLParam(r, "key")
+	groupName := urlParam(r, "groupName")
+	key := urlParam(r, "key")
 	if groupName == "" || key == "" {
-		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing group name(%s) or key(%s)", groupName, key))
+		c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing group name(%s) or key(%s)", groupName, key))
 		return
 	}
 
 	g, err := c.getGroupByName(groupName)
 	if err != nil {
-		writeJSONError(w, http.StatusNotFound, err.Error())
+		c.writeJSONError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	g.mtx.Lock()
-	delete(g.data, key)
-	g.mtx.Unlock()
+	if vetoed := c.vetoDeletes(groupName, []string{key}); len(vetoed) != 0 {
+		c.writeJSONError(w, http.StatusConflict, fmt.Sprintf("delete of key '%s' in group '%s' refused", key, groupName))
+		return
+	}
+	g.deleteKeysFrom([]string{key}, peerOrigin(r))
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(fmt.Appendf(nil, "key '%s' deleted successfully from group '%s'", key, groupName))
 }
 
+// deleteBatchHandler deletes the JSON array of keys in the body under a single lock.
+func (c *cache) deleteBatchHandler(w http.ResponseWriter, r *http.Request) {
+	groupName := urlParam(r, "groupName")
+
+	g, err := c.getGroupByName(groupName)
+	if err != nil {
+		c.writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	body, err := c.readBody(w, r)
+	if err != nil {
+		return
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("data unmarshal failed. err=%v", err))
+		return
+	}
+	vetoed := c.vetoDeletes(groupName, keys)
+	if len(vetoed) != 0 {
+		keys = slices.DeleteFunc(keys, func(key string) bool {
+			return slices.Contains(vetoed, key)
+		})
+	}
+	g.deleteKeysFrom(keys, peerOrigin(r))
+	if len(vetoed) != 0 {
+		// 허용된 key 는 삭제하고 거부된 key 를 알린다
+		c.writeJSONError(w, http.StatusConflict, fmt.Sprintf("delete of %d keys in group '%s' refused: %s", len(vetoed), groupName, strings.Join(vetoed, ", ")))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(fmt.Appendf(nil, "%d keys deleted successfully from group '%s'", len(keys), groupName))
+}
+
+// vetoDeletes returns the keys Config.DeleteFilter refuses to delete, and logs them.
+func (c *cache) vetoDeletes(group string, keys []string) []string {
+	if c.deleteFilter == nil {
+		return nil
+	}
+	var vetoed []string
+	for _, key := range keys {
+		if !c.deleteFilter(group, key) {
+			vetoed = append(vetoed, key)
+			c.logger.Info("peer delete vetoed", "group", group, "key", key)
+		}
+	}
+	return vetoed
+}
+
+// readBody reads the request body up to maxRequestBytes.
+// On failure the error response is already written.
+func (c *cache) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.maxRequestBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+		} else {
+			c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("read body failed. err=%v", err))
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// setHandler stores the entries pushed by a peer without propagating them further.
+func (c *cache) setHandler(w http.ResponseWriter, r *http.Request) {
+	groupName := urlParam(r, "groupName")
+
+	g, err := c.getGroupByName(groupName)
+	if err != nil {
+		c.writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	body, err := c.readBody(w, r)
+	if err != nil {
+		return
+	}
+	if body, err = c.open(r.Header, body); err != nil {
+		c.writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var entries []setEntry
+	if err := g.unmarshal(r.Header.Get("Content-Type"), body, &entries); err != nil {
+		c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("data unmarshal failed. err=%v", err))
+		return
+	}
+	if err := g.decodeBinaryEntries(entries); err != nil {
+		c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("data unmarshal failed. err=%v", err))
+		return
+	}
+	origin := peerOrigin(r)
+	for i := range entries {
+		entries[i].origin = origin
+	}
+	g.setEntries(entries)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(fmt.Appendf(nil, "%d keys set successfully in group '%s'", len(entries), groupName))
+}
+
 func (c *cache) getGroupHandler(w http.ResponseWriter, r *http.Request) {
-	groupName := chi.URLParam(r, "groupName")
+	groupName := urlParam(r, "groupName")
 
 	if groupName == "" {
 		http.Error(w, "missing group name", http.StatusBadRequest)
@@ -64,37 +376,285 @@ func (c *cache) getGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dat, err := g.(*group).JSONMarshalIndent("", " ")
+	// limit 또는 offset 이 있으면 key 순서로 일부만 반환
+	var dat []byte
+	var err error
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("offset") {
+		offset, limit, ok := pageParams(query)
+		if !ok {
+			http.Error(w, "limit and offset must be non-negative integers", http.StatusBadRequest)
+			return
+		}
+		dat, err = g.(*group).codec.Marshal(g.(*group).page(offset, limit))
+	} else {
+		dat, err = g.(*group).marshal()
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("data marshal failed. err=%v", err), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", g.(*group).codec.ContentType())
 	w.WriteHeader(http.StatusOK)
 	w.Write(dat)
 
 }
 
+// defaultPageLimit is the page size of GET /{groupName} when only offset is set.
+const defaultPageLimit = 100
+
+func pageParams(query url.Values) (offset, limit int, ok bool) {
+	limit = defaultPageLimit
+	var err error
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, false
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, 0, false
+		}
+	}
+	return offset, limit, true
+}
+
 func (c *cache) getHandler(w http.ResponseWriter, r *http.Request) {
-	groupName := chi.URLParam(r, "groupName")
-	key := chi.URLParam(r, "key")
+	groupName := urlParam(r, "groupName")
+	key := urlParam(r, "key")
 
 	if groupName == "" || key == "" {
-		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing group name(%s) or key(%s)", groupName, key))
+		c.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing group name(%s) or key(%s)", groupName, key))
 		return
 	}
 
 	g, err := c.getGroupByName(groupName)
 	if err != nil {
-		writeJSONError(w, http.StatusNotFound, err.Error())
+		c.writeMiss(w, r, MissGroupNotFound, err.Error())
+		return
+	}
+
+	// peer fetch: 로컬 cache 만 조회
+	if r.URL.Query().Get("local") == "true" {
+		c.localGetHandler(w, r, g, key)
+		return
+	}
+
+	var val any
+	if r.Header.Get(headerCacheBypass) == "true" {
+		// 비싼 getter 호출을 강제하므로 admin token 이 설정된 경우에만 허용
+		if c.adminToken == "" || !c.isAdmin(r) {
+			c.writeJSONError(w, http.StatusForbidden, "cache bypass requires the admin token")
+			return
+		}
+		val, err = g.Refresh(r.Context(), key)
+	} else {
+		val, err = g.Get(context.Background(), key)
+	}
+	if errors.Is(err, ErrKeyTooLong) {
+		c.writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch {
+	case errors.Is(err, ErrExpired):
+		c.writeMiss(w, r, MissExpired, fmt.Sprintf("cache miss. key '%s' in group name '%s'", key, groupName))
+		return
+	case errors.Is(err, ErrNotFound):
+		c.writeMiss(w, r, MissNotFound, fmt.Sprintf("cache miss. key '%s' in group name '%s'", key, groupName))
+		return
+	case err != nil:
+		// miss 가 아닌 getter 또는 backend 장애
+		status := getErrorStatus(err)
+		if status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "1")
+		}
+		c.writeJSONError(w, status, err.Error())
+		return
+	}
+
+	if s, ok := val.(Stream); ok {
+		c.writeStream(w, s)
+		return
+	}
+
+	if wantsJSON(r) {
+		data, _ := g.entry(g.normalizeKey(key))
+		resp := valueResponse{
+			Key:        key,
+			Value:      val,
+			TTLSeconds: max(time.Until(data.ttlTime), 0).Seconds(),
+			CreatedAt:  data.createdAt,
+			Generation: data.generation,
+		}
+		dat, err := json.Marshal(resp)
+		if err != nil {
+			c.writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("data marshal failed. err=%v", err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(dat)
+		return
+	}
+
+	// Accept 에서 먼저 나온 codec 또는 text/plain, 없으면 group codec
+	codec := g.codec
+	for _, mediaType := range mediaTypes(r.Header.Get("Accept")) {
+		if mediaType == "text/plain" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(fmt.Sprintf("%v", val)))
+			return
+		}
+		if accepted := codecFor(mediaType, g.codecs()); accepted != nil {
+			codec = accepted
+			break
+		}
+	}
+	dat, err := codec.Marshal(val)
+	if err != nil {
+		c.writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("data marshal failed. err=%v", err))
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// getErrorStatus returns the status of a Get that failed for another reason
+// than a miss: 503 while the getter is not called (circuit breaker open, rate
+// limited), 504 when it timed out, and 502 when it failed.
+func getErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrRateLimited):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// localGetHandler serves a peer fetch with the locally cached value encoded by the group codec.
+func (c *cache) localGetHandler(w http.ResponseWriter, r *http.Request, g *group, key string) {
+	// peer 가 보낸 key 는 이미 정규화되어 있음
+	val, err := g.get(context.Background(), key)
+	if err != nil {
+		c.writeJSONError(w, http.StatusNotFound, fmt.Sprintf("cache miss. key '%s' in group name '%s'", key, g.name))
 		return
 	}
 
-	val, err := g.Get(context.Background(), key)
+	// 요청한 peer 가 decode 할 수 있는 codec 으로 응답
+	codec := g.acceptedCodec(r.Header.Get("Accept"))
+	contentType := codec.ContentType()
+	dat, binary, err := g.marshalBinary(val)
+	if binary && slices.Contains(mediaTypes(r.Header.Get("Accept")), binaryContentType) {
+		contentType = binaryContentType
+	} else if err == nil {
+		dat, err = codec.Marshal(val)
+	}
+	if err == nil {
+		dat, err = c.seal(dat)
+	}
 	if err != nil {
-		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("cache miss. key '%s' in group name '%s'", key, groupName))
+		c.writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("data marshal failed. err=%v", err))
 		return
 	}
+
+	if data, ok := g.entry(key); ok {
+		w.Header().Set(headerCacheTTL, time.Until(data.ttlTime).String())
+		w.Header().Set(headerGeneration, strconv.FormatUint(data.generation, 10))
+		w.Header().Set(headerCreatedAt, data.createdAt.Format(time.RFC3339Nano))
+		w.Header().Set(headerSchemaVersion, strconv.Itoa(data.schemaVersion))
+	}
+	w.Header().Set("Content-Type", contentType)
+	c.setEncrypted(w.Header())
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf("%v", val)))
+	w.Write(dat)
+}
+
+// concurrencyLimit rejects requests with 503 while Config.MaxConcurrentRequests
+// requests are being handled, e.g. during a propagation storm.
+func (c *cache) concurrencyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case c.handlerSem <- struct{}{}:
+			defer func() { <-c.handlerSem }()
+		default:
+			w.Header().Set("Retry-After", "1")
+			c.writeJSONError(w, http.StatusServiceUnavailable, "too many concurrent requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// isAdmin reports whether r carries the admin token, or no admin token is configured.
+func (c *cache) isAdmin(r *http.Request) bool {
+	return c.adminToken == "" || r.Header.Get("Authorization") == "Bearer "+c.adminToken
+}
+
+// adminAuth requires "Authorization: Bearer <AdminToken>" when an admin token is configured.
+func (c *cache) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.isAdmin(r) {
+			c.writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// flushHandler clears the group on this node and, unless the request came from a peer, on every peer.
+func (c *cache) flushHandler(w http.ResponseWriter, r *http.Request) {
+	groupName := urlParam(r, "groupName")
+	// 인증 없이 cluster 전체를 비우지 못하도록 admin token 이 없으면 사용할 수 없다
+	if c.adminToken == "" {
+		c.writeJSONError(w, http.StatusForbidden, "flush requires an admin token")
+		return
+	}
+
+	g, err := c.getGroupByName(groupName)
+	if err != nil {
+		c.writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	resp := flushResponse{Group: groupName, Removed: g.flush()}
+	// peer 가 보낸 요청은 다시 전파하지 않는다
+	if r.Header.Get(headerOrigin) == "" {
+		result := c.propagateFlush(groupName)
+		resp.Acknowledged = result.Acknowledged
+		for peer, err := range result.Failed {
+			if resp.Failed == nil {
+				resp.Failed = make(map[string]string)
+			}
+			resp.Failed[peer] = err.Error()
+		}
+	}
+
+	status := http.StatusOK
+	if len(resp.Failed) != 0 {
+		status = http.StatusBadGateway
+	}
+	dat, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+// flushResponse is the body of POST /{groupName}/_flush. The flush is answered
+// with 502 when a peer did not apply it.
+type flushResponse struct {
+	Group string `json:"group"`
+	// 이 node 에서 삭제한 key 수
+	Removed int `json:"removed"`
+	// flush 를 적용한 peer 수와 적용하지 못한 peer 별 오류
+	Acknowledged int               `json:"acknowledged"`
+	Failed       map[string]string `json:"failed,omitempty"`
 }
@@ -0,0 +1,1277 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHTTPCache(pathPrefix string) *cache {
+	c := &cache{
+		group:           make(map[string]*group),
+		pathPrefix:      normalizePathPrefix(pathPrefix),
+		maxRequestBytes: defaultMaxRequestBytes,
+		nodeID:          newNodeID(),
+		logger:          slog.Default(),
+		peerClient:      newPeerClient(defaultMaxIdleConnsPerHost),
+		peerSem:         make(chan struct{}, defaultMaxConcurrentPeerRequests),
+
+		peerFetchStagger: defaultPeerFetchStagger,
+	}
+	c.newHTTPServer(":0")
+	return c
+}
+
+func TestCacheHTTP_PathPrefix(t *testing.T) {
+	c := newTestHTTPCache("/cache/")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	c.group["testGroup"] = g
+
+	assert.Equal(t, "http://peer:4567/cache/testGroup/testKey", c.peerURL("peer:4567", "testGroup", "testKey"))
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/cache/testGroup/testKey", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, g.data, "testKey")
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/testGroup/testKey", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCacheHTTP_GetJSON(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", map[string]any{"name": "test"})
+	c.group["testGroup"] = g
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/testKey?format=json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp valueResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "testKey", resp.Key)
+	assert.Equal(t, map[string]any{"name": "test"}, resp.Value)
+	assert.InDelta(t, time.Minute.Seconds(), resp.TTLSeconds, 1)
+
+	// the value alone, encoded with the group codec
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"name":"test"}`, rec.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, "map[name:test]", rec.Body.String())
+}
+
+func TestCacheHTTP_GetAccept(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.legacyCodecs = []Codec{GobCodec{}}
+	g.Set("testKey", "testValue")
+	c.group["testGroup"] = g
+
+	req := httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil)
+	req.Header.Set("Accept", "application/x-gob, text/plain;q=0.5")
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-gob", rec.Header().Get("Content-Type"))
+	var val string
+	assert.NoError(t, GobCodec{}.Unmarshal(rec.Body.Bytes(), &val))
+	assert.Equal(t, "testValue", val)
+}
+
+func TestCacheHTTP_Miss(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.SetWithTTL("expiredKey", "testValue", -time.Second)
+	c.group["testGroup"] = g
+
+	for path, reason := range map[string]MissReason{
+		"/testGroup/expiredKey": MissExpired,
+		"/testGroup/testKey":    MissNotFound,
+		"/otherGroup/testKey":   MissGroupNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+
+		var resp missResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, reason, resp.Reason, path)
+	}
+
+	c.missStatusCode = http.StatusNoContent
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestCacheHTTP_GetError(t *testing.T) {
+	errDown := errors.New("upstream down")
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		switch key {
+		case "slowKey":
+			<-ctx.Done()
+			return ctx.Err()
+		case "missingKey":
+			return fmt.Errorf("%s %w", key, ErrNotFound)
+		}
+		return errDown
+	}), time.Minute, nil)
+	WithGetterTimeout(10 * time.Millisecond)(g)
+	c.group["testGroup"] = g
+
+	for path, status := range map[string]int{
+		"/testGroup/missingKey": http.StatusNotFound,
+		"/testGroup/failKey":    http.StatusBadGateway,
+		"/testGroup/slowKey":    http.StatusGatewayTimeout,
+	} {
+		rec := httptest.NewRecorder()
+		c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, rec.Code, path)
+	}
+
+	limited := newGroup("limitedGroup", g.getter, time.Minute, nil)
+	WithKeyRateLimit(1, time.Hour)(limited)
+	c.group["limitedGroup"] = limited
+	c.httpServ.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/limitedGroup/failKey", nil))
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/limitedGroup/failKey", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrRateLimited.Error())
+}
+
+func TestCacheHTTP_ErrorBody(t *testing.T) {
+	c := newTestHTTPCache("")
+
+	// quotes in the group name are escaped
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, `/other%22Group/test%22Key`, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	var resp errorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Error, `other"Group`)
+
+	type apiError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Reason  string `json:"reason,omitempty"`
+	}
+	c.errorBody = func(status int, message string, reason MissReason) any {
+		return apiError{Code: status, Message: message, Reason: string(reason)}
+	}
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, `/other%22Group/test%22Key`, nil))
+	var custom apiError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &custom))
+	assert.Equal(t, http.StatusNotFound, custom.Code)
+	assert.Contains(t, custom.Message, `other"Group`)
+	assert.Empty(t, custom.Reason)
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/other%22Group/test%22Key`, nil))
+	custom = apiError{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &custom))
+	assert.Equal(t, string(MissGroupNotFound), custom.Reason)
+}
+
+func TestCacheHTTP_Set(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	c.group["testGroup"] = g
+
+	body, _ := json.Marshal([]setEntry{{Key: "testKey", Value: "testValue", TTL: time.Minute}})
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/testGroup", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	val, ok := g.GetIfPresent("testKey")
+	assert.True(t, ok)
+	assert.Equal(t, "testValue", val)
+}
+
+func TestCacheHTTP_PeerFetch(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peerGroup.SetWithTTL("testKey", "testValue", time.Second*30)
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.peerFetch = func(ctx context.Context, key string) (setEntry, error) {
+		return c.fetchFromPeers(ctx, g, key)
+	}
+
+	val, src, err := g.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", val)
+	assert.Equal(t, PeerHit, src)
+	data, _ := g.entry("testKey")
+	peerData, _ := peerGroup.entry("testKey")
+	assert.InDelta(t, (time.Second * 30).Seconds(), data.ttl.Seconds(), 1)
+	assert.Equal(t, peerData.generation, data.generation)
+
+	_, src, _ = g.GetWithSource(context.Background(), "testKey")
+	assert.Equal(t, LocalHit, src)
+
+	_, _, err = g.GetWithSource(context.Background(), "unknownKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCacheHTTP_MaxRequestBytes(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.maxRequestBytes = 16
+	c.group["testGroup"] = newGroup("testGroup", nil, time.Minute, nil)
+
+	body, _ := json.Marshal([]setEntry{{Key: "testKey", Value: "testValue", TTL: time.Minute}})
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/testGroup", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestCacheHTTP_Flush(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peer.adminToken = "secret"
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peerGroup.Set("testKey", "testValue")
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.adminToken = "secret"
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	c.group["testGroup"] = g
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/testGroup/_flush", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/testGroup/_flush", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, g.data)
+	assert.Empty(t, peerGroup.data)
+
+	var resp flushResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, flushResponse{Group: "testGroup", Removed: 1, Acknowledged: 1}, resp)
+
+	// a peer that does not apply the flush is reported with 502
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	c.peerAddresses = append(c.peerAddresses, strings.TrimPrefix(down.URL, "http://"))
+	g.Set("testKey", "testValue")
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	resp = flushResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Removed)
+	assert.Equal(t, 1, resp.Acknowledged)
+	assert.Len(t, resp.Failed, 1)
+	assert.Contains(t, resp.Failed, strings.TrimPrefix(down.URL, "http://"))
+
+	// the flush is refused without an admin token
+	c.adminToken = ""
+	g.Set("testKey", "testValue")
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/testGroup/_flush", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.NotEmpty(t, g.data)
+}
+
+func TestCacheHTTP_NoPropagationLoop(t *testing.T) {
+	nodes := make([]*cache, 3)
+	servers := make([]*httptest.Server, 3)
+	counts := make([]atomic.Int32, 3)
+	for i := range nodes {
+		nodes[i] = newTestHTTPCache("")
+		nodes[i].adminToken = "secret"
+		g := newGroup("testGroup", nil, time.Minute, nil)
+		g.Set("testKey", "testValue")
+		nodes[i].group["testGroup"] = g
+
+		handler := nodes[i].httpServ.Handler
+		cnt := &counts[i]
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cnt.Add(1)
+			handler.ServeHTTP(w, r)
+		}))
+		defer servers[i].Close()
+	}
+	// ring: 0 -> 1 -> 2 -> 0
+	for i := range nodes {
+		next := servers[(i+1)%len(nodes)]
+		nodes[i].peerAddresses = []string{strings.TrimPrefix(next.URL, "http://")}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, servers[0].URL+"/testGroup/_flush", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	nodes[0].propagateDelete("testGroup", "testKey")
+
+	assert.Equal(t, int32(1), counts[0].Load())
+	assert.Equal(t, int32(2), counts[1].Load())
+	assert.Equal(t, int32(0), counts[2].Load())
+	assert.Empty(t, nodes[1].group["testGroup"].data)
+	assert.NotEmpty(t, nodes[2].group["testGroup"].data)
+
+	// events that came back to their origin or went past the hop limit are ignored
+	req, _ = http.NewRequest(http.MethodDelete, servers[2].URL+"/testGroup/testKey", nil)
+	req.Header.Set(headerOrigin, nodes[2].nodeID)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusLoopDetected, resp.StatusCode)
+
+	req, _ = http.NewRequest(http.MethodDelete, servers[2].URL+"/testGroup/testKey", nil)
+	req.Header.Set(headerOrigin, nodes[0].nodeID)
+	req.Header.Set(headerHops, "2")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusLoopDetected, resp.StatusCode)
+	assert.NotEmpty(t, nodes[2].group["testGroup"].data)
+}
+
+func TestCacheHTTP_DeleteBatch(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peerGroup.SetMulti(map[string]any{"key1": "value1", "key2": "value2", "key3": "value3"})
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, make(chan deleteEvent, 1))
+	g.SetMulti(map[string]any{"key1": "value1", "key2": "value2", "key3": "value3"})
+	c.group["testGroup"] = g
+
+	g.DelBatch([]string{"key1", "key2"})
+	assert.Len(t, g.data, 1)
+
+	event := <-g.deleteChan
+	assert.Equal(t, []string{"key1", "key2"}, event.keys)
+	c.propagateDeleteBatch(event.group, event.keys)
+	assert.Len(t, peerGroup.data, 1)
+	assert.Contains(t, peerGroup.data, "key3")
+}
+
+func TestCacheHTTP_GetGroup(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	c.group["testGroup"] = g
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var views map[string]entryView
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &views))
+	assert.Equal(t, "testValue", views["testKey"].Value)
+	assert.Equal(t, "1m0s", views["testKey"].TTL)
+	assert.False(t, views["testKey"].CreatedAt.IsZero())
+}
+
+func TestCacheHTTP_GetGroupPage(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	for i := range 5 {
+		g.Set(fmt.Sprintf("key%d", i), i)
+	}
+	c.group["testGroup"] = g
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup?offset=1&limit=2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page groupPage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, 5, page.Total)
+	assert.Len(t, page.Entries, 2)
+	assert.Contains(t, page.Entries, "key1")
+	assert.Contains(t, page.Entries, "key2")
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup?offset=10", nil))
+	page = groupPage{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, 5, page.Total)
+	assert.Empty(t, page.Entries)
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup?limit=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCacheHTTP_OnError(t *testing.T) {
+	var errs []error
+	c := newTestHTTPCache("")
+	c.onError = func(err error) { errs = append(errs, err) }
+	c.peerAddresses = []string{"127.0.0.1:1"}
+
+	c.propagateDelete("testGroup", "testKey")
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "peer request DELETE")
+}
+
+func TestCacheHTTP_PeerFetchStagger(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peerGroup.Set("testKey", "testValue")
+	peer.group["testGroup"] = peerGroup
+	fast := httptest.NewServer(peer.httpServ.Handler)
+	defer fast.Close()
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	c := newTestHTTPCache("")
+	c.peerFetchStagger = time.Millisecond * 10
+	c.peerAddresses = []string{strings.TrimPrefix(slow.URL, "http://"), strings.TrimPrefix(fast.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+
+	start := time.Now()
+	entry, err := c.fetchFromPeers(context.Background(), g, "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", entry.Value)
+	assert.Less(t, time.Since(start), time.Second)
+
+	c.peerAddresses = []string{strings.TrimPrefix(fast.URL, "http://")}
+	_, err = c.fetchFromPeers(context.Background(), g, "unknownKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCacheHTTP_PeerConflictPolicy(t *testing.T) {
+	now := time.Now()
+	values := []struct {
+		val        string
+		generation uint64
+		createdAt  time.Time
+	}{
+		{"newestGeneration", 300, now.Add(-time.Hour)},
+		{"newestCreatedAt", 100, now},
+		{"middle", 200, now.Add(-time.Minute)},
+	}
+	c := newTestHTTPCache("")
+	c.peerFetchStagger = time.Minute
+	addrs := make(map[string]string)
+	for _, v := range values {
+		peer := newTestHTTPCache("")
+		peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+		peer.group["testGroup"] = peerGroup
+		server := httptest.NewServer(peer.httpServ.Handler)
+		defer server.Close()
+
+		peerGroup.Set("testKey", v.val)
+		d := peerGroup.data["testKey"]
+		d.generation, d.createdAt = v.generation, v.createdAt
+		peerGroup.data["testKey"] = d
+		addr := strings.TrimPrefix(server.URL, "http://")
+		c.peerAddresses = append(c.peerAddresses, addr)
+		addrs[addr] = v.val
+	}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	owner, _ := c.Owner("testGroup", "testKey")
+
+	for policy, want := range map[PeerConflictPolicy]string{
+		// only the owner is asked within the stagger delay
+		FirstResponse:    addrs[owner],
+		NewestGeneration: "newestGeneration",
+		NewestCreatedAt:  "newestCreatedAt",
+	} {
+		c.peerConflictPolicy = policy
+		entry, err := c.fetchFromPeers(context.Background(), g, "testKey")
+		assert.NoError(t, err, policy.String())
+		assert.Equal(t, want, entry.Value, policy.String())
+	}
+
+	// peers without the key are skipped
+	c.peerConflictPolicy = NewestGeneration
+	_, err := c.fetchFromPeers(context.Background(), g, "unknownKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCacheHTTP_MembershipSettle(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peer.group["testGroup"] = peerGroup
+	holder := httptest.NewServer(peer.httpServ.Handler)
+	defer holder.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	holderAddr, slowAddr := strings.TrimPrefix(holder.URL, "http://"), strings.TrimPrefix(slow.URL, "http://")
+
+	c := newTestHTTPCache("")
+	c.peerFetchStagger = time.Minute
+	c.membershipSettle = time.Minute
+	c.peerAddresses = []string{holderAddr}
+	c.hashRing()
+	// a node joins and becomes the owner of key, which it does not hold yet
+	c.peerAddresses = []string{holderAddr, slowAddr}
+	var key string
+	for i := 0; key == ""; i++ {
+		if owner, _ := c.Owner("testGroup", fmt.Sprintf("key%d", i)); owner == slowAddr {
+			key = fmt.Sprintf("key%d", i)
+		}
+	}
+	peerGroup.Set(key, "value")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	entry, err := c.fetchFromPeers(ctx, g, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", entry.Value)
+
+	// once settled, only the new owner is asked until the stagger delay
+	c.membershipSettle = 0
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.fetchFromPeers(ctx, g, key)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCacheHTTP_DeleteFilter(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.deleteFilter = func(group, key string) bool {
+		return !strings.HasPrefix(key, "owned")
+	}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	c.group["testGroup"] = g
+	for _, key := range []string{"owned1", "owned2", "other1", "other2"} {
+		g.Set(key, "value")
+	}
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/testGroup/owned1", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, g.data, "owned1")
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/testGroup/other1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, g.data, "other1")
+
+	// a batch deletes the allowed keys and reports the refused ones
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/testGroup", strings.NewReader(`["owned1","owned2","other2"]`)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "owned1, owned2")
+	assert.Contains(t, g.data, "owned1")
+	assert.Contains(t, g.data, "owned2")
+	assert.NotContains(t, g.data, "other2")
+}
+
+func TestCacheHTTP_Stream(t *testing.T) {
+	c := newTestHTTPCache("")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	c.group["testGroup"] = g
+	page := strings.Repeat("<p>large page</p>", 10000)
+	var opens int
+	g.Set("page", StreamFunc(func() (io.Reader, error) {
+		opens++
+		return strings.NewReader(page), nil
+	}))
+	g.Set("broken", StreamFunc(func() (io.Reader, error) {
+		return nil, errors.New("file removed")
+	}))
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/page", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+		assert.Equal(t, page, rec.Body.String())
+	}
+	assert.Equal(t, 2, opens)
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/broken", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "file removed")
+}
+
+// binaryPoint encodes itself with MarshalBinary, see WithBinaryValues.
+type binaryPoint struct{ X, Y byte }
+
+func (p *binaryPoint) MarshalBinary() ([]byte, error) {
+	return []byte{p.X, p.Y}, nil
+}
+
+func (p *binaryPoint) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("binaryPoint of %d bytes", len(data))
+	}
+	p.X, p.Y = data[0], data[1]
+	return nil
+}
+
+func newBinaryPoint() encoding.BinaryUnmarshaler {
+	return new(binaryPoint)
+}
+
+func TestCacheHTTP_BinaryValues(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	WithBinaryValues(newBinaryPoint)(peerGroup)
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	WithBinaryValues(newBinaryPoint)(g)
+
+	peerGroup.Set("fetched", &binaryPoint{1, 2})
+	peerGroup.Set("plain", "value")
+	entry, err := c.fetchFromPeer(context.Background(), g, c.peerAddresses[0], "fetched")
+	assert.NoError(t, err)
+	assert.Equal(t, &binaryPoint{1, 2}, entry.Value)
+	entry, err = c.fetchFromPeer(context.Background(), g, c.peerAddresses[0], "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", entry.Value)
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		WithCodec(codec)(g)
+		WithCodec(codec)(peerGroup)
+		entries := []setEntry{{Key: "pushed", Value: &binaryPoint{3, 4}, TTL: time.Minute}}
+		c.pushEntries(context.Background(), g, entries)
+		assert.Equal(t, &binaryPoint{3, 4}, peerGroup.data["pushed"].val, codec.ContentType())
+		// the entries of the caller are left as they are
+		assert.False(t, entries[0].Binary)
+		peerGroup.Del("pushed")
+	}
+	WithCodec(JSONCodec{})(peerGroup)
+
+	// a peer without WithBinaryValues does not request binary values
+	entry, err = c.fetchFromPeer(context.Background(), newGroup("testGroup", nil, time.Minute, nil), c.peerAddresses[0], "fetched")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"X": float64(1), "Y": float64(2)}, entry.Value)
+}
+
+func TestCacheHTTP_Encryption(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+
+	peer := newTestHTTPCache("")
+	peer.encryption, _ = newEncryption(newKey, [][]byte{oldKey})
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	// not rotated yet, still sealing with the old key
+	c := newTestHTTPCache("")
+	c.encryption, _ = newEncryption(oldKey, [][]byte{newKey})
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+
+	peerGroup.Set("fetched", "secret")
+	entry, err := c.fetchFromPeer(context.Background(), g, c.peerAddresses[0], "fetched")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", entry.Value)
+	c.pushEntries(context.Background(), g, []setEntry{{Key: "pushed", Value: "secret", TTL: time.Minute}})
+	val, ok := peerGroup.GetIfPresent("pushed")
+	assert.True(t, ok)
+	assert.Equal(t, "secret", val)
+
+	resp, err := http.Get(server.URL + "/testGroup/fetched?local=true")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, encryptionAESGCM, resp.Header.Get(headerEncrypted))
+	assert.NotContains(t, string(body), "secret")
+
+	// a node without the key can neither read nor push values
+	plain := newTestHTTPCache("")
+	plain.peerAddresses = c.peerAddresses
+	_, err = plain.fetchFromPeer(context.Background(), g, c.peerAddresses[0], "fetched")
+	assert.ErrorIs(t, err, ErrDecrypt)
+	plain.pushEntries(context.Background(), g, []setEntry{{Key: "plain", Value: "value", TTL: time.Minute}})
+	_, ok = peerGroup.GetIfPresent("plain")
+	assert.False(t, ok)
+
+	other := newTestHTTPCache("")
+	other.encryption, _ = newEncryption(bytes.Repeat([]byte{3}, 16), nil)
+	_, err = other.fetchFromPeer(context.Background(), g, c.peerAddresses[0], "fetched")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestCacheHTTP_SpecialCharacters(t *testing.T) {
+	keys := []string{"a/b", "a?b=c", "a#b", "a b", "100%", "/a/b/"}
+
+	peer := newTestHTTPCache("/cache")
+	peerGroup := newGroup("test/group", nil, time.Minute, nil)
+	peer.group["test/group"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("/cache")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("test/group", nil, time.Minute, nil)
+
+	for _, key := range keys {
+		peerGroup.Set(key, "value for "+key)
+		peerGroup.Set("other", "other")
+
+		// peer fetch
+		entry, err := c.fetchFromPeer(context.Background(), g, c.peerAddresses[0], key)
+		if assert.NoError(t, err, key) {
+			assert.Equal(t, "value for "+key, entry.Value)
+		}
+
+		// delete propagation
+		c.propagateDelete("test/group", key)
+		assert.NotContains(t, peerGroup.data, key)
+		assert.Contains(t, peerGroup.data, "other")
+	}
+
+	assert.Equal(t, "http://peer/cache/test%2Fgroup/a%3Fb=c", c.peerURL("peer", "test/group", "a?b=c"))
+	resp, err := http.Get(server.URL + "/cache/test%2Fgroup/other")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCacheHTTP_DeleteWorkers(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peerGroup.SetMulti(map[string]any{"slowKey": "value1", "fastKey": "value2"})
+	peer.group["testGroup"] = peerGroup
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/slowKey") {
+			<-release
+		}
+		peer.httpServ.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newTestHTTPCache("")
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	defer c.cancel()
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	c.deleteChan = make(chan deleteEvent, 2)
+	for range 2 {
+		c.spawn(c.deleteEventWorker)
+	}
+
+	// a slow delete does not hold back the ones queued after it
+	c.deleteChan <- deleteEvent{group: "testGroup", key: "slowKey"}
+	c.deleteChan <- deleteEvent{group: "testGroup", key: "fastKey"}
+	assert.Eventually(t, func() bool {
+		_, ok := peerGroup.GetIfPresent("fastKey")
+		return !ok
+	}, time.Second, time.Millisecond*10)
+	_, ok := peerGroup.GetIfPresent("slowKey")
+	assert.True(t, ok)
+}
+
+func TestCacheHTTP_PeerRequestHeaders(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.peerRequestHeaders = map[string]string{
+		"X-Trace-Id": "trace-1",
+		headerOrigin: "spoofed",
+	}
+
+	req, err := c.newPeerRequest(context.Background(), http.MethodDelete, "http://peer/testGroup/testKey", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "trace-1", req.Header.Get("X-Trace-Id"))
+	assert.Equal(t, c.nodeID, req.Header.Get(headerOrigin))
+}
+
+func TestCacheHTTP_Metrics(t *testing.T) {
+	c := newTestHTTPCache("")
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c.metrics = true
+	c.newHTTPServer(":0")
+	g := newGroup(`test"Group`, nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	g.Get(context.Background(), "testKey")
+	c.group[g.name] = g
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "# TYPE gocache_hits_total counter\n")
+	assert.Contains(t, rec.Body.String(), `gocache_hits_total{group="test\"Group"} 1`+"\n")
+	assert.Contains(t, rec.Body.String(), `gocache_entries{group="test\"Group"} 1`+"\n")
+}
+
+func TestCacheHTTP_Stats(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	c := newTestHTTPCache("")
+	c.adminToken = "secret"
+	c.onError = func(err error) {}
+	downAddr, upAddr := strings.TrimPrefix(down.URL, "http://"), strings.TrimPrefix(up.URL, "http://")
+	c.peerAddresses = []string{downAddr, upAddr}
+	c.propagateDelete("testGroup", "testKey")
+	// a peer that was never contacted
+	c.peerAddresses = append(c.peerAddresses, "10.0.0.1:4567")
+
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	g.Get(context.Background(), "testKey")
+	g.inflight["slowKey"] = 1
+	c.group[g.name] = g
+
+	var resp struct {
+		Groups  map[string]Stats `json:"groups"`
+		Cluster clusterStats     `json:"cluster"`
+	}
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/stats", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1), resp.Groups["testGroup"].Hits)
+	assert.Equal(t, 1, resp.Groups["testGroup"].Entries)
+	assert.Equal(t, 3, resp.Cluster.Peers)
+	assert.Equal(t, 2, resp.Cluster.HealthyPeers)
+	assert.Nil(t, resp.Cluster.PeerHealth)
+	assert.Equal(t, 1, resp.Groups["testGroup"].InFlight)
+	assert.Nil(t, resp.Groups["testGroup"].InFlightKeys)
+
+	req := httptest.NewRequest(http.MethodGet, "/_cache/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, req)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"slowKey"}, resp.Groups["testGroup"].InFlightKeys)
+	assert.Len(t, resp.Cluster.PeerHealth, 3)
+	assert.False(t, resp.Cluster.PeerHealth[0].Healthy)
+	assert.Equal(t, 1, resp.Cluster.PeerHealth[0].ConsecutiveFailures)
+	assert.True(t, resp.Cluster.PeerHealth[1].Healthy)
+	assert.False(t, resp.Cluster.PeerHealth[1].LastSuccess.IsZero())
+}
+
+func TestCacheHTTP_AuditLog(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.audit = newAuditLog(4)
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.audit = c.audit
+	c.group["testGroup"] = g
+
+	g.Set("oldKey", "value")
+	g.Set("localKey", "value")
+	body, _ := json.Marshal([]setEntry{{Key: "peerKey", Value: "value", TTL: time.Minute}})
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/testGroup", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/testGroup/localKey", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	g.SetWithTTL("expiredKey", "value", -time.Second)
+	g.GetIfPresent("expiredKey")
+
+	// the oldest mutations are dropped, newest first
+	var ops []string
+	for _, e := range c.AuditLog() {
+		ops = append(ops, fmt.Sprintf("%s %s %s", e.Op, e.Key, e.Origin))
+	}
+	assert.Equal(t, []string{
+		"expire expiredKey local",
+		"set expiredKey local",
+		"delete localKey 192.0.2.1",
+		"set peerKey 192.0.2.1",
+	}, ops)
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/audit?key=localKey", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var entries []AuditEntry
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "delete", entries[0].Op)
+	assert.Equal(t, "testGroup", entries[0].Group)
+}
+
+func TestCacheHTTP_Ring(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.addr = "self:4567"
+	c.peerAddresses = []string{"b:4567", "a:4567"}
+	c.peerWeight = func(node string) int {
+		if node == "b:4567" {
+			return 2
+		}
+		return 1
+	}
+
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/ring", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var state RingState
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	assert.Equal(t, "self:4567", state.Self)
+	assert.Len(t, state.Nodes, 3)
+	var share float64
+	for i, addr := range []string{"a:4567", "b:4567", "self:4567"} {
+		node := state.Nodes[i]
+		assert.Equal(t, addr, node.Addr)
+		assert.Equal(t, defaultVirtualNodes*node.Weight, node.VirtualNodes)
+		assert.Empty(t, node.Ranges)
+		share += node.Share
+	}
+	assert.InDelta(t, 1, share, 1e-9)
+	assert.Equal(t, 2, state.Nodes[1].Weight)
+	assert.InDelta(t, 0.5, state.Nodes[1].Share, 0.1)
+
+	// every key hash falls in a range of its owner
+	state = c.Ring()
+	ring := c.hashRing()
+	for i := range 100 {
+		key := fmt.Sprintf("key%d", i)
+		h := hashString("testGroup\x00" + key)
+		var owners []string
+		for _, node := range state.Nodes {
+			for _, r := range node.Ranges {
+				if r.Start < r.End && h > r.Start && h <= r.End || r.Start > r.End && (h > r.Start || h <= r.End) {
+					owners = append(owners, node.Addr)
+				}
+			}
+		}
+		assert.Equal(t, []string{ring.owner("testGroup", key)}, owners, key)
+	}
+}
+
+func TestCacheHTTP_Readyz(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.headlessServiceNames = []string{"cache.invalid"}
+	c.servicePeers = make(map[string][]string)
+	readyz := func() (int, readiness) {
+		var resp readiness
+		rec := httptest.NewRecorder()
+		c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/readyz", nil))
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	c.listening.Store(true)
+	c.getCurrentPeers(context.Background())
+	code, resp := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, resp.Ready)
+	assert.True(t, resp.Server.Listening)
+	assert.True(t, resp.Discovery.Enabled)
+	assert.Nil(t, resp.Discovery.LastResolved)
+	assert.Contains(t, resp.Discovery.LastError, "cache.invalid")
+
+	c.headlessServiceNames = []string{"localhost"}
+	c.getCurrentPeers(context.Background())
+	code, resp = readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Ready)
+	assert.NotNil(t, resp.Discovery.LastResolved)
+	assert.Empty(t, resp.Discovery.LastError)
+
+	c.draining.Store(true)
+	code, resp = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, resp.Draining)
+}
+
+func TestCacheHTTP_ClockSkew(t *testing.T) {
+	peer := newTestHTTPCache("")
+	var offset time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the peer clock runs ahead by offset
+		rec := httptest.NewRecorder()
+		peer.httpServ.Handler.ServeHTTP(rec, r)
+		nanos, err := strconv.ParseInt(rec.Header().Get(headerTime), 10, 64)
+		assert.NoError(t, err)
+		w.Header().Set(headerTime, strconv.FormatInt(nanos+int64(offset), 10))
+		w.WriteHeader(rec.Code)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	c := newTestHTTPCache("")
+	c.ctx = context.Background()
+	c.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	c.maxClockSkew = time.Second
+	addr := strings.TrimPrefix(server.URL, "http://")
+	c.peerAddresses = []string{addr}
+
+	c.warmUp(addr)
+	assert.InDelta(t, 0, c.PeerHealth()[0].ClockSkew, float64(100*time.Millisecond))
+	assert.Zero(t, c.PeerHealth()[0].ClockSkewExceeded)
+
+	offset = 5 * time.Second
+	c.warmUp(addr)
+	c.warmUp(addr)
+	assert.InDelta(t, 5*time.Second, c.PeerHealth()[0].ClockSkew, float64(100*time.Millisecond))
+	assert.EqualValues(t, 2, c.PeerHealth()[0].ClockSkewExceeded)
+	// warned once when the skew started exceeding the threshold
+	assert.Equal(t, 1, strings.Count(logs.String(), "clock skew"))
+}
+
+func TestCacheHTTP_WarmUp(t *testing.T) {
+	peer := newTestHTTPCache("")
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		peer.httpServ.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.ctx = context.Background()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	c.peerAddresses = []string{addr}
+	c.warmUp(addr)
+
+	assert.Equal(t, []string{"/_cache/healthz"}, paths)
+	assert.True(t, c.PeerHealth()[0].Healthy)
+	assert.False(t, c.PeerHealth()[0].LastSuccess.IsZero())
+}
+
+func TestCacheHTTP_DelWithResult(t *testing.T) {
+	peer := newTestHTTPCache("")
+	pg := newGroup("testGroup", nil, time.Minute, nil)
+	pg.Set("testKey", "testValue")
+	peer.group["testGroup"] = pg
+	up := httptest.NewServer(peer.httpServ.Handler)
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	// a peer without the group
+	empty := httptest.NewServer(newTestHTTPCache("").httpServ.Handler)
+	defer empty.Close()
+
+	c := newTestHTTPCache("")
+	c.ctx = context.Background()
+	c.onError = func(err error) {}
+	c.deleteChan = make(chan deleteEvent, 1)
+	upAddr, downAddr := strings.TrimPrefix(up.URL, "http://"), strings.TrimPrefix(down.URL, "http://")
+	c.peerAddresses = []string{upAddr, downAddr, strings.TrimPrefix(empty.URL, "http://")}
+	g := c.buildGroup("testGroup", nil, time.Minute)
+	g.Set("testKey", "testValue")
+
+	result := g.DelWithResult("testKey")
+	assert.Equal(t, 2, result.Acknowledged)
+	assert.False(t, result.Complete())
+	assert.Len(t, result.Failed, 1)
+	assert.ErrorContains(t, result.Failed[downAddr], "500")
+	assert.NotContains(t, g.data, "testKey")
+	assert.NotContains(t, pg.data, "testKey")
+
+	// single node
+	assert.True(t, newGroup("testGroup", nil, time.Minute, nil).DelWithResult("testKey").Complete())
+}
+
+func TestCacheHTTP_MaxConcurrentRequests(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.handlerSem = make(chan struct{}, 1)
+	c.newHTTPServer(":0")
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.Set("testKey", "testValue")
+	c.group["testGroup"] = g
+
+	// a request is being handled
+	c.handlerSem <- struct{}{}
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// the probes are not limited
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_cache/readyz", nil))
+	assert.NotContains(t, rec.Body.String(), "too many concurrent requests")
+
+	<-c.handlerSem
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, c.handlerSem)
+}
+
+func TestCacheHTTP_LegacyCodecs(t *testing.T) {
+	// peer is already upgraded to gob, c still uses json
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	WithCodec(GobCodec{})(peerGroup)
+	peerGroup.legacyCodecs = []Codec{JSONCodec{}}
+	peerGroup.Set("testKey", "testValue")
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	g.peerFetch = func(ctx context.Context, key string) (setEntry, error) {
+		return c.fetchFromPeers(ctx, g, key)
+	}
+
+	val, src, err := g.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", val)
+	assert.Equal(t, PeerHit, src)
+
+	// sets propagated by c are decoded with the legacy codec
+	body, _ := JSONCodec{}.Marshal([]setEntry{{Key: "jsonKey", Value: "jsonValue", TTL: time.Minute}})
+	req := httptest.NewRequest(http.MethodPut, "/testGroup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	peer.httpServ.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	val, _ = peerGroup.GetIfPresent("jsonKey")
+	assert.Equal(t, "jsonValue", val)
+
+	// without a content type, the codecs are tried in order
+	body, _ = GobCodec{}.Marshal([]setEntry{{Key: "gobKey", Value: "gobValue", TTL: time.Minute}})
+	rec = httptest.NewRecorder()
+	peer.httpServ.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/testGroup", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	val, _ = peerGroup.GetIfPresent("gobKey")
+	assert.Equal(t, "gobValue", val)
+}
+
+func TestCacheHTTP_DeleteReplicas(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.addr = "node-0"
+	c.peerAddresses = []string{"node-1", "node-2", "node-3", "node-4"}
+	assert.Equal(t, c.peerAddresses, c.deleteTargets("testGroup", "testKey"))
+
+	c.deleteReplicas = 2
+	for i := range 20 {
+		key := fmt.Sprintf("key%d", i)
+		owners := c.hashRing().order("testGroup", key)[:2]
+		targets := c.deleteTargets("testGroup", key)
+		assert.Equal(t, slices.DeleteFunc(owners, func(node string) bool { return node == "node-0" }), targets)
+		assert.NotEmpty(t, targets)
+	}
+}
+
+func TestCacheHTTP_Handoff(t *testing.T) {
+	peer := newTestHTTPCache("")
+	peerGroup := newGroup("testGroup", nil, time.Minute, nil)
+	peer.group["testGroup"] = peerGroup
+	server := httptest.NewServer(peer.httpServ.Handler)
+	defer server.Close()
+
+	c := newTestHTTPCache("")
+	c.peerAddresses = []string{strings.TrimPrefix(server.URL, "http://")}
+	g := newGroup("testGroup", nil, time.Minute, nil)
+	c.group["testGroup"] = g
+	for i := range handoffBatchSize + 1 {
+		g.SetWithTTL(fmt.Sprintf("key%d", i), i, 30*time.Second)
+	}
+	g.SetWithDependencies("derivedKey", "value", "key0")
+	g.SetWithTTL("expiredKey", "value", -time.Second)
+	g.SetMissing("missingKey")
+
+	c.handoff(time.Second)
+	assert.Len(t, peerGroup.data, handoffBatchSize+2)
+	derived, _ := peerGroup.entry("derivedKey")
+	assert.Equal(t, []string{"key0"}, derived.dependsOn)
+	data, _ := peerGroup.entry("key0")
+	want, _ := g.entry("key0")
+	assert.Equal(t, want.generation, data.generation)
+	assert.InDelta(t, (30 * time.Second).Seconds(), data.ttl.Seconds(), 1)
+	assert.NotContains(t, peerGroup.data, "expiredKey")
+	assert.NotContains(t, peerGroup.data, "missingKey")
+}
+
+func TestCacheHTTP_CacheBypass(t *testing.T) {
+	c := newTestHTTPCache("")
+	var calls int
+	g := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		dest.Set(key, fmt.Sprintf("fresh%d", calls))
+		return nil
+	}), time.Minute, nil)
+	g.Set("testKey", "cached")
+	c.group["testGroup"] = g
+
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/testGroup/testKey", nil)
+		req.Header.Set("Accept", "text/plain")
+		req.Header.Set(headerCacheBypass, "true")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req
+	}
+
+	// disabled without an admin token
+	rec := httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, newRequest(""))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	c.adminToken = "secret"
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, newRequest("wrong"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	c.httpServ.Handler.ServeHTTP(rec, newRequest("secret"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "fresh1", rec.Body.String())
+	val, _ := g.GetIfPresent("testKey")
+	assert.Equal(t, "fresh1", val)
+}
@@ -1,8 +1,21 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,3 +35,688 @@ func TestCache_NewGroup(t *testing.T) {
 	group := c.NewGroup("testGroup", getter)
 	assert.NotNil(t, group)
 }
+
+func TestCache_OnChange(t *testing.T) {
+	c := NewCache(&Config{}).(*cache)
+	defer c.Close()
+
+	events := make(chan Op, 2)
+	c.OnChange(func(group, key string, op Op, val any) {
+		assert.Equal(t, "testGroup", group)
+		assert.Equal(t, "testKey", key)
+		events <- op
+	})
+
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		SetWithTTL(dest, key, "value for "+key, time.Millisecond*10)
+		return nil
+	})
+	group := c.NewGroup("testGroup", getter).(*group)
+	group.Get(context.Background(), "testKey")
+	group.ttlCleanUp(time.Now().Add(time.Second))
+
+	for _, want := range []Op{OpSet, OpExpire} {
+		select {
+		case op := <-events:
+			assert.Equal(t, want, op)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s event", want)
+		}
+	}
+}
+
+func TestCache_GetCurrentPeers(t *testing.T) {
+	c := &cache{
+		headlessServiceNames: []string{"localhost", "localhost", "unknown.invalid"},
+		headlessServicePort:  4567,
+		logger:               slog.Default(),
+		servicePeers: map[string][]string{
+			"unknown.invalid": {"10.0.0.1:4567"},
+		},
+	}
+
+	// resolution failure keeps the previous peers of that service
+	peers := c.getCurrentPeers(context.Background())
+	assert.Contains(t, peers, "127.0.0.1:4567")
+	assert.Contains(t, peers, "10.0.0.1:4567")
+	assert.True(t, slices.IsSorted(peers))
+	assert.Equal(t, peers, slices.Compact(slices.Clone(peers)))
+
+	// the mapper rewrites the resolved peers, duplicates are removed afterwards
+	c.peerAddressMapper = func(peers []string) []string {
+		mapped := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			mapped = append(mapped, strings.Replace(peer, ":4567", ":15001", 1), "10.0.0.1:15001")
+		}
+		return mapped
+	}
+	peers = c.getCurrentPeers(context.Background())
+	assert.Contains(t, peers, "127.0.0.1:15001")
+	assert.Equal(t, 1, slices.Index(peers, "127.0.0.1:15001")-slices.Index(peers, "10.0.0.1:15001"))
+	assert.NotContains(t, peers, "10.0.0.1:4567")
+}
+
+func TestCache_GetCurrentPeersMapped(t *testing.T) {
+	c := &cache{
+		ctx:                  context.Background(),
+		headlessServiceNames: []string{"cache-a-headless", "cache-b-headless"},
+		headlessServicePort:  4567,
+		logger:               slog.Default(),
+		servicePeers:         map[string][]string{},
+		lookupHost: func(ctx context.Context, host string) ([]string, error) {
+			if host == "cache-a-headless" {
+				return []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil
+			}
+			return []string{"10.0.0.3", "10.0.0.4", "10.0.1.1"}, nil
+		},
+		localIPs: func() map[string]struct{} {
+			return map[string]struct{}{"10.0.0.1": {}}
+		},
+		// 10.0.1.1 is a second ip of this node's pod, unknown to localIPs
+		peerAddressMapper: func(peers []string) []string {
+			mapped := make([]string, 0, len(peers))
+			for _, peer := range peers {
+				peer = strings.Replace(peer, "10.0.1.1", "10.0.0.1", 1)
+				mapped = append(mapped, strings.Replace(peer, ":4567", ":15001", 1))
+			}
+			return mapped
+		},
+	}
+
+	peers := c.getCurrentPeers(context.Background())
+	assert.Equal(t, []string{"10.0.0.2:15001", "10.0.0.3:15001", "10.0.0.4:15001"}, peers)
+	assert.Equal(t, "10.0.0.1:15001", c.resolvedSelf)
+	assert.Equal(t, []string{"10.0.0.2:4567", "10.0.0.3:4567"}, c.servicePeers["cache-a-headless"])
+}
+
+func TestCache_HeadlessOwnerAgreement(t *testing.T) {
+	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	nodes := make([]*cache, len(ips))
+	for i, ip := range ips {
+		nodes[i] = &cache{
+			ctx:                  context.Background(),
+			addr:                 ":4567",
+			headlessServiceNames: []string{"cache-headless"},
+			headlessServicePort:  4567,
+			logger:               slog.Default(),
+			servicePeers:         map[string][]string{},
+			lookupHost: func(ctx context.Context, host string) ([]string, error) {
+				return ips, nil
+			},
+			localIPs: func() map[string]struct{} {
+				return map[string]struct{}{ip: {}}
+			},
+			peerAddressMapper: func(peers []string) []string {
+				mapped := make([]string, 0, len(peers))
+				for _, peer := range peers {
+					mapped = append(mapped, strings.Replace(peer, ":4567", ":15001", 1))
+				}
+				return mapped
+			},
+		}
+		nodes[i].updatePeers()
+		assert.NotContains(t, nodes[i].peerAddresses, ip+":15001")
+	}
+
+	for k := range 200 {
+		key := fmt.Sprintf("key%d", k)
+		owner, _ := nodes[0].Owner("testGroup", key)
+		assert.Contains(t, []string{"10.0.0.1:15001", "10.0.0.2:15001", "10.0.0.3:15001"}, owner)
+		var owners int
+		for i, node := range nodes {
+			got, self := node.Owner("testGroup", key)
+			assert.Equal(t, owner, got, key)
+			assert.Equal(t, ips[i]+":15001" == owner, self, key)
+			if self {
+				owners++
+			}
+		}
+		assert.Equal(t, 1, owners, key)
+	}
+
+	// an explicit SelfAddr wins over the resolved one
+	nodes[0].configSelf = "cache-0.cache-headless:15001"
+	assert.Equal(t, "cache-0.cache-headless:15001", nodes[0].Ring().Self)
+}
+
+func TestCache_NextCleanupInterval(t *testing.T) {
+	assert.Equal(t, 2*time.Minute, nextCleanupInterval(time.Minute, 100, 0))
+	assert.Equal(t, maxAdaptiveCleanupInterval, nextCleanupInterval(maxAdaptiveCleanupInterval, 0, 0))
+	assert.Equal(t, 30*time.Second, nextCleanupInterval(time.Minute, 100, 50))
+	assert.Equal(t, minAdaptiveCleanupInterval, nextCleanupInterval(minAdaptiveCleanupInterval, 100, 50))
+	assert.Equal(t, time.Minute, nextCleanupInterval(time.Minute, 100, 10))
+}
+
+func TestCache_RegisterCloseHook(t *testing.T) {
+	c := NewCache(&Config{})
+
+	var order []int
+	c.RegisterCloseHook(func() { order = append(order, 1) })
+	c.RegisterCloseHook(func() { order = append(order, 2) })
+	c.Close()
+
+	assert.Equal(t, []int{2, 1}, order)
+
+	// hooks run before the snapshot is written
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c = NewCache(&Config{SnapshotFile: path})
+	g := c.NewGroup("testGroup", nil)
+	c.RegisterCloseHook(func() { g.Set("hookKey", "hookValue") })
+	c.Close()
+	c = NewCache(&Config{SnapshotFile: path})
+	defer c.Close()
+	_, ok := c.NewGroup("testGroup", nil).GetIfPresent("hookKey")
+	assert.True(t, ok)
+}
+
+func TestCache_SnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	// no snapshot yet
+	c := NewCache(&Config{SnapshotFile: path})
+	g := c.NewGroup("testGroup", nil)
+	g.Set("testKey", "testValue")
+	g.SetWithTTL("shortKey", "shortValue", 50*time.Millisecond)
+	c.NewGroup("otherGroup", nil).Set("otherKey", "otherValue")
+	c.Close()
+
+	time.Sleep(60 * time.Millisecond)
+	c = NewCache(&Config{SnapshotFile: path})
+	g = c.NewGroup("testGroup", nil)
+	val, ok := g.GetIfPresent("testKey")
+	assert.True(t, ok)
+	assert.Equal(t, "testValue", val)
+	// expired while the node was down
+	_, ok = g.GetIfPresent("shortKey")
+	assert.False(t, ok)
+	val, ok = c.NewGroup("otherGroup", nil).GetIfPresent("otherKey")
+	assert.True(t, ok)
+	assert.Equal(t, "otherValue", val)
+	// the snapshot is only restored into the first group of the name
+	_, ok = c.NewGroup("testGroup", nil).GetIfPresent("testKey")
+	assert.False(t, ok)
+	c.NewGroup("testGroup", nil).Set("testKey", "testValue")
+	c.Close()
+
+	// groups created with GetGroupOrCreate are restored too
+	c = NewCache(&Config{SnapshotFile: path})
+	val, ok = c.GetGroupOrCreate("testGroup", nil, time.Minute).GetIfPresent("testKey")
+	assert.True(t, ok)
+	assert.Equal(t, "testValue", val)
+	assert.Empty(t, c.(*cache).snapshot["testGroup"])
+	c.Close()
+
+	// a corrupt snapshot starts empty
+	assert.NoError(t, os.WriteFile(path, []byte(`{"group":"testGroup","key":"testKey","value":"testValue","expires_at":"2999-01-01T00:00:00Z"}`+"\n{"), 0o644))
+	c = NewCache(&Config{SnapshotFile: path})
+	defer c.Close()
+	_, ok = c.NewGroup("testGroup", nil).GetIfPresent("testKey")
+	assert.False(t, ok)
+}
+
+func TestCache_SnapshotFileOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewCache(&Config{SnapshotFile: path}).(*cache)
+	defer c.Close()
+	for _, name := range []string{"groupC", "groupA", "groupB"} {
+		g := c.NewGroup(name, nil)
+		for i := range 50 {
+			g.Set(fmt.Sprintf("key%d", 49-i), i)
+		}
+	}
+
+	assert.NoError(t, c.writeSnapshotFile())
+	first, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for range 5 {
+		assert.NoError(t, c.writeSnapshotFile())
+		dat, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(first), string(dat))
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(first)), "\n")
+	assert.Len(t, lines, 150)
+	assert.True(t, strings.HasPrefix(lines[0], `{"group":"groupA","key":"key0",`), lines[0])
+	assert.True(t, strings.HasPrefix(lines[149], `{"group":"groupC","key":"key9",`), lines[149])
+}
+
+func TestCache_SnapshotFileEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	key := bytes.Repeat([]byte{1}, 32)
+
+	c := NewCache(&Config{SnapshotFile: path, EncryptionKey: key})
+	c.NewGroup("testGroup", nil).Set("testKey", "secret")
+	c.Close()
+	dat, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(dat), "secret")
+	assert.NotContains(t, string(dat), "testKey")
+
+	// read with the previous key, written back with the new one
+	c = NewCache(&Config{SnapshotFile: path, EncryptionKey: bytes.Repeat([]byte{2}, 16), DecryptionKeys: [][]byte{key}})
+	val, ok := c.NewGroup("testGroup", nil).GetIfPresent("testKey")
+	assert.True(t, ok)
+	assert.Equal(t, "secret", val)
+	c.Close()
+
+	// without the key the snapshot cannot be read and the cache starts empty
+	c = NewCache(&Config{SnapshotFile: path, EncryptionKey: key})
+	defer c.Close()
+	_, ok = c.NewGroup("testGroup", nil).GetIfPresent("testKey")
+	assert.False(t, ok)
+}
+
+func TestCache_NumGoroutines(t *testing.T) {
+	c := NewCache(&Config{
+		Addr:               "localhost:0",
+		PeerAddresses:      []string{"localhost:1"},
+		PropagateSets:      true,
+		CompactIntervalSec: 60,
+	})
+	workers := c.NumGoroutines()
+	assert.Positive(t, workers)
+
+	// a background refresh blocked in the getter until the cache closes
+	refreshing := make(chan struct{})
+	var calls atomic.Int32
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if calls.Add(1) == 1 {
+			dest.Set(key, "value")
+			return nil
+		}
+		close(refreshing)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	g := c.NewGroupWithTTL("testGroup", getter, time.Minute, WithRefreshAhead(time.Hour))
+	g.Get(context.Background(), "testKey")
+	g.Get(context.Background(), "testKey")
+	<-refreshing
+	assert.Equal(t, workers+1, c.NumGoroutines())
+
+	c.Close()
+	assert.Zero(t, c.NumGoroutines())
+}
+
+func TestCache_GroupCodec(t *testing.T) {
+	c := NewCache(&Config{Codec: GobCodec{}})
+	defer c.Close()
+
+	gobGroup := c.NewGroup("gobGroup", nil).(*group)
+	jsonGroup := c.NewGroup("jsonGroup", nil, WithCodec(JSONCodec{})).(*group)
+
+	assert.Equal(t, GobCodec{}, gobGroup.codec)
+	assert.Equal(t, JSONCodec{}, jsonGroup.codec)
+}
+
+func TestCache_Cleanup(t *testing.T) {
+	c := NewCache(&Config{LazyCleanupOnly: true})
+	defer c.Close()
+
+	group := c.NewGroupWithTTL("testGroup", nil, time.Millisecond*10)
+	group.Set("key1", "value1")
+	group.Set("key2", "value2")
+	group.SetWithTTL("key3", "value3", time.Minute)
+
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 2, c.Cleanup())
+	assert.Equal(t, 0, c.Cleanup())
+
+	// callbacks may use the cache during the sweep
+	c.NewGroupWithTTL("callbackGroup", nil, time.Millisecond*10, WithOnExpire(func(key string, val any) {
+		c.NewGroup("createdOnExpire", nil)
+	})).Set("key1", "value1")
+	time.Sleep(time.Millisecond * 20)
+	done := make(chan int)
+	go func() { done <- c.Cleanup() }()
+	select {
+	case removed := <-done:
+		assert.Equal(t, 1, removed)
+	case <-time.After(time.Second):
+		t.Fatal("cleanup deadlocked")
+	}
+	assert.NotNil(t, c.GetGroup("createdOnExpire"))
+}
+
+func TestCache_ReadOnly(t *testing.T) {
+	c := NewCache(&Config{ReadOnly: true})
+	defer c.Close()
+
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		t.Fatal("getter must not be called on a read-only cache")
+		return nil
+	})
+	group := c.NewGroup("testGroup", getter).(*group)
+
+	_, err := group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// entries pushed by a peer
+	group.setEntries([]setEntry{{Key: "testKey", Value: "testValue", TTL: time.Minute}})
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", val)
+}
+
+func TestCache_GetGroupOK(t *testing.T) {
+	c := NewCache(&Config{})
+	defer c.Close()
+
+	c.NewGroup("testGroup", nil)
+	g, ok := c.GetGroupOK("testGroup")
+	assert.True(t, ok)
+	assert.NotNil(t, g)
+
+	g, ok = c.GetGroupOK("unknownGroup")
+	assert.False(t, ok)
+	assert.Nil(t, g)
+	assert.True(t, c.GetGroup("unknownGroup") == nil)
+}
+
+func TestCache_MaxGroups(t *testing.T) {
+	c := NewCache(&Config{MaxGroups: 2})
+	defer c.Close()
+
+	_, err := c.CreateGroup("group1", nil, time.Minute)
+	assert.NoError(t, err)
+	_, err = c.CreateGroup("group2", nil, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = c.CreateGroup("group3", nil, time.Minute)
+	assert.ErrorIs(t, err, ErrTooManyGroups)
+	assert.Nil(t, c.NewGroup("group3", nil))
+	assert.Equal(t, 2, c.GroupCount())
+
+	// replacing an existing group is allowed
+	_, err = c.CreateGroup("group1", nil, time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestCache_ReservedGroupName(t *testing.T) {
+	c := NewCache(&Config{})
+	defer c.Close()
+
+	for _, name := range []string{"", "_cache", "_flush"} {
+		_, err := c.CreateGroup(name, nil, time.Minute)
+		assert.ErrorIs(t, err, ErrReservedGroupName, name)
+	}
+	_, err := c.CreateGroup("metrics", nil, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.GroupCount())
+}
+
+func TestCache_Owner(t *testing.T) {
+	single := &cache{addr: "node-0"}
+	owner, local := single.Owner("testGroup", "testKey")
+	assert.Equal(t, "node-0", owner)
+	assert.True(t, local)
+
+	peers := []string{"node-0", "node-1", "node-2"}
+	nodes := make([]*cache, len(peers))
+	for i, addr := range peers {
+		nodes[i] = &cache{addr: addr, peerAddresses: slices.DeleteFunc(slices.Clone(peers), func(p string) bool { return p == addr })}
+	}
+
+	counts := make(map[string]int)
+	for i := range 3000 {
+		key := fmt.Sprintf("key%d", i)
+		owner, _ := nodes[0].Owner("testGroup", key)
+		counts[owner]++
+
+		// every node agrees on the owner, and exactly one of them is local
+		locals := 0
+		for _, n := range nodes {
+			o, local := n.Owner("testGroup", key)
+			assert.Equal(t, owner, o)
+			if local {
+				locals++
+			}
+		}
+		assert.Equal(t, 1, locals)
+	}
+	for _, addr := range peers {
+		assert.InDelta(t, 1000, counts[addr], 300, addr)
+	}
+
+	// a node leaving only moves its own keys
+	nodes[1].peerAddresses = []string{"node-2"}
+	for i := range 3000 {
+		key := fmt.Sprintf("key%d", i)
+		before, _ := nodes[0].Owner("testGroup", key)
+		after, _ := nodes[1].Owner("testGroup", key)
+		if before != "node-0" {
+			assert.Equal(t, before, after)
+		}
+	}
+}
+
+func TestCache_PeerWeight(t *testing.T) {
+	c := &cache{
+		addr:          "node-0",
+		peerAddresses: []string{"node-1", "node-2"},
+		peerWeight: func(addr string) int {
+			if addr == "node-0" {
+				return 2
+			}
+			return 1
+		},
+	}
+	counts := make(map[string]int)
+	for i := range 4000 {
+		owner, _ := c.Owner("testGroup", fmt.Sprintf("key%d", i))
+		counts[owner]++
+	}
+	assert.InDelta(t, 2000, counts["node-0"], 300)
+	assert.InDelta(t, 1000, counts["node-1"], 300)
+	assert.InDelta(t, 1000, counts["node-2"], 300)
+}
+
+func TestCache_CompactGroups(t *testing.T) {
+	c := &cache{group: make(map[string]*group), compactRatio: 0.5}
+	churned := newGroup("churned", nil, time.Minute, nil)
+	tuned := newGroup("tuned", nil, time.Minute, nil)
+	WithAutoCompact(0.1)(tuned)
+	c.group["churned"], c.group["tuned"] = churned, tuned
+
+	keys := make([]string, 0, 2000)
+	for i := range 2000 {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+		churned.Set(keys[i], i)
+		tuned.Set(keys[i], i)
+	}
+	churned.DelBatch(keys[800:])
+	tuned.DelBatch(keys[800:])
+
+	c.compactGroups()
+	assert.Equal(t, 800, churned.peakEntries)
+	assert.Equal(t, 2000, tuned.peakEntries)
+}
+
+func TestCache_MaxTotalBytes(t *testing.T) {
+	c := &cache{group: make(map[string]*group), ctx: context.Background(), codec: JSONCodec{}}
+	c.budget = &byteBudget{max: 1000, groups: c.groups}
+	large := c.buildGroup("large", nil, time.Minute)
+	small := c.buildGroup("small", nil, time.Minute, WithSizer(func(key string, val any) int64 { return 100 }))
+	c.group["large"], c.group["small"] = large, small
+
+	for i := range 3 {
+		small.Set(fmt.Sprintf("key%d", i), i)
+	}
+	large.Set("old", strings.Repeat("a", 300))
+	large.Set("new", strings.Repeat("b", 300))
+	large.Get(context.Background(), "old")
+	assert.Equal(t, int64(300), small.Stats().Bytes)
+	assert.Equal(t, int64(606), large.Stats().Bytes)
+
+	// the least recently used entry of the group holding the most bytes is evicted
+	large.Set("newest", strings.Repeat("c", 300))
+	assert.LessOrEqual(t, c.budget.used.Load(), int64(1000))
+	assert.NotContains(t, large.data, "new")
+	assert.Contains(t, large.data, "old")
+	assert.Len(t, small.data, 3)
+	assert.Equal(t, int64(1), large.Stats().Evictions)
+
+	large.Del("old")
+	assert.Equal(t, int64(306), large.Stats().Bytes)
+	assert.Equal(t, int64(606), c.budget.used.Load())
+}
+
+func TestCache_Webhook(t *testing.T) {
+	var attempts atomic.Int32
+	events := make(chan webhookEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt fails and is retried
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var event webhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer server.Close()
+
+	c := NewCache(&Config{WebhookURL: server.URL})
+	defer c.Close()
+	g := c.NewGroup("testGroup", nil)
+	g.Set("testKey", "testValue")
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "testGroup", event.Group)
+		assert.Equal(t, "testKey", event.Key)
+		assert.Equal(t, "set", event.Op)
+		assert.False(t, event.Timestamp.IsZero())
+	case <-time.After(time.Second):
+		t.Fatal("expected webhook event")
+	}
+}
+
+func TestCache_NewGroupWithGetters(t *testing.T) {
+	c := NewCache(&Config{})
+	defer c.Close()
+
+	errReplica := errors.New("replica unavailable")
+	var calls []string
+	group := c.NewGroupWithGetters("testGroup",
+		GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			calls = append(calls, "replica")
+			return errReplica
+		}),
+		GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			calls = append(calls, "empty")
+			return nil
+		}),
+		GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			calls = append(calls, "source")
+			if key == "unknown" {
+				return ErrNotFound
+			}
+			dest.Set(key, "value for "+key)
+			return nil
+		}),
+	)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, []string{"replica", "empty", "source"}, calls)
+
+	_, err = group.Get(context.Background(), "unknown")
+	assert.ErrorIs(t, err, errReplica)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCache_GetGroupOrCreate(t *testing.T) {
+	c := NewCache(&Config{})
+	defer c.Close()
+
+	groups := make(chan Group, 10)
+	for range 10 {
+		go func() {
+			groups <- c.GetGroupOrCreate("testGroup", nil, time.Minute)
+		}()
+	}
+	first := <-groups
+	assert.NotNil(t, first)
+	for range 9 {
+		assert.Same(t, first, <-groups)
+	}
+	assert.Same(t, first, c.GetGroup("testGroup"))
+	assert.Nil(t, c.GetGroupOrCreate("_reserved", nil, time.Minute))
+}
+
+func TestCache_PauseDiscovery(t *testing.T) {
+	c := &cache{
+		ctx:                  context.Background(),
+		headlessServiceNames: []string{"localhost"},
+		headlessServicePort:  4567,
+		logger:               slog.Default(),
+		peerAddresses:        []string{"10.0.0.1:4567"},
+		servicePeers:         map[string][]string{},
+	}
+
+	c.PauseDiscovery()
+	c.updatePeers()
+	assert.Equal(t, []string{"10.0.0.1:4567"}, c.peerAddresses)
+
+	c.ResumeDiscovery()
+	c.updatePeers()
+	assert.Contains(t, c.peerAddresses, "127.0.0.1:4567")
+	assert.NotContains(t, c.peerAddresses, "10.0.0.1:4567")
+}
+
+func TestCache_MaintenanceMode(t *testing.T) {
+	c := NewCache(&Config{})
+	defer c.Close()
+
+	var calls int
+	group := c.NewGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		dest.Set(key, "value for "+key)
+		return nil
+	}))
+	group.Set("cachedKey", "cachedValue")
+
+	c.SetMaintenanceMode(true)
+	assert.True(t, c.MaintenanceMode())
+	val, err := group.Get(context.Background(), "cachedKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "cachedValue", val)
+	_, err = group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = group.Refresh(context.Background(), "cachedKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, int64(1), group.Stats().MaintenanceMisses)
+
+	c.SetMaintenanceMode(false)
+	val, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_UnixNetwork(t *testing.T) {
+	dir := t.TempDir()
+	sock1, sock2 := filepath.Join(dir, "node1.sock"), filepath.Join(dir, "node2.sock")
+	c1 := NewCache(&Config{Network: "unix", Addr: sock1, PeerAddresses: []string{sock2}})
+	defer c1.Close()
+	c2 := NewCache(&Config{Network: "unix", Addr: sock2, PeerAddresses: []string{sock1}})
+	defer c2.Close()
+
+	assert.Equal(t, sock2, peerOfHost(unixHost(sock2)))
+
+	g1 := c1.NewGroup("testGroup", nil)
+	g2 := c2.NewGroup("testGroup", nil)
+	g2.Set("testKey", "testValue")
+
+	assert.Eventually(t, func() bool {
+		return g1.DelWithResult("testKey").Complete()
+	}, time.Second, 10*time.Millisecond)
+	_, ok := g2.GetIfPresent("testKey")
+	assert.False(t, ok)
+	assert.True(t, c1.PeerHealth()[0].Healthy)
+	assert.Equal(t, sock2, c1.PeerHealth()[0].Addr)
+}
@@ -0,0 +1,116 @@
+// Package cachetest runs a cluster of go-cache nodes in one process for tests.
+// The nodes talk to each other through an in-process Transport, so no port is
+// opened and no timing hack is needed.
+//
+//	cluster := cachetest.NewCluster(3, cache.Config{PropagateSets: true})
+//	defer cluster.Close()
+//
+//	groups := cluster.NewGroup("users", getter)
+//	groups[0].Del("user-1")
+//	cluster.AssertAbsent(t, "users", "user-1")
+package cachetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/winey-dev/go-cache"
+)
+
+// DefaultTimeout is how long the assertions wait for the nodes to converge.
+var DefaultTimeout = time.Second
+
+type Cluster struct {
+	Nodes []cache.Cache
+	// Addrs[i] is the address of Nodes[i] on the transport
+	Addrs     []string
+	Transport *Transport
+}
+
+// NewCluster starts n nodes configured with config, each with every other node as a static peer.
+func NewCluster(n int, config cache.Config) *Cluster {
+	cluster := &Cluster{Transport: NewTransport()}
+	for i := range n {
+		cluster.Addrs = append(cluster.Addrs, fmt.Sprintf("node-%d", i))
+	}
+
+	for _, addr := range cluster.Addrs {
+		config := config
+		config.Addr = addr
+		config.PeerAddresses = cluster.Addrs
+		config.HeadlessServiceName = ""
+		config.HeadlessServiceNames = nil
+		config.PeerTransport = cluster.Transport
+		cluster.Nodes = append(cluster.Nodes, cache.NewCache(&config))
+	}
+	return cluster
+}
+
+// NewGroup creates the group on every node and returns them in node order.
+func (cl *Cluster) NewGroup(name string, getter cache.Getter, opts ...cache.GroupOption) []cache.Group {
+	groups := make([]cache.Group, len(cl.Nodes))
+	for i, node := range cl.Nodes {
+		groups[i] = node.NewGroup(name, getter, opts...)
+	}
+	return groups
+}
+
+func (cl *Cluster) Close() {
+	for _, node := range cl.Nodes {
+		node.Close()
+	}
+}
+
+// AssertConsistent waits until every node holds want for key in its local cache.
+// It never calls the getter.
+func (cl *Cluster) AssertConsistent(t testing.TB, group, key string, want any) bool {
+	t.Helper()
+	return cl.eventually(t, group, key, func(val any, ok bool) bool {
+		return ok && reflect.DeepEqual(val, want)
+	}, fmt.Sprintf("want %v", want))
+}
+
+// AssertAbsent waits until no node holds key in its local cache.
+func (cl *Cluster) AssertAbsent(t testing.TB, group, key string) bool {
+	t.Helper()
+	return cl.eventually(t, group, key, func(val any, ok bool) bool {
+		return !ok
+	}, "want absent")
+}
+
+func (cl *Cluster) eventually(t testing.TB, group, key string, cond func(val any, ok bool) bool, msg string) bool {
+	t.Helper()
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		node, val, ok := cl.firstMismatch(group, key, cond)
+		if node < 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			if ok {
+				t.Errorf("cachetest: %s/%s on %s is %v, %s", group, key, cl.Addrs[node], val, msg)
+			} else {
+				t.Errorf("cachetest: %s/%s on %s is absent, %s", group, key, cl.Addrs[node], msg)
+			}
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// firstMismatch returns the index of the first node whose value does not satisfy cond, or -1.
+func (cl *Cluster) firstMismatch(group, key string, cond func(val any, ok bool) bool) (int, any, bool) {
+	for i, node := range cl.Nodes {
+		var val any
+		var ok bool
+		if g, found := node.GetGroupOK(group); found {
+			val, ok = g.GetIfPresent(key)
+		}
+		if !cond(val, ok) {
+			return i, val, ok
+		}
+	}
+	return -1, nil, false
+}
@@ -0,0 +1,24 @@
+package cachetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/winey-dev/go-cache"
+)
+
+func TestCluster(t *testing.T) {
+	cluster := NewCluster(3, cache.Config{PropagateSets: true})
+	defer cluster.Close()
+
+	groups := cluster.NewGroup("testGroup", nil)
+	groups[0].Set("testKey", "testValue")
+	cluster.AssertConsistent(t, "testGroup", "testKey", "testValue")
+
+	groups[1].Del("testKey")
+	cluster.AssertAbsent(t, "testGroup", "testKey")
+
+	_, err := groups[2].Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
@@ -0,0 +1,53 @@
+package cachetest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Transport is an in-process cache.PeerTransport. Requests are served directly
+// by the handler registered at the request host, without opening any port.
+type Transport struct {
+	mtx      sync.RWMutex
+	handlers map[string]http.Handler
+}
+
+func NewTransport() *Transport {
+	return &Transport{handlers: make(map[string]http.Handler)}
+}
+
+func (t *Transport) Register(addr string, handler http.Handler) func() {
+	t.mtx.Lock()
+	t.handlers[addr] = handler
+	t.mtx.Unlock()
+
+	return func() {
+		t.mtx.Lock()
+		delete(t.handlers, addr)
+		t.mtx.Unlock()
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mtx.RLock()
+	handler, ok := t.handlers[req.URL.Host]
+	t.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("cachetest: no node at %s", req.URL.Host)
+	}
+
+	// server 에서 받은 요청처럼 보이도록 복사
+	sreq := req.Clone(req.Context())
+	sreq.RequestURI = req.URL.RequestURI()
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, sreq)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// Codec serializes values exchanged over HTTP.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default codec.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob.
+// Concrete types stored behind interface values must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// codecFor returns the codec of codecs with the given media type, or nil.
+func codecFor(mediaType string, codecs []Codec) Codec {
+	for _, codec := range codecs {
+		if codec.ContentType() == mediaType {
+			return codec
+		}
+	}
+	return nil
+}
+
+// mediaTypes returns the media types listed in a Content-Type or Accept header, without parameters.
+func mediaTypes(header string) []string {
+	var types []string
+	for _, v := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v)); err == nil {
+			types = append(types, mediaType)
+		}
+	}
+	return types
+}
+
+// codecs returns the group codec followed by the legacy codecs it still reads.
+func (g *group) codecs() []Codec {
+	return append([]Codec{g.codec}, g.legacyCodecs...)
+}
+
+// unmarshal decodes data received from a peer with the codec named by its
+// Content-Type. When the content type is unknown or missing, every codec is
+// tried in order.
+func (g *group) unmarshal(contentType string, data []byte, v any) error {
+	codecs := g.codecs()
+	for _, mediaType := range mediaTypes(contentType) {
+		if codec := codecFor(mediaType, codecs); codec != nil {
+			return codec.Unmarshal(data, v)
+		}
+	}
+	var errs []error
+	for _, codec := range codecs {
+		err := codec.Unmarshal(data, v)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// acceptedCodec returns the first codec of the group listed in an Accept header,
+// or the group codec.
+func (g *group) acceptedCodec(accept string) Codec {
+	codecs := g.codecs()
+	for _, mediaType := range mediaTypes(accept) {
+		if codec := codecFor(mediaType, codecs); codec != nil {
+			return codec
+		}
+	}
+	return g.codec
+}
+
+// binaryContentType is the media type of a value sent as the bytes of its
+// MarshalBinary, see WithBinaryValues.
+const binaryContentType = "application/x-gocache-binary"
+
+// WithBinaryValues sends the values implementing encoding.BinaryMarshaler to the
+// peers, and writes them in SnapshotTo, as the bytes of their MarshalBinary
+// instead of encoding them with the group codec. The bytes are decoded into the
+// value returned by newValue, which is then stored, e.g.
+// func() encoding.BinaryUnmarshaler { return new(User) } for a group of *User.
+// Other values still use the codec. Every node must create the group with it,
+// as a node without it cannot decode the values.
+func WithBinaryValues(newValue func() encoding.BinaryUnmarshaler) GroupOption {
+	return func(g *group) {
+		g.newBinaryValue = newValue
+	}
+}
+
+// marshalBinary returns the MarshalBinary of val, and false when val does not
+// take the binary path.
+func (g *group) marshalBinary(val any) ([]byte, bool, error) {
+	m, ok := val.(encoding.BinaryMarshaler)
+	if g.newBinaryValue == nil || !ok {
+		return nil, false, nil
+	}
+	dat, err := m.MarshalBinary()
+	return dat, true, err
+}
+
+func (g *group) unmarshalBinary(dat []byte) (any, error) {
+	if g.newBinaryValue == nil {
+		return nil, fmt.Errorf("binary value in group %s without WithBinaryValues", g.name)
+	}
+	v := g.newBinaryValue()
+	if err := v.UnmarshalBinary(dat); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// binaryBytes returns the bytes of a binary value decoded by a codec: gob keeps
+// them as []byte, JSON as a base64 string.
+func binaryBytes(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return nil, fmt.Errorf("binary value of type %T", v)
+}
+
+// encodeBinaryEntries returns entries with the values taking the binary path
+// replaced by their MarshalBinary. entries is not modified.
+func (g *group) encodeBinaryEntries(entries []setEntry) ([]setEntry, error) {
+	if g.newBinaryValue == nil {
+		return entries, nil
+	}
+	encoded := slices.Clone(entries)
+	for i, e := range encoded {
+		dat, ok, err := g.marshalBinary(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Key, err)
+		}
+		if ok {
+			encoded[i].Value, encoded[i].Binary = dat, true
+		}
+	}
+	return encoded, nil
+}
+
+// decodeBinaryEntries decodes in place the binary values of entries received from a peer.
+func (g *group) decodeBinaryEntries(entries []setEntry) error {
+	for i, e := range entries {
+		if !e.Binary {
+			continue
+		}
+		dat, err := binaryBytes(e.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Key, err)
+		}
+		if entries[i].Value, err = g.unmarshalBinary(dat); err != nil {
+			return fmt.Errorf("%s: %w", e.Key, err)
+		}
+		entries[i].Binary = false
+	}
+	return nil
+}
@@ -1,17 +1,244 @@
 package cache
 
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
 type Config struct {
-	// localhost:8080
+	// localhost:8080, or the socket path with Network "unix"
 	Addr string
+	// "tcp" by default, or "unix" for sidecar deployments: Addr and PeerAddresses
+	// are then unix socket paths, e.g. /var/run/cache.sock
+	Network string
 	// localhost:8081, localhost:8082
 	PeerAddresses []string //
 
 	// service-headless.namespace
 	HeadlessServiceName string //
+	// service-a-headless.namespace, service-b-headless.namespace
+	// peers resolved from every service are merged
+	HeadlessServiceNames []string
 
 	// 4567
 	HeadlessServicePort int //
+	// rewrites the peers resolved from the headless services ("ip:port") before
+	// they are used, e.g. to reach each pod through a sidecar port. the result
+	// is deduplicated. the addresses of this node's own ips are dropped before,
+	// but its own address on the hash ring (see SelfAddr) is mapped the same way,
+	// so that every node labels it alike, and dropped from the mapped peers
+	PeerAddressMapper func(peers []string) []string
+	// address of this node as the other nodes list it, which labels this node on
+	// the hash ring (Cache.Owner, PeerFetch, DeleteReplicas). by default Addr with
+	// static peers, and with headless services the address resolved for the pod
+	// ip of this node, "ip:HeadlessServicePort" passed through PeerAddressMapper
+	SelfAddr string
+
+	// run without peers: no http server, no discovery and no delete propagation.
+	// a cache without headless services, and without both Addr and PeerAddresses,
+	// also runs as a single node; SingleNode makes it explicit, and
+	// NewCacheWithOptions rejects it together with peers
+	SingleNode bool
 
+	// /cache
+	// prefix of the cache http routes when mounted behind a reverse proxy subpath
+	PathPrefix string
+
+	// when CacheCleanupIntervalSec is not set, the cleanup interval adapts to
+	// how many expired entries each sweep removes.
 	CacheCleanupIntervalSec         int
 	HeadlessServiceWatchIntervalSec int
+
+	// size of the queue of deletes waiting to be propagated to peers, 1024 by default
+	DeleteQueueSize int
+	// goroutines propagating queued deletes concurrently, 4 by default.
+	// deletes are not propagated in the order they were queued
+	DeleteWorkers int
+	// send each delete only to the first DeleteReplicas nodes of the key on the
+	// consistent hash ring (its owner and replicas, see Cache.Owner) instead of to
+	// every peer, all peers when 0. the other nodes keep their copy of the key
+	// until it expires, so only use it when reads are served by the owners
+	// (e.g. PeerFetch with short ttls) or stale copies are acceptable until the ttl
+	DeleteReplicas int
+	// weight of a node on the consistent hash ring (see Cache.Owner), 1 when nil.
+	// a node of weight 2 owns about twice as many keys as a node of weight 1, e.g.
+	// for nodes with more memory. it is called with the address of every node,
+	// this one included, and must return the same weights on every node
+	PeerWeight func(addr string) int
+
+	// chooses the peers each delete is sent to and the order of the peers asked
+	// on a peer fetch, e.g. HashRingSelector or ZoneSelector. by default deletes
+	// are sent as set by DeleteReplicas and peer fetches follow the hash ring
+	PeerSelector PeerSelector
+	// when the delete queue is full, Del drops the propagation instead of waiting for room
+	DropDeletesWhenFull bool
+
+	// serve the peer http server and call the peers over https. the server uses
+	// Certificates (or GetCertificate) and ClientAuth/ClientCAs, the peer client
+	// uses RootCAs and Certificates as its client certificate
+	TLSConfig *tls.Config
+
+	// carries peer requests instead of http over the network, see PeerTransport
+	PeerTransport PeerTransport
+
+	// open a connection to each peer that appears in the headless services
+	// (GET /_cache/healthz), so that the first delete propagated to it does not
+	// wait for the TCP and TLS handshakes
+	WarmUpPeers bool
+
+	// idle connections kept per peer, 4 by default
+	MaxIdleConnsPerHost int
+	// requests sent to peers at the same time, 16 by default
+	MaxConcurrentPeerRequests int
+	// requests handled by the http server at the same time, unlimited when 0.
+	// requests beyond the limit are rejected with 503 instead of waiting.
+	// /_cache/healthz and /_cache/readyz are not limited
+	MaxConcurrentRequests int
+	// headers added to every request sent to peers (delete, set, fetch, flush),
+	// e.g. {"Authorization": "Bearer ..."} when the peers sit behind a proxy
+	PeerRequestHeaders map[string]string
+
+	// push locally set values to peers (PUT /{groupName}).
+	// values are encoded with the group codec, so peers may receive a
+	// different concrete type than the one that was set (e.g. JSONCodec decodes structs to maps)
+	PropagateSets bool
+
+	// serve the per-group Stats in the Prometheus text format on GET /_cache/metrics
+	// of the peer http server (Addr)
+	Metrics bool
+
+	// on Close, push the unexpired entries of every group to the peers (as with
+	// PropagateSets) for at most this long, so that a rolling deploy keeps them warm.
+	// peers keep a newer value they already have. disabled when 0 and on ReadOnly nodes
+	HandoffTimeoutSec int
+
+	// path of a snapshot of every group, loaded by NewCache and written on Close,
+	// so that a restarting node comes up warm. the entries of a group are
+	// restored when the group is created; expired entries are skipped. a missing
+	// or corrupt file is logged and the cache starts empty. entries are written
+	// sorted by group name and key, so that snapshots can be diffed. disabled when empty
+	SnapshotFile string
+
+	// AES key (16, 24 or 32 bytes) encrypting with AES-GCM the values sent to
+	// peers (pushed sets, peer fetches and the handoff on Close) and the entries
+	// written to SnapshotFile. values stay plaintext in memory, and the http api
+	// (GET /{groupName}/{key}) still returns them in clear. every node needs the
+	// same key: nodes refuse the plaintext and the values they cannot decrypt.
+	// disabled when empty
+	EncryptionKey []byte
+	// previous keys still accepted when decrypting, to rotate EncryptionKey:
+	// first roll out {EncryptionKey: old, DecryptionKeys: new} to every node,
+	// then {EncryptionKey: new, DecryptionKeys: old}, and drop the old key once
+	// every node has been restarted, rewriting its SnapshotFile with the new key
+	DecryptionKeys [][]byte
+
+	// bearer token required by the admin endpoints (POST /{groupName}/_flush).
+	// the admin endpoints are not protected when empty, except for the flush,
+	// which is refused with 403 without an admin token
+	AdminToken string
+
+	// called for each key of a delete received from a peer (DELETE /{groupName}/{key}
+	// and the batch delete). returning false keeps the key, e.g. on the node that
+	// is authoritative for it, and the delete is answered with 409
+	DeleteFilter func(group, key string) bool
+
+	// maximum size of the body accepted by the write endpoints, 10MiB by default.
+	// larger requests are rejected with 413
+	MaxRequestBytes int64
+
+	// status code of GET /{groupName}/{key} on a miss, 404 by default.
+	// the JSON body carries the MissReason, except for 204 which has no body
+	MissStatusCode int
+	// returns the JSON body of the error responses of the http server, e.g. to
+	// match the error schema of an API gateway. reason is set for the misses of
+	// GET /{groupName}/{key}. {"error": message} (with "reason" on misses) when nil
+	ErrorBody func(status int, message string, reason MissReason) any
+	// writes the whole response of GET /{groupName}/{key} on a miss instead,
+	// MissStatusCode is ignored when set
+	MissHandler func(w http.ResponseWriter, r *http.Request, reason MissReason)
+
+	// on a local miss, ask the peers for their cached value before calling the getter
+	PeerFetch bool
+	// delay before the remaining peers are asked when the most likely owner
+	// has not answered a peer fetch, 50ms by default
+	PeerFetchStaggerMs int
+	// which value a peer fetch returns when the peers hold different values,
+	// FirstResponse by default. the other policies ask every peer at once and
+	// wait for all of them (or the peer request timeout), trading the latency of
+	// a miss for a result that does not depend on which peer answers first
+	PeerConflictPolicy PeerConflictPolicy
+	// for this long after the peers change, a peer fetch asks the previous owner
+	// of the key on the hash ring together with the current one, as the previous
+	// owner holds the keys the new one has not filled yet. disabled when 0.
+	// it smooths the misses of a scaling event, not the consistency: both owners
+	// may still hold different values until they expire
+	MembershipSettleSec int
+	// estimate the clock offset of each peer from the time it sends with its
+	// responses, reported as PeerHealth.ClockSkew, and warn when it exceeds this
+	// many milliseconds. ttls are sent as durations, but tombstones and the
+	// generations resolving conflicting sets compare clocks across nodes.
+	// disabled when 0
+	MaxClockSkewMs int
+
+	// record the last this many mutations of every group (set, delete, expiry,
+	// eviction) with their time and origin, this node or the peer that sent them,
+	// served on GET /_cache/audit and by Cache.AuditLog. each mutation then
+	// takes a shared lock. disabled when 0
+	AuditLogSize int
+
+	// maximum number of groups, unlimited when 0.
+	// a safety valve for applications that derive group names from user input
+	MaxGroups int
+
+	// bytes all groups may hold together, unlimited when 0. when a write goes
+	// over it, entries are evicted from the group holding the most bytes by its
+	// eviction policy. entries are measured with WithSizer, or their length
+	// (strings, byte slices) or codec encoding otherwise
+	MaxTotalBytes int64
+
+	// read replica: local misses return ErrNotFound instead of calling the getter,
+	// values pushed by peers (PropagateSets) still populate the cache
+	ReadOnly bool
+
+	// codec of the values exchanged over http, JSONCodec by default.
+	// can be overridden per group with WithCodec
+	Codec Codec
+	// codecs still accepted from peers, to switch Codec without a full flush.
+	// values are decoded with the codec named by their Content-Type, and peer
+	// fetches are answered with a codec the requesting peer accepts.
+	// e.g. first roll out {Codec: JSONCodec, LegacyCodecs: GobCodec} to every node,
+	// then {Codec: GobCodec, LegacyCodecs: JSONCodec}
+	LegacyCodecs []Codec
+
+	// POST every local Set, Delete, Expire and Evict as JSON
+	// ({"group", "key", "op", "timestamp"}) to this url, with retries.
+	// events are dropped when WebhookQueueSize (1024 by default) events are pending
+	WebhookURL       string
+	WebhookQueueSize int
+
+	// called when a background task hits a non-fatal error
+	// (headless service resolution, peer request, http server).
+	// errors are logged with Logger when not set
+	OnError func(err error)
+
+	// slog.Default() when not set.
+	// per-request hit/miss/fill/expire events are logged at debug level
+	Logger *slog.Logger
+
+	// flush every group of this node when the process receives this signal
+	// (e.g. syscall.SIGUSR1), disabled when nil. signal handling is process wide,
+	// so make sure the application does not use the signal for anything else
+	FlushSignal os.Signal
+
+	// disable the background cleanup; expired entries are removed only when accessed
+	LazyCleanupOnly bool
+
+	// every CompactIntervalSec, compact (see Group.Compact) the groups holding
+	// fewer entries than CompactRatio (0.25 by default) of the most they held
+	// since their last compaction. disabled when 0, works with LazyCleanupOnly.
+	// WithAutoCompact overrides the ratio of a group
+	CompactIntervalSec int
+	CompactRatio       float64
 }
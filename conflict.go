@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// PeerConflictPolicy decides which value a peer fetch (see Config.PeerFetch)
+// returns when the peers hold different values for the key, e.g. after
+// independent fills or while a set is still being propagated.
+type PeerConflictPolicy int
+
+const (
+	// FirstResponse returns the first value received. The owner of the key is
+	// asked first and the other peers only after Config.PeerFetchStaggerMs, so
+	// this is the fastest policy, but which value wins depends on timing.
+	FirstResponse PeerConflictPolicy = iota
+	// NewestGeneration asks every peer at once and returns the value with the
+	// highest generation, i.e. the one set last, comparing the clocks of the
+	// nodes that set them (see Config.MaxClockSkewMs).
+	NewestGeneration
+	// NewestCreatedAt asks every peer at once and returns the value first cached
+	// last; unlike the generation, the creation time is kept when a value is set
+	// again or its ttl is extended.
+	NewestCreatedAt
+)
+
+func (p PeerConflictPolicy) String() string {
+	switch p {
+	case FirstResponse:
+		return "first_response"
+	case NewestGeneration:
+		return "newest_generation"
+	case NewestCreatedAt:
+		return "newest_created_at"
+	}
+	return fmt.Sprintf("PeerConflictPolicy(%d)", int(p))
+}
+
+// newer reports whether a wins over b.
+func (p PeerConflictPolicy) newer(a, b setEntry) bool {
+	switch p {
+	case NewestGeneration:
+		return a.Generation > b.Generation
+	case NewestCreatedAt:
+		return a.createdAt.After(b.createdAt)
+	}
+	return false
+}
+
+// fetchNewestFromPeers asks every peer for key at once, waits for all of them
+// and returns the newest value according to the policy. On a tie the peer
+// listed first, the most likely owner, wins, so that the result does not depend
+// on the order of the responses.
+func (c *cache) fetchNewestFromPeers(ctx context.Context, g *group, key string, peers []string) (setEntry, bool, error) {
+	type result struct {
+		i     int
+		entry setEntry
+		err   error
+	}
+	results := make(chan result, len(peers))
+	for i, peer := range peers {
+		go func() {
+			entry, err := c.fetchFromPeer(ctx, g, peer, key)
+			results <- result{i, entry, err}
+		}()
+	}
+
+	var best setEntry
+	found := -1
+	for range peers {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				continue
+			}
+			p := c.peerConflictPolicy
+			if found < 0 || p.newer(r.entry, best) || (!p.newer(best, r.entry) && r.i < found) {
+				best, found = r.entry, r.i
+			}
+		case <-ctx.Done():
+			// 이미 받은 값이 있으면 반환
+			if found >= 0 {
+				return best, true, nil
+			}
+			return setEntry{}, false, ctx.Err()
+		}
+	}
+	return best, found >= 0, nil
+}
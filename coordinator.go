@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// FillCoordinator coordinates the getter calls of the nodes of a cluster. The
+// concurrent misses of a key share one fill within a node only, so on a cold
+// start every node would call the getter for the same key; with a
+// FillCoordinator the fill of a node takes the fill lock of the key before
+// calling the getter. The other nodes wait for the lock and then find the value
+// in the store, at the owner of the key or through Config.PropagateSets instead
+// of calling the getter again.
+//
+// It can be backed by any lock shared by the nodes, e.g. SET NX with a ttl in
+// redis or a lease in etcd. The lock should expire by itself in case the node
+// holding it dies.
+type FillCoordinator interface {
+	// Acquire blocks until the node holds the fill lock of key in group, or ctx
+	// is done. The returned func releases the lock.
+	Acquire(ctx context.Context, group, key string) (release func(), err error)
+}
+
+// NopFillCoordinator does not coordinate: every node fills its misses itself,
+// which is what groups without WithFillCoordinator do.
+type NopFillCoordinator struct{}
+
+func (NopFillCoordinator) Acquire(ctx context.Context, group, key string) (func(), error) {
+	return func() {}, nil
+}
+
+// WithFillCoordinator sets the FillCoordinator consulted before the getter is
+// called on a miss of Get. Refresh and refresh-ahead call the getter without it.
+// When Acquire fails for another reason than ctx, the error is reported and the
+// node fills the key without the lock.
+func WithFillCoordinator(fc FillCoordinator) GroupOption {
+	return func(g *group) {
+		g.fillCoordinator = fc
+	}
+}
+
+// acquireFill takes the fill lock of key. When the key was filled by another
+// node while waiting for the lock, its value is returned with ok true and the
+// lock is already released.
+func (g *group) acquireFill(ctx context.Context, key, nkey string) (release func(), val any, src Source, ok bool, err error) {
+	release, err = g.fillCoordinator.Acquire(ctx, g.name, nkey)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, GetterFill, false, err
+		}
+		// lock 을 잡지 못해도 fill 은 계속한다
+		g.coordinatorFailed(key, err)
+		return func() {}, nil, GetterFill, false, nil
+	}
+
+	if val, err := g.get(ctx, nkey); err == nil {
+		release()
+		return nil, val, LocalHit, true, nil
+	}
+	if val, src, ok, err := g.fetchRemote(ctx, key, nkey); ok || err != nil {
+		release()
+		return nil, val, src, ok, err
+	}
+	return release, nil, GetterFill, false, nil
+}
+
+// coordinatorFailed reports an error of FillCoordinator.Acquire.
+func (g *group) coordinatorFailed(key string, err error) {
+	err = fmt.Errorf("fill coordinator: %s in group %s: %w", key, g.name, err)
+	switch {
+	case g.reportError != nil:
+		g.reportError(err)
+	case g.logger != nil:
+		g.logger.Warn(err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+func (g *group) SetWithDependencies(key string, val any, dependsOn ...string) {
+	key = g.normalizeKey(key)
+	deps := make([]string, 0, len(dependsOn))
+	for _, dep := range dependsOn {
+		if dep = g.normalizeKey(dep); dep != key && !slices.Contains(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	entries := []setEntry{{Key: key, Value: val, TTL: g.defaultTTL(), DependsOn: deps}}
+	g.propagateSet(g.setEntries(entries))
+}
+
+func (g *group) dependencyAdd(key string, d data) {
+	if len(d.dependsOn) == 0 {
+		return
+	}
+	if g.dependents == nil {
+		g.dependents = make(map[string]map[string]struct{})
+	}
+	for _, base := range d.dependsOn {
+		keys, ok := g.dependents[base]
+		if !ok {
+			keys = make(map[string]struct{})
+			g.dependents[base] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (g *group) dependencyRemove(key string, d data) {
+	for _, base := range d.dependsOn {
+		delete(g.dependents[base], key)
+		if len(g.dependents[base]) == 0 {
+			delete(g.dependents, base)
+		}
+	}
+}
+
+// dependentsLocked returns the keys that depend on keys directly or transitively,
+// excluding keys themselves. Must be called with g.mtx held.
+func (g *group) dependentsLocked(keys []string) []string {
+	if len(g.dependents) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	var deps []string
+	queue := slices.Clone(keys)
+	for len(queue) != 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for dep := range g.dependents[key] {
+			// 순환 의존이어도 한 번만 방문
+			if _, ok := seen[dep]; ok {
+				continue
+			}
+			seen[dep] = struct{}{}
+			deps = append(deps, dep)
+			queue = append(queue, dep)
+		}
+	}
+	return deps
+}
+
+// deleteDependents removes the dependents of keys that expired, and propagates
+// their deletion like Del.
+func (g *group) deleteDependents(keys []string) {
+	g.mtx.Lock()
+	deps := g.dependentsLocked(keys)
+	g.deleteDependentsLocked(deps, time.Now())
+	g.mtx.Unlock()
+	g.dependentsDeleted(deps)
+}
+
+// deleteDependentsLocked removes the dependents found by dependentsLocked.
+// Must be called with g.mtx held.
+func (g *group) deleteDependentsLocked(deps []string, now time.Time) {
+	for _, key := range deps {
+		g.deleteLocked(key)
+		g.tombstoneLocked(key, now)
+	}
+	if len(deps) != 0 {
+		g.writes++
+	}
+}
+
+// dependentsDeleted reports the dependents removed by deleteDependentsLocked and
+// propagates their deletion like Del; call it without g.mtx held.
+func (g *group) dependentsDeleted(deps []string) {
+	if len(deps) == 0 {
+		return
+	}
+	for _, key := range deps {
+		g.notify(key, OpDelete, nil)
+	}
+	g.delRemote(deps)
+}
+
+// delRemote removes already deleted keys from the store and the peers.
+func (g *group) delRemote(keys []string) {
+	if g.store != nil {
+		for _, key := range keys {
+			g.store.Del(context.Background(), g.name, key)
+		}
+	}
+	if len(keys) == 1 {
+		g.propagateDelete(deleteEvent{group: g.name, key: keys[0]})
+	} else {
+		g.propagateDelete(deleteEvent{group: g.name, keys: keys})
+	}
+}
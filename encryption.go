@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errInvalidEncryptionKey is returned when sealing with an EncryptionKey that
+// NewCache could not use.
+var errInvalidEncryptionKey = errors.New("invalid EncryptionKey")
+
+// headerEncrypted marks a peer request or response body sealed with the
+// EncryptionKey of the cluster.
+const headerEncrypted = "X-Cache-Encrypted"
+
+const encryptionAESGCM = "aes-gcm"
+
+// ErrDecrypt is returned for data sealed with none of the keys of the node, or
+// for plaintext received from a peer by a node with an EncryptionKey.
+var ErrDecrypt = errors.New("decryption failed")
+
+// encryption seals the values leaving the node, see Config.EncryptionKey.
+type encryption struct {
+	// 첫 번째 key 로 암호화, 나머지는 복호화에만 사용
+	aeads []cipher.AEAD
+}
+
+func newEncryption(key []byte, oldKeys [][]byte) (*encryption, error) {
+	e := new(encryption)
+	for _, k := range append([][]byte{key}, oldKeys...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.aeads = append(e.aeads, aead)
+	}
+	return e, nil
+}
+
+// seal encrypts data with the current key, prefixed with a random nonce.
+func (e *encryption) seal(data []byte) ([]byte, error) {
+	if len(e.aeads) == 0 {
+		return nil, errInvalidEncryptionKey
+	}
+	aead := e.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts data sealed with any of the keys.
+func (e *encryption) open(data []byte) ([]byte, error) {
+	for _, aead := range e.aeads {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+		nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, sealed, nil); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrDecrypt
+}
+
+// seal encrypts a body sent to a peer when an EncryptionKey is set; the
+// request or response must then carry headerEncrypted, see setEncrypted.
+func (c *cache) seal(body []byte) ([]byte, error) {
+	if c.encryption == nil {
+		return body, nil
+	}
+	return c.encryption.seal(body)
+}
+
+func (c *cache) setEncrypted(h http.Header) {
+	if c.encryption != nil {
+		h.Set(headerEncrypted, encryptionAESGCM)
+	}
+}
+
+// open decrypts a body received from a peer. With an EncryptionKey, plaintext is
+// refused; without one, so is an encrypted body.
+func (c *cache) open(h http.Header, body []byte) ([]byte, error) {
+	encrypted := h.Get(headerEncrypted) != ""
+	switch {
+	case c.encryption == nil && encrypted:
+		return nil, fmt.Errorf("%w: encrypted by the peer, no EncryptionKey", ErrDecrypt)
+	case c.encryption == nil:
+		return body, nil
+	case !encrypted:
+		return nil, fmt.Errorf("%w: plaintext from the peer", ErrDecrypt)
+	}
+	return c.encryption.open(body)
+}
+
+func validEncryptionKeys(key []byte, oldKeys [][]byte) bool {
+	_, err := newEncryption(key, oldKeys)
+	return err == nil
+}
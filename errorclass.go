@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrorClass tells a group how to handle a getter error, see WithClassifyError.
+type ErrorClass int
+
+const (
+	// ErrorFatal errors are returned to the caller, as without WithClassifyError.
+	ErrorFatal ErrorClass = iota
+	// ErrorTransient errors are not cached. The caller receives the value the
+	// key had before it expired, when there is one, and the error otherwise.
+	// The value is kept for another ttl and reported as StaleHit until it is
+	// set again.
+	ErrorTransient
+	// ErrorNotFound errors are cached as a missing key (see SetMissing) for the
+	// default ttl, so the getter is not called again until then.
+	ErrorNotFound
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorFatal:
+		return "fatal"
+	case ErrorTransient:
+		return "transient"
+	case ErrorNotFound:
+		return "not_found"
+	}
+	return fmt.Sprintf("ErrorClass(%d)", int(c))
+}
+
+// WithClassifyError sets the function deciding, after a getter call fails,
+// whether the error is cached, hidden behind the expired value, or returned.
+func WithClassifyError(fn func(err error) ErrorClass) GroupOption {
+	return func(g *group) {
+		g.classifyError = fn
+	}
+}
+
+// WithStaleIfError keeps expired values for d after they expire instead of
+// removing them, so that a Get whose getter call fails returns the expired value
+// as a StaleHit rather than the error. The value is replaced as soon as a getter
+// call succeeds, and is not served any more once it expired more than d ago,
+// or once it was deleted or replaced while the getter ran.
+// Without WithClassifyError every getter error is handled as ErrorTransient;
+// with it, only ErrorTransient errors return the expired value. ErrCircuitOpen
+// (see WithCircuitBreaker) and ErrRateLimited are always ErrorTransient.
+func WithStaleIfError(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.staleIfError = d
+	}
+}
+
+// removable reports whether d is expired and past its WithStaleIfError,
+// WithStaleOnTimeout or WithServeStaleOnExpiry retention.
+func (g *group) removable(d data, now time.Time) bool {
+	return now.After(d.ttlTime.Add(max(g.staleIfError, g.staleOnTimeout, g.staleOnExpiry)))
+}
+
+// keepsStale reports whether a failed getter call may return the previous value
+// of the key, which then has to be looked up before the Get removes it.
+func (g *group) keepsStale() bool {
+	return g.classifyError != nil || g.staleIfError > 0 || g.staleOnTimeout > 0 || g.keyLimiter != nil
+}
+
+// getterFailed handles err returned by the getter for key according to its class.
+// stale is the entry key had before the Get, if any, which lookup left in the
+// group; it is not served once it was deleted or replaced during the fill.
+func (g *group) getterFailed(key, nkey string, sink *fillSink, stale data, hasStale bool, err error) (any, Source, error) {
+	var class ErrorClass
+	// 만료된 값을 반환하는 기간
+	keep := g.staleIfError
+	switch {
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrCircuitOpen):
+		class = ErrorTransient
+	case errors.Is(err, ErrTimeout) && g.staleOnTimeout > 0:
+		class = ErrorTransient
+		keep = max(keep, g.staleOnTimeout)
+	case g.classifyError != nil:
+		class = g.classifyError(err)
+	case g.staleIfError > 0:
+		class = ErrorTransient
+	}
+	switch class {
+	case ErrorNotFound:
+		g.SetMissing(key)
+		return nil, GetterFill, fmt.Errorf("%s %w: %w", key, ErrNotFound, err)
+	case ErrorTransient:
+		if hasStale && !stale.missing && stale.schemaVersion == g.schemaVersion {
+			now := time.Now()
+			if !stale.stale {
+				stale.expiredAt = stale.ttlTime
+			}
+			if keep > 0 && now.Sub(stale.expiredAt) > keep {
+				return nil, GetterFill, err
+			}
+			// 만료된 값을 원래 ttl 로 다시 넣어 다음 만료 후 재시도
+			stale.stale = true
+			stale.ttlTime = expiresAt(now, stale.ttl)
+			stale.lastAccess = now
+			g.mtx.Lock()
+			// getter 호출 중에 삭제되거나 교체된 값은 되살리지 않는다
+			cur, ok := g.data[nkey]
+			if !ok || cur.generation != stale.generation || sink.discarded ||
+				g.buriedLocked(setEntry{Key: nkey, started: sink.started}, now) {
+				g.mtx.Unlock()
+				return nil, GetterFill, err
+			}
+			// Update 가 나중에 측정한 size 를 유지
+			stale.size = cur.size
+			g.putLocked(nkey, stale)
+			g.writes++
+			g.mtx.Unlock()
+			g.stats.staleHits.Add(1)
+			g.debug("cache stale", key, "err", err)
+			return stale.val, StaleHit, nil
+		}
+	}
+	return nil, GetterFill, err
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+)
+
+// EvictionPolicy chooses the entry removed when a group reaches its maximum number of entries.
+// Entries with a lower priority are always evicted first; the policy breaks ties.
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used entry.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used entry.
+	EvictLFU
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	}
+	return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+}
+
+// PrioritySink is implemented by sinks that can store a value with an eviction priority.
+type PrioritySink interface {
+	SetWithPriority(key string, val any, priority int)
+}
+
+// SetWithPriority stores val in dest with the given eviction priority; entries
+// with a higher priority are evicted later. If dest does not implement
+// PrioritySink, the priority is ignored and Set is used.
+func SetWithPriority(dest Sink, key string, val any, priority int) {
+	if s, ok := dest.(PrioritySink); ok {
+		s.SetWithPriority(key, val, priority)
+		return
+	}
+	dest.Set(key, val)
+}
+
+// WithMaxEntries limits the group to n entries. Storing a new key in a full
+// group evicts an entry chosen by the eviction policy, LRU by default.
+func WithMaxEntries(n int) GroupOption {
+	return func(g *group) {
+		g.maxEntries = n
+	}
+}
+
+// WithEvictionPolicy sets the policy used once the group reaches WithMaxEntries.
+func WithEvictionPolicy(policy EvictionPolicy) GroupOption {
+	return func(g *group) {
+		g.evictionPolicy = policy
+	}
+}
+
+// WithEvictionSamples makes the eviction compare n entries taken from the group
+// instead of all of them, and evict the one the policy ranks first among them,
+// like Redis' approximate LRU. A group with WithMaxEntries then stops taking the
+// write lock on every read to record the access, so recency and, with EvictLFU,
+// hit counts are only updated as often as the ttl slides (see
+// WithTTLRefreshThreshold). The eviction is cheaper and reads scale better, but
+// the victim is only approximately the least recently or frequently used.
+func WithEvictionSamples(n int) GroupOption {
+	return func(g *group) {
+		g.evictionSamples = n
+	}
+}
+
+// evictLocked removes entries until the group fits maxEntries and returns them.
+// Entries stored at now are only evicted for a lower priority, so that a new
+// entry is not the LFU victim before it had a chance to be read.
+// Must be called with g.mtx held.
+func (g *group) evictLocked(now time.Time) map[string]any {
+	if g.maxEntries <= 0 || len(g.data) <= g.maxEntries {
+		return nil
+	}
+
+	evicted := make(map[string]any)
+	for len(g.data) > g.maxEntries {
+		victim := g.victimLocked(now)
+		d, _ := g.deleteLocked(victim)
+		evicted[victim] = d.val
+		g.stats.evictedLifetimes[lifetimeBucket(now.Sub(d.createdAt), d.ttl)].Add(1)
+	}
+	g.stats.evictions.Add(int64(len(evicted)))
+	return evicted
+}
+
+// evictBytes removes entries chosen by the eviction policy until n bytes are
+// freed or the group is empty, and returns them. See Config.MaxTotalBytes.
+func (g *group) evictBytes(n int64) map[string]any {
+	now := time.Now()
+	evicted := make(map[string]any)
+	g.mtx.Lock()
+	for freed := int64(0); freed < n && len(g.data) != 0; {
+		victim := g.victimLocked(now)
+		d, _ := g.deleteLocked(victim)
+		evicted[victim] = d.val
+		freed += d.size
+		g.stats.evictedLifetimes[lifetimeBucket(now.Sub(d.createdAt), d.ttl)].Add(1)
+	}
+	if len(evicted) != 0 {
+		g.writes++
+	}
+	g.mtx.Unlock()
+	g.stats.evictions.Add(int64(len(evicted)))
+	return evicted
+}
+
+// victimLocked returns the key the eviction policy removes first, among the
+// first evictionSamples entries of the map iteration when it is set. The group
+// must not be empty. Must be called with g.mtx held.
+func (g *group) victimLocked(now time.Time) string {
+	if g.evictionSamples == 0 {
+		if g.evictOrder == nil {
+			g.evictOrder = newEvictOrder(g.evictionPolicy, g.data)
+		}
+		return g.evictOrder.victim(now)
+	}
+
+	var victim string
+	var victimData data
+	found := false
+	sampled := 0
+	// map 순회는 매번 임의의 위치에서 시작하므로 앞의 entry 를 표본으로 사용
+	for key, d := range g.data {
+		if !found || g.evictsBefore(d, victimData, now) {
+			victim, victimData, found = key, d, true
+		}
+		if sampled++; g.evictionSamples > 0 && sampled >= g.evictionSamples {
+			break
+		}
+	}
+	return victim
+}
+
+// evictsBefore reports whether a should be evicted before b.
+func (g *group) evictsBefore(a, b data, now time.Time) bool {
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	if aNew, bNew := !a.lastAccess.Before(now), !b.lastAccess.Before(now); aNew != bNew {
+		return bNew
+	}
+	if g.evictionPolicy == EvictLFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastAccess.Before(b.lastAccess)
+}
+
+// notifyEvicted reports evicted entries; call it without g.mtx held.
+func (g *group) notifyEvicted(evicted map[string]any) {
+	for key, val := range evicted {
+		g.debug("cache evicted", key)
+		g.notify(key, OpEvict, val)
+	}
+}
+
+// evictOrder is a heap of the keys of a group ordered by evictsBefore, except
+// for the entries stored at now, which victim handles. It lets a group without
+// WithEvictionSamples find the victim without scanning every entry.
+// The methods are no-ops on a nil evictOrder.
+type evictOrder struct {
+	policy EvictionPolicy
+	items  []evictItem
+	// key 별 items 의 위치
+	pos map[string]int
+}
+
+type evictItem struct {
+	key        string
+	priority   int
+	hits       int64
+	lastAccess time.Time
+}
+
+func newEvictOrder(policy EvictionPolicy, entries map[string]data) *evictOrder {
+	o := &evictOrder{
+		policy: policy,
+		items:  make([]evictItem, 0, len(entries)),
+		pos:    make(map[string]int, len(entries)),
+	}
+	for key, d := range entries {
+		o.pos[key] = len(o.items)
+		o.items = append(o.items, evictItem{key: key, priority: d.priority, hits: d.hits, lastAccess: d.lastAccess})
+	}
+	heap.Init(o)
+	return o
+}
+
+// update adds key or moves it to the place of d.
+func (o *evictOrder) update(key string, d data) {
+	if o == nil {
+		return
+	}
+	item := evictItem{key: key, priority: d.priority, hits: d.hits, lastAccess: d.lastAccess}
+	if i, ok := o.pos[key]; ok {
+		o.items[i] = item
+		heap.Fix(o, i)
+		return
+	}
+	heap.Push(o, item)
+}
+
+func (o *evictOrder) remove(key string) {
+	if o == nil {
+		return
+	}
+	if i, ok := o.pos[key]; ok {
+		heap.Remove(o, i)
+	}
+}
+
+// victim returns the first key in the order, preferring a key of the same
+// priority that was not stored at now. The order must not be empty.
+func (o *evictOrder) victim(now time.Time) string {
+	first := o.items[0]
+	if first.lastAccess.Before(now) {
+		return first.key
+	}
+	// now 에 저장된 entry 를 잠시 꺼내 같은 priority 의 다른 entry 를 찾는다
+	var held []evictItem
+	victim := first.key
+	for o.Len() != 0 && o.items[0].priority == first.priority {
+		if o.items[0].lastAccess.Before(now) {
+			victim = o.items[0].key
+			break
+		}
+		held = append(held, heap.Pop(o).(evictItem))
+	}
+	for _, item := range held {
+		heap.Push(o, item)
+	}
+	return victim
+}
+
+func (o *evictOrder) Len() int { return len(o.items) }
+
+func (o *evictOrder) Less(i, j int) bool {
+	a, b := o.items[i], o.items[j]
+	if a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	if o.policy == EvictLFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastAccess.Before(b.lastAccess)
+}
+
+func (o *evictOrder) Swap(i, j int) {
+	o.items[i], o.items[j] = o.items[j], o.items[i]
+	o.pos[o.items[i].key] = i
+	o.pos[o.items[j].key] = j
+}
+
+func (o *evictOrder) Push(x any) {
+	item := x.(evictItem)
+	o.pos[item.key] = len(o.items)
+	o.items = append(o.items, item)
+}
+
+func (o *evictOrder) Pop() any {
+	item := o.items[len(o.items)-1]
+	o.items = o.items[:len(o.items)-1]
+	delete(o.pos, item.key)
+	return item
+}
@@ -0,0 +1,32 @@
+package cache
+
+// spawn runs fn in a background goroutine that Close waits for, counted by
+// NumGoroutines. fn must return once c.ctx is done. Once Close is waiting for
+// the goroutines, spawn does not start fn and returns false.
+func (c *cache) spawn(fn func()) bool {
+	c.spawnMtx.Lock()
+	if c.closing {
+		c.spawnMtx.Unlock()
+		return false
+	}
+	c.wg.Add(1)
+	c.goroutines.Add(1)
+	c.spawnMtx.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		defer c.goroutines.Add(-1)
+		fn()
+	}()
+	return true
+}
+
+// NumGoroutines returns the number of background goroutines of the cache that
+// are running: its workers, the peer discovery, the http server and the
+// background refreshes of its groups. It is 0 once Close has returned, which
+// tests embedding the cache can assert to check that nothing leaked. Goroutines
+// serving a single call, e.g. the concurrent requests of a peer fetch, end with
+// the context of the call and are not counted.
+func (c *cache) NumGoroutines() int {
+	return int(c.goroutines.Load())
+}
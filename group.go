@@ -2,34 +2,816 @@ package cache
 
 import (
 	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExpired  = errors.New("cache expired")
+
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrTimeout is returned by Get when the group's operation timeout or getter
+	// timeout expires.
+	ErrTimeout = errors.New("operation timed out")
+	// ErrKeyTooLong is returned by Get for keys longer than WithMaxKeyLength.
+	ErrKeyTooLong = errors.New("key too long")
+	// ErrSlowGetter is reported to Config.OnError for getter calls slower than
+	// WithSlowGetterThreshold. Get does not return it.
+	ErrSlowGetter = errors.New("slow getter call")
+	// ErrFillKeyLimit is reported to Config.OnError for getter calls that set
+	// more keys than WithMaxFillKeys. Get does not return it.
+	ErrFillKeyLimit = errors.New("too many keys set by one getter call")
+
+	// errMissing is returned for keys recorded with SetMissing
+	errMissing = fmt.Errorf("missing: %w", ErrNotFound)
+)
+
 type Sink interface {
 	Set(key string, val any)
 }
 
+// TTLSink is implemented by sinks that can store a value with its own TTL.
+type TTLSink interface {
+	SetWithTTL(key string, val any, ttl time.Duration)
+}
+
+// TTLRangeSink is implemented by sinks that can store a value with a TTL drawn
+// uniformly from [min, max], so that entries loaded together do not expire together.
+type TTLRangeSink interface {
+	SetWithTTLRange(key string, val any, min, max time.Duration)
+}
+
+// MissingSink is implemented by sinks that can record a key as known to be missing (negative caching).
+type MissingSink interface {
+	SetMissing(key string)
+}
+
+// TagSink is implemented by sinks that can attach tags to a stored key.
+type TagSink interface {
+	SetTags(key string, tags ...string)
+}
+
+// SetWithTTL stores val in dest with the given ttl.
+// If dest does not implement TTLSink, the ttl is ignored and Set is used.
+func SetWithTTL(dest Sink, key string, val any, ttl time.Duration) {
+	if s, ok := dest.(TTLSink); ok {
+		s.SetWithTTL(key, val, ttl)
+		return
+	}
+	dest.Set(key, val)
+}
+
+// SetWithTTLRange stores val in dest with a ttl drawn uniformly from [min, max].
+// If dest does not implement TTLRangeSink, the ttl is drawn here and SetWithTTL is used.
+func SetWithTTLRange(dest Sink, key string, val any, min, max time.Duration) {
+	if s, ok := dest.(TTLRangeSink); ok {
+		s.SetWithTTLRange(key, val, min, max)
+		return
+	}
+	SetWithTTL(dest, key, val, randomTTL(min, max))
+}
+
+func randomTTL(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + rand.N(max-min)
+}
+
+// SetMissing records key as missing in dest. It is a no-op if dest does not implement MissingSink.
+func SetMissing(dest Sink, key string) {
+	if s, ok := dest.(MissingSink); ok {
+		s.SetMissing(key)
+	}
+}
+
+// SetTags attaches tags to key in dest. It is a no-op if dest does not implement TagSink.
+// Tags are attached to an already stored key, so call it after Set.
+func SetTags(dest Sink, key string, tags ...string) {
+	if s, ok := dest.(TagSink); ok {
+		s.SetTags(key, tags...)
+	}
+}
+
+type ttlContextKey struct{}
+
+// ContextWithTTL returns a copy of ctx that suggests ttl for the values the getter
+// stores with Sink.Set during a Get with that context, e.g. from an upstream
+// Cache-Control header. A ttl passed to SetWithTTL by the getter takes precedence.
+func ContextWithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlContextKey{}, ttl)
+}
+
+// TTLFromContext returns the ttl suggested with ContextWithTTL.
+func TTLFromContext(ctx context.Context) (time.Duration, bool) {
+	ttl, ok := ctx.Value(ttlContextKey{}).(time.Duration)
+	return ttl, ok
+}
+
 type data struct {
-	val     any
-	ttlTime time.Time
+	val       any
+	ttl       time.Duration
+	ttlTime   time.Time
+	createdAt time.Time
+	missing   bool
+	tags      []string
+	stale     bool // getter 실패로 만료된 값을 다시 넣은 경우
+	// stale 인 값이 처음 만료된 시각
+	expiredAt time.Time
+	dependsOn []string
+	// 값을 설정한 node 의 group schema version
+	schemaVersion int
+	// 값이 설정될 때마다 증가, peer 간 충돌 해결에 사용
+	generation uint64
+
+	// eviction 순서 결정에 사용
+	priority   int
+	lastAccess time.Time
+	hits       int64
+
+	// sizer 로 측정한 byte 수, sizer 가 없으면 0
+	size int64
+}
+
+// NoExpiry is the ttl of entries that never expire, e.g. static reference data
+// kept next to expiring entries in one group. Pass it to SetWithTTL, or as the
+// ttl of NewGroupWithTTL to make it the group default. These entries are only
+// removed by Del, by eviction (WithMaxEntries, Config.MaxTotalBytes) or by a
+// flush, so a group storing an unbounded set of keys with NoExpiry grows without
+// limit unless it has an eviction bound. WithTTLBounds still clamps it to its max.
+const NoExpiry time.Duration = math.MaxInt64
+
+// noExpiryTime is the expiry of NoExpiry entries, later than any other.
+var noExpiryTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// expiresAt returns the expiry of an entry stored or touched at now with ttl.
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl == NoExpiry {
+		return noExpiryTime
+	}
+	return now.Add(ttl)
+}
+
+// Source is where the value returned by GetWithSource came from.
+type Source int
+
+const (
+	LocalHit Source = iota
+	StoreHit
+	PeerHit
+	GetterFill
+	// StaleHit is an expired value returned because the getter failed, see ErrorTransient.
+	StaleHit
+)
+
+func (s Source) String() string {
+	switch s {
+	case LocalHit:
+		return "local"
+	case StoreHit:
+		return "store"
+	case PeerHit:
+		return "peer"
+	case GetterFill:
+		return "getter"
+	case StaleHit:
+		return "stale"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
 }
 
 type Group interface {
+	// Get returns the value of key, filling a miss with the getter. Concurrent
+	// misses of a key on this node wait for a single getter call and share its
+	// result; a Get whose context ends stops waiting without ending that call.
 	Get(ctx context.Context, key string) (any, error)
+	// GetWithSource is Get that also reports where the value came from.
+	GetWithSource(ctx context.Context, key string) (any, Source, error)
+	// GetStale is Get that also reports whether the value is stale, i.e. expired
+	// and served in place of a failed getter call (see WithClassifyError).
+	GetStale(ctx context.Context, key string) (val any, stale bool, err error)
+	// GetInto is Get that stores the value into dest, which must be a non-nil pointer.
+	// A value of a type assignable to *dest is assigned directly; any other value,
+	// e.g. one decoded by a peer as a map, is converted through the group codec.
+	// It returns an error wrapping ErrTypeMismatch when the value does not fit dest.
+	GetInto(ctx context.Context, key string, dest any) error
+	// Refresh calls the getter for key even when it is cached, and stores the
+	// value like a getter fill on a miss. Read-only groups, groups without a
+	// getter and caches in maintenance mode return ErrNotFound.
+	Refresh(ctx context.Context, key string) (any, error)
+	// GetIfPresent looks up key in the local cache only and never calls the getter.
+	GetIfPresent(key string) (any, bool)
+	// WithKeyLock runs fn while holding the lock of key, so that calls for the
+	// same key run one after the other while calls for different keys run
+	// concurrently, e.g. to perform a side effect once per key. The lock is
+	// local to this node, not cluster-wide (see FillCoordinator), and separate
+	// from the entries: fn may use the group, but must not call WithKeyLock
+	// for the same key again.
+	WithKeyLock(key string, fn func())
+	// Metadata returns the metadata of the live entry of key without its value.
+	// Like GetIfPresent it never calls the getter, and it does not slide the ttl.
+	Metadata(key string) (EntryMeta, bool)
+	Set(key string, val any)
+	SetWithTTL(key string, val any, ttl time.Duration)
+	// SetWithPriority stores val with an eviction priority, see WithMaxEntries.
+	// Entries with a lower priority are evicted first; Set uses priority 0.
+	SetWithPriority(key string, val any, priority int)
+	// SetMulti stores all items under a single lock acquisition, or one per
+	// batch with WithWriteBatchSize.
+	SetMulti(items map[string]any)
+	SetMultiWithTTL(items map[string]any, ttl time.Duration)
+	// SetDefaultTTL changes the ttl of the entries set from now on.
+	// Existing entries keep their ttl until they are set again.
+	SetDefaultTTL(ttl time.Duration)
 	Del(key string)
+	// DelWithResult is Del that sends the delete to the peers before returning,
+	// instead of queueing it, and reports which peers applied it. Callers that need
+	// the delete to reach every node can retry or alert on result.Failed.
+	DelWithResult(key string) DeleteResult
+	// DelBatch deletes keys together. Every node, this one and each peer, applies
+	// the whole batch under a single lock, so readers of a node never observe a
+	// partially applied batch. It is best effort across nodes: a peer that does not
+	// receive the batch keeps all of the keys.
+	DelBatch(keys []string)
+	// GetAndDelete removes key and returns its value if it was live, under a
+	// single lock, so that concurrent callers never both receive the same value.
+	// The delete is propagated like Del. The getter is never called.
+	GetAndDelete(key string) (any, bool)
+	// Compact copies the entries into a freshly allocated map, releasing the memory
+	// a Go map keeps after its entries are deleted, e.g. after a mass invalidation.
+	// The group is locked for the copy. See also WithAutoCompact.
+	Compact()
+	// InFlight returns the keys that currently have an active getter call.
+	InFlight() []string
+	Stats() Stats
+	// ResetStats returns the stats like Stats and zeroes the counters, so that
+	// the next call returns the counts of the interval in between. Gauges such
+	// as Entries are not reset.
+	ResetStats() Stats
+	// Entries returns a point-in-time snapshot of the unexpired entries, sorted by key.
+	// Keys recorded with SetMissing are not included.
+	Entries() []Entry
+	// Snapshot returns a read-only copy of the unexpired entries, taken under a
+	// single read lock, see GroupView.
+	Snapshot() GroupView
+	// SnapshotTo writes the unexpired entries to w as JSON lines, sorted by key.
+	// The group is only locked while each chunk of entries is copied, so the
+	// snapshot is consistent per entry but not across the whole group, and it
+	// does not need the memory of the whole encoded group.
+	SnapshotTo(w io.Writer) error
+	// RestoreFrom stores the entries written by SnapshotTo, skipping the ones that
+	// expired since, without propagating them to peers. Values are decoded from
+	// JSON, so structs are restored as maps (see JSONCodec). Entries older than
+	// the ones already cached are ignored.
+	RestoreFrom(r io.Reader) error
+	// Append adds val to the list stored under key, starting a new list when key
+	// is absent or expired, so that a group can be used as a multimap. The whole
+	// list is one entry: it is propagated, evicted and expires as a unit, and
+	// each Append resets its ttl to the group default.
+	Append(key string, val any)
+	// Update stores the value fn returns for key, called with the current live
+	// value of key, if any. fn runs with the group locked, so concurrent Updates
+	// of the group never lose a write; it must be fast and must not use the group.
+	// Nothing is stored when fn returns false, or when WithShouldCache rejects
+	// the value, which then stays as it was. The stored value gets the group
+	// default ttl and is propagated like Set. It returns an error wrapping
+	// ErrKeyTooLong for keys over WithMaxKeyLength.
+	Update(key string, fn func(old any, existed bool) (new any, store bool)) error
+	// GetAll returns a copy of the list built with Append. It only looks up the
+	// local cache and never calls the getter.
+	GetAll(key string) ([]any, error)
+	// SetWithDependencies is Set that also records that the value is derived from
+	// the dependsOn keys: deleting or expiring one of them deletes key too, and
+	// transitively the keys that depend on key. The cascaded deletes are propagated
+	// to peers like Del. Cycles are allowed and delete every key of the cycle.
+	// Setting key again replaces its dependencies, and setting or updating one of
+	// the dependsOn keys on this node deletes key as well.
+	SetWithDependencies(key string, val any, dependsOn ...string)
+	// GetByIndex returns the unexpired values that WithIndex mapped to indexValue,
+	// sorted by key. It returns nil for groups without an index.
+	GetByIndex(indexValue string) []any
+	// SetGetter attaches or replaces the getter after the group was created,
+	// e.g. when the getter's dependencies are initialized after the group.
+	SetGetter(getter Getter)
 }
 
 type group struct {
 	mtx        sync.RWMutex
 	name       string
 	data       map[string]data
-	getter     Getter
+	getter     Getter // SetGetter 로 교체 가능
 	defttl     time.Duration
 	deleteChan chan deleteEvent
+
+	store Store
+	codec Codec
+	// peer 에게 받은 값을 decode 할 때 codec 다음으로 사용
+	legacyCodecs []Codec
+	// WithBinaryValues 사용 시 MarshalBinary 한 값을 decode 할 값 생성
+	newBinaryValue func() encoding.BinaryUnmarshaler
+
+	// getter 호출 중인 key 별 호출 수
+	inflight map[string]int
+	// 정규화된 key 별 진행 중인 fill, WithCancelFillsOnDelete 사용 시에만 생성
+	fills map[string][]*fillSink
+	// 정규화된 key 별 Get 의 miss 를 채우는 중인 fill, 같은 key 의 다른 Get 이 기다린다
+	misses map[string]*missFill
+
+	// ttlTime 전 refreshAhead 이내에 읽힌 key 는 미리 getter 로 갱신
+	refreshAhead time.Duration
+	refreshing   map[string]struct{}
+
+	changeChan chan changeEvent
+	setChan    chan setEvent
+
+	// 마지막으로 발급하거나 peer 에게 받은 generation
+	generation uint64
+
+	// peer 에게 바로 delete 를 보내고 결과를 반환, 단일 node 면 nil
+	peerDelete func(keys []string) DeleteResult
+
+	// local miss 시 peer 에서 값을 조회, PeerFetch 설정 시에만 사용
+	peerFetch func(ctx context.Context, key string) (setEntry, error)
+
+	// getter 를 호출하지 않고 peer 가 보낸 값만 사용
+	readOnly bool
+	// cache 의 maintenance mode, true 인 동안 getter 를 호출하지 않음
+	maintenance *atomic.Bool
+	// 모든 group 이 공유하는 Config.MaxTotalBytes, 없으면 nil
+	budget *byteBudget
+	sizer  func(key string, val any) int64
+	// entry 의 size 합계, g.mtx 로 보호
+	bytes int64
+
+	// deleteChan 이 가득 찬 경우 대기하지 않고 버림
+	dropDeletes bool
+	// cache 종료 시 닫힘
+	done <-chan struct{}
+
+	shouldCache func(key string, val any) bool
+	// ttl 만료로 삭제된 경우에만 호출
+	onExpire func(key string, val any)
+	// nil 이면 getter 오류를 그대로 반환
+	classifyError func(err error) ErrorClass
+	// 만료 후 이 기간 동안 값을 남겨 getter 실패 시 반환, 0 이면 만료 즉시 삭제
+	staleIfError time.Duration
+	// 만료 후 이 기간 안의 첫 Get 에 만료된 값을 반환하고 background 로 다시 채움
+	staleOnExpiry time.Duration
+
+	// index 값 별 key 목록, indexFunc 가 nil 이면 사용하지 않음
+	indexFunc func(val any) []string
+	index     map[string]map[string]struct{}
+	// 삭제된 key 와 삭제 시각, tombstoneTTL 이 0 이면 사용하지 않음
+	tombstoneTTL time.Duration
+	tombstones   map[string]time.Time
+	// key 별로 그 key 에 의존하는 key 목록, SetWithDependencies 로 설정
+	dependents map[string]map[string]struct{}
+	// 모든 Get/Set/Del 의 key 에 적용, nil 이면 그대로 사용
+	keyNormalizer func(key string) string
+	// 0 이면 제한 없음
+	maxKeyLength int
+	// 다른 schema version 으로 저장된 entry 는 miss 로 취급
+	schemaVersion int
+
+	// 저장되는 ttl 의 범위, 0 이면 제한 없음
+	minTTL, maxTTL time.Duration
+	// nil 이 아니면 read 시 hits 에 따라 ttl 을 조정
+	adaptiveTTL func(ttl time.Duration, hits int64) time.Duration
+	// 0 이하의 ttl 로 설정된 값을 저장하지 않음
+	dropNonPositiveTTL bool
+
+	// nil 이면 사용하지 않음
+	breaker    *breaker
+	keyLimiter *keyLimiter
+	stats      groupStats
+
+	// 남은 ttl 이 이보다 짧을 때만 읽기에서 ttl 연장, 0 이면 매번 연장
+	ttlRefreshThreshold time.Duration
+
+	// Get 전체 (local, store, peer, getter) 에 적용되는 timeout, 0 이면 없음
+	operationTimeout time.Duration
+	// getter 호출에만 적용되는 timeout, 0 이면 없음
+	getterTimeout time.Duration
+	// getter timeout 시 만료된 값을 반환하는 기간
+	staleOnTimeout time.Duration
+	// 이보다 오래 걸린 getter 호출을 reportError 로 보고, 0 이면 보고하지 않음
+	slowGetterThreshold time.Duration
+	// getter 호출 한 번이 set 할 수 있는 key 수, 0 이면 제한 없음
+	maxFillKeys int
+	// cache 의 Config.OnError, 없으면 logger 로 기록
+	reportError func(err error)
+
+	// WithKeyLock 의 key 별 lock, 사용 중인 key 만 유지
+	keyLocksMtx sync.Mutex
+	keyLocks    map[string]*keyLock
+
+	// nil 이면 node 마다 fill
+	fillCoordinator FillCoordinator
+	// Config.AuditLogSize 가 설정된 cache 의 audit log
+	audit *auditLog
+	// cache 의 background goroutine 으로 실행, cache 없이 만든 group 은 nil
+	spawn func(fn func()) bool
+
+	// nil 이 아니면 반환하는 값을 복사
+	cloneOnRead func(val any) (any, error)
+
+	// 0 보다 크면 bulk write 를 이 크기로 나누어 lock
+	writeBatchSize int
+
+	// 0 보다 크면 이보다 많이 읽힌 key 만 set 을 전파
+	propagateHits int64
+
+	// cleanup 을 copy-and-swap 으로 수행, writes 는 data 변경 횟수
+	copyOnCleanup bool
+	writes        uint64
+
+	// compactRatio 가 0 이면 auto compact 하지 않음.
+	// peakEntries 는 마지막 compact 이후 write 와 cleanup 에서 관찰한 최대 entry 수
+	compactRatio float64
+	peakEntries  int
+
+	// 0 이면 제한 없음
+	maxEntries     int
+	evictionPolicy EvictionPolicy
+	// 0 이면 모든 entry 를 비교
+	evictionSamples int
+	// evictionSamples 가 0 일 때 첫 eviction 에서 만들어지는 eviction 순서
+	evictOrder *evictOrder
+
+	// nil 이면 debug 로그를 남기지 않음
+	logger *slog.Logger
+}
+
+type GroupOption func(*group)
+
+// WithCodec overrides the cache codec for the group.
+func WithCodec(codec Codec) GroupOption {
+	return func(g *group) {
+		g.codec = codec
+	}
+}
+
+// WithShouldCache sets a predicate consulted whenever a value is about to be stored,
+// for getter fills as well as values pushed by peers. When it returns false the
+// value is still returned to the caller of Get but is not cached.
+// Keys recorded with SetMissing (negative caching) are not subject to it.
+func WithShouldCache(fn func(key string, val any) bool) GroupOption {
+	return func(g *group) {
+		g.shouldCache = fn
+	}
+}
+
+// WithOnExpire calls fn with each entry removed because its ttl expired, when it
+// is read after expiry or swept by the background cleanup, but not when it is
+// deleted, evicted or replaced. Keys recorded with SetMissing are not reported.
+// fn runs on the goroutine that removed the entry,
+// outside of the group lock; keys are passed as stored, see WithKeyNormalizer.
+func WithOnExpire(fn func(key string, val any)) GroupOption {
+	return func(g *group) {
+		g.onExpire = fn
+	}
+}
+
+// WithOperationTimeout bounds a whole Get, from the local lookup through the
+// store, the peer fetch and the getter, which all receive a context with this
+// deadline. Get returns an error wrapping ErrTimeout once it expires, even when
+// the getter ignores the context; a value it fills later is still cached.
+func WithOperationTimeout(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.operationTimeout = d
+	}
+}
+
+// WithGetterTimeout bounds each getter call, which receives a context with this
+// deadline. The Get returns an error wrapping ErrTimeout once it expires, even
+// when the getter ignores the context; a value it fills later is still cached.
+// Unlike WithOperationTimeout, the local lookup, the store and the peers are not
+// bounded. Counted in Stats.GetterTimeouts.
+func WithGetterTimeout(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.getterTimeout = d
+	}
+}
+
+// WithStaleOnTimeout keeps expired values for d after they expire, like
+// WithStaleIfError, but only returns them as a StaleHit when the getter call
+// times out (see WithGetterTimeout), so that a slow backend costs at most the
+// getter timeout while other getter errors are still returned. Get returns the
+// error wrapping ErrTimeout when the key has no value that expired less than d ago.
+func WithStaleOnTimeout(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.staleOnTimeout = d
+	}
+}
+
+// WithPropagateAfterHits holds back the propagation of local writes (see
+// Config.PropagateSets) until the key has been read more than n times on this
+// node: the entry is then pushed to the peers once, and its later writes are
+// propagated right away. Keys that are written and never read again are not
+// replicated. Reads of the group then take the write lock to count the hits.
+func WithPropagateAfterHits(n int) GroupOption {
+	return func(g *group) {
+		g.propagateHits = int64(n)
+	}
+}
+
+// WithSlowGetterThreshold reports the getter calls taking longer than d, failed
+// or not, with an error wrapping ErrSlowGetter that names the key and the
+// duration, to Config.OnError or the logger, and counts them in
+// Stats.SlowGetterCalls.
+func WithSlowGetterThreshold(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.slowGetterThreshold = d
+	}
+}
+
+// WithTTLRefreshThreshold makes a read slide the ttl of an entry only when less
+// than d of it remains, instead of on every read. The other reads do not take the
+// write lock, at the cost of not being seen by the eviction of WithMaxEntries.
+func WithTTLRefreshThreshold(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.ttlRefreshThreshold = d
+	}
+}
+
+// WithCopyOnCleanup makes the background cleanup build the surviving entries
+// under the read lock and swap them in, instead of deleting the expired entries
+// while holding the write lock for the whole scan. The write lock is then only
+// held for the swap, and the swapped map is compact again after heavy churn.
+// The tradeoff: a sweep that removes entries costs several times more
+// (about 5x with 10% expired entries, see BenchmarkGroup_TTLCleanUp), writers
+// still wait for the scan, which holds the read lock, the group briefly holds
+// two copies of its entries, and the copy is wasted (the expired keys are then
+// deleted one by one) when the group is written meanwhile, which includes every Get.
+// It pays off for large groups with few writes per cleanup interval, or that
+// need their map compacted after churn.
+func WithCopyOnCleanup() GroupOption {
+	return func(g *group) {
+		g.copyOnCleanup = true
+	}
+}
+
+const minAutoCompactEntries = 1024
+
+// WithAutoCompact compacts the group (see Group.Compact) when the background
+// cleanup finds fewer entries than ratio, e.g. 0.25, of the most entries it has
+// seen since the last compaction. Groups that never held minAutoCompactEntries
+// entries are left alone. With Config.LazyCleanupOnly, groups are only compacted
+// by Config.CompactIntervalSec, which then uses ratio.
+func WithAutoCompact(ratio float64) GroupOption {
+	return func(g *group) {
+		g.compactRatio = ratio
+	}
+}
+
+// WithKeyNormalizer maps every key passed to Get, Set and Del to the key it is
+// stored and propagated under, e.g. a fixed length digest of long keys.
+// The getter is still called with the original key.
+func WithKeyNormalizer(fn func(key string) string) GroupOption {
+	return func(g *group) {
+		g.keyNormalizer = fn
+	}
+}
+
+// WithSchemaVersion sets the version of the format of the values of the group,
+// e.g. bumped when a deploy changes what the getter returns. Entries stored by
+// nodes with another version, received from peers during a rolling deploy or
+// restored from a snapshot, are treated as misses and filled again.
+func WithSchemaVersion(v int) GroupOption {
+	return func(g *group) {
+		g.schemaVersion = v
+	}
+}
+
+// WithMaxKeyLength rejects keys longer than n bytes after WithKeyNormalizer is
+// applied: Get returns an error wrapping ErrKeyTooLong without calling the getter,
+// and Set, Append and SetMissing, including values pushed by peers, ignore them.
+func WithMaxKeyLength(n int) GroupOption {
+	return func(g *group) {
+		g.maxKeyLength = n
+	}
+}
+
+// keyTooLong reports whether a normalized key exceeds maxKeyLength.
+func (g *group) keyTooLong(key string) bool {
+	return g.maxKeyLength > 0 && len(key) > g.maxKeyLength
+}
+
+// WithTTLBounds clamps the ttl of every entry stored in the group, whether set
+// locally, by the getter or by a peer, into [min, max]; a zero bound is not
+// enforced. A zero or negative ttl is replaced by the group default ttl before
+// clamping, unless WithDropNonPositiveTTL is set.
+func WithTTLBounds(min, max time.Duration) GroupOption {
+	return func(g *group) {
+		g.minTTL, g.maxTTL = min, max
+	}
+}
+
+// WithAdaptiveTTL scales the ttl an entry slides to on each read with scale,
+// called with the ttl the entry was stored with and its number of reads, so
+// that hot keys stay cached longer than cold ones. The result is clamped by
+// WithTTLBounds. A nil scale uses DefaultTTLScale. Reads of the group then take
+// the write lock to count the hits.
+func WithAdaptiveTTL(scale func(ttl time.Duration, hits int64) time.Duration) GroupOption {
+	return func(g *group) {
+		if scale == nil {
+			scale = DefaultTTLScale
+		}
+		g.adaptiveTTL = scale
+	}
+}
+
+// DefaultTTLScale grows the ttl logarithmically with the hits: the stored ttl
+// after the first read, twice as long after 7 reads and 3 times after 31.
+func DefaultTTLScale(ttl time.Duration, hits int64) time.Duration {
+	return time.Duration(float64(ttl) * (1 + math.Log2(float64(1+hits))) / 2)
+}
+
+// touchTTL returns the ttl a read slides d to.
+func (g *group) touchTTL(d data) time.Duration {
+	if g.adaptiveTTL == nil || d.ttl == NoExpiry {
+		return d.ttl
+	}
+	ttl := g.adaptiveTTL(d.ttl, d.hits)
+	if ttl <= 0 {
+		return d.ttl
+	}
+	if g.minTTL > 0 {
+		ttl = max(ttl, g.minTTL)
+	}
+	if g.maxTTL > 0 {
+		ttl = min(ttl, g.maxTTL)
+	}
+	return ttl
+}
+
+// WithDropNonPositiveTTL makes the group ignore values set with a zero or
+// negative ttl, e.g. from a "max-age=0" upstream directive, instead of storing them.
+func WithDropNonPositiveTTL() GroupOption {
+	return func(g *group) {
+		g.dropNonPositiveTTL = true
+	}
+}
+
+// boundTTL applies WithTTLBounds and WithDropNonPositiveTTL to ttl, reporting
+// false when the value must not be stored. Must be called with g.mtx held.
+func (g *group) boundTTL(ttl time.Duration) (time.Duration, bool) {
+	if ttl <= 0 {
+		if g.dropNonPositiveTTL {
+			return 0, false
+		}
+		if g.minTTL <= 0 && g.maxTTL <= 0 {
+			return ttl, true
+		}
+		ttl = g.defttl
+	}
+	if g.minTTL > 0 {
+		ttl = max(ttl, g.minTTL)
+	}
+	if g.maxTTL > 0 {
+		ttl = min(ttl, g.maxTTL)
+	}
+	return ttl, true
+}
+
+// WithStore sets the backing store consulted between the local map and the getter.
+func WithStore(store Store) GroupOption {
+	return func(g *group) {
+		g.store = store
+	}
+}
+
+// WithMaxFillKeys limits a getter call to setting n distinct keys, so that a
+// runaway getter, e.g. a batch getter loading a whole table, cannot fill the
+// group past its capacity in one call. The requested key is always stored; the
+// other keys set beyond the limit are dropped, counted in Stats.FillKeysDropped
+// and reported with an error wrapping ErrFillKeyLimit to Config.OnError or the
+// logger. Stats.MaxFillKeys reports the most keys set by one call either way.
+func WithMaxFillKeys(n int) GroupOption {
+	return func(g *group) {
+		g.maxFillKeys = n
+	}
+}
+
+// WithCloneOnRead returns a copy of the cached value to the callers of Get,
+// GetWithSource, GetInto, GetIfPresent, Refresh and GetByIndex, and of each item
+// to the callers of GetAll, made by clone, so that a caller modifying a returned
+// pointer, map or slice does not modify the value every other caller reads.
+// With a nil clone, values are copied by a round trip through the group codec
+// into a new value of the same type, which fails for values the codec cannot
+// encode; Get then returns the error and GetByIndex leaves the value out.
+// Without it, values are shared by reference, which is faster. Values passed to
+// Update and returned by Entries and Snapshot are never copied.
+func WithCloneOnRead(clone func(val any) any) GroupOption {
+	return func(g *group) {
+		if clone == nil {
+			g.cloneOnRead = g.codecClone
+			return
+		}
+		g.cloneOnRead = func(val any) (any, error) {
+			return clone(val), nil
+		}
+	}
+}
+
+// codecClone copies val by encoding it with the group codec and decoding it
+// into a new value of its type.
+func (g *group) codecClone(val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	dat, err := g.codec.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	ptr := reflect.New(reflect.TypeOf(val))
+	if err := g.codec.Unmarshal(dat, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+// cloneValue returns the copy of val returned to the caller, see WithCloneOnRead.
+func (g *group) cloneValue(key string, val any) (any, error) {
+	val, err := g.cloneOnRead(val)
+	if err != nil {
+		return nil, fmt.Errorf("%s clone on read: %w", key, err)
+	}
+	return val, nil
+}
+
+// WithWriteBatchSize stores bulk writes (SetMulti, RestoreFrom, Config.SnapshotFile
+// and the entries pushed by peers) in batches of n entries, releasing the write
+// lock between batches, so that loading millions of entries, and growing the map
+// for them, does not block concurrent reads for the whole load. Readers may then
+// observe part of a bulk write. Stats.MaxWriteLock reports the longest hold.
+func WithWriteBatchSize(n int) GroupOption {
+	return func(g *group) {
+		g.writeBatchSize = n
+	}
+}
+
+// WithServeStaleOnExpiry returns the expired value of a key, as a StaleHit, to
+// the Get that finds it expired within d of its expiry, and refills the key in
+// the background instead of making that caller wait for the getter. Other Gets
+// of the key while the refill runs are served the expired value as well, without
+// calling the getter again. When the refill fails, the
+// next Get within d serves the expired value again and starts another refill.
+// Unlike WithRefreshAhead, nothing is refreshed before the key expires.
+func WithServeStaleOnExpiry(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.staleOnExpiry = d
+	}
+}
+
+// serveExpired returns the expired value of nkey and starts its refill, unless a
+// refill of nkey is already running. It returns false when the value expired
+// more than WithServeStaleOnExpiry ago.
+func (g *group) serveExpired(key, nkey string) (any, bool) {
+	if g.currentGetter() == nil || g.readOnly || g.inMaintenance() {
+		return nil, false
+	}
+	now := time.Now()
+	g.mtx.Lock()
+	data, ok := g.data[nkey]
+	if !ok || data.missing || !now.After(data.ttlTime) || now.After(data.ttlTime.Add(g.staleOnExpiry)) {
+		g.mtx.Unlock()
+		return nil, false
+	}
+	// 진행 중인 refill 이 있으면 그 결과를 기다리지 않고 만료된 값을 반환
+	if _, ok := g.refreshing[nkey]; ok {
+		g.mtx.Unlock()
+		return data.val, true
+	}
+	g.refreshing[nkey] = struct{}{}
+	g.mtx.Unlock()
+
+	if !g.startRefresh(key, nkey) {
+		return nil, false
+	}
+	return data.val, true
+}
+
+// WithRefreshAhead refreshes a key in the background when it is read within d of
+// its expiry. The current value is still returned to the caller while the getter runs.
+func WithRefreshAhead(d time.Duration) GroupOption {
+	return func(g *group) {
+		g.refreshAhead = d
+	}
 }
 
 func newGroup(name string, getter Getter, defttl time.Duration, deleteChan chan deleteEvent) *group {
@@ -39,79 +821,1509 @@ func newGroup(name string, getter Getter, defttl time.Duration, deleteChan chan
 		defttl:     defttl,
 		getter:     getter,
 		deleteChan: deleteChan,
+		codec:      JSONCodec{},
+		inflight:   make(map[string]int),
+		misses:     make(map[string]*missFill),
+		refreshing: make(map[string]struct{}),
 	}
 }
 
 func (g *group) get(ctx context.Context, key string) (any, error) {
+	return g.lookup(key, false)
+}
+
+// lookup is get. With keepExpired, an expired entry is left in the group for
+// the fill that follows, so that getterFailed can tell whether it was deleted
+// meanwhile; the caller removes it with dropExpired afterwards.
+func (g *group) lookup(key string, keepExpired bool) (any, error) {
 	g.mtx.RLock()
 	data, hit := g.data[key]
 	g.mtx.RUnlock()
 	if !hit {
-		return nil, fmt.Errorf("%s not found", key)
+		return nil, fmt.Errorf("%s %w", key, ErrNotFound)
 	}
 	// Check if the data is expired
 	//ttltime := 15초 time now 20초
+	if data.schemaVersion != g.schemaVersion {
+		g.mtx.Lock()
+		if cur, ok := g.data[key]; ok && cur.generation == data.generation {
+			g.deleteLocked(key)
+			g.writes++
+		}
+		g.mtx.Unlock()
+		g.debug("cache schema mismatch", key, "version", data.schemaVersion)
+		return nil, fmt.Errorf("%s %w", key, ErrNotFound)
+	}
 	now := time.Now()
 	if now.After(data.ttlTime) {
+		// WithStaleIfError 기간 동안은 getter 실패 시 반환하도록 남겨 둔다
+		if !keepExpired && g.removable(data, now) {
+			g.dropExpired(key, data.generation)
+		}
+		return nil, ErrExpired
+	}
+
+	if g.needsTouch(data, now) {
+		var hot []setEntry
 		g.mtx.Lock()
-		delete(g.data, key)
+		// RUnlock 이후 다른 값으로 교체되었으면 덮어쓰지 않는다
+		if cur, ok := g.data[key]; ok && cur.generation == data.generation {
+			cur.lastAccess = now
+			cur.hits++
+			cur.ttlTime = expiresAt(now, g.touchTTL(cur))
+			g.data[key] = cur
+			g.evictOrder.update(key, cur)
+			g.writes++
+			// 이번 read 로 threshold 를 넘은 key 를 peer 에게 전파
+			if g.propagateHits > 0 && cur.hits == g.propagateHits+1 && !cur.missing {
+				hot = []setEntry{{Key: key, Value: cur.val, TTL: cur.ttl, Generation: cur.generation, Priority: cur.priority, DependsOn: cur.dependsOn, SchemaVersion: cur.schemaVersion}}
+			}
+		}
 		g.mtx.Unlock()
-		return nil, errors.New("cache expired")
+		g.sendSets(hot)
 	}
 
-	g.mtx.Lock()
-	data.ttlTime = time.Now().Add(g.defttl)
-	g.data[key] = data
-	g.mtx.Unlock()
-
+	if data.missing {
+		return nil, fmt.Errorf("%s %w", key, errMissing)
+	}
 	return data.val, nil
 }
 
 func (g *group) Get(ctx context.Context, key string) (any, error) {
-	if val, err := g.get(ctx, key); err == nil {
-		return val, nil
+	val, _, err := g.GetWithSource(ctx, key)
+	return val, err
+}
+
+func (g *group) GetStale(ctx context.Context, key string) (any, bool, error) {
+	val, src, err := g.GetWithSource(ctx, key)
+	return val, src == StaleHit, err
+}
+
+func (g *group) GetWithSource(ctx context.Context, key string) (any, Source, error) {
+	val, src, err := g.getWithTimeout(ctx, key)
+	if err == nil && g.cloneOnRead != nil {
+		val, err = g.cloneValue(key, val)
 	}
-	if err := g.getter.Get(ctx, key, g); err != nil {
-		return nil, err
+	return val, src, err
+}
+
+// getWithTimeout is getWithSource bounded by WithOperationTimeout.
+func (g *group) getWithTimeout(ctx context.Context, key string) (any, Source, error) {
+	if g.operationTimeout <= 0 {
+		return g.getWithSource(ctx, key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.operationTimeout)
+	defer cancel()
+
+	type result struct {
+		val any
+		src Source
+		err error
+	}
+	// getter 가 ctx 를 무시하더라도 timeout 에 반환, 채워진 값은 그대로 cache 된다
+	done := make(chan result, 1)
+	go func() {
+		val, src, err := g.getWithSource(ctx, key)
+		done <- result{val, src, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.err = fmt.Errorf("%s %w: %w", key, ErrTimeout, r.err)
+		}
+		return r.val, r.src, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, GetterFill, fmt.Errorf("%s %w: %w", key, ErrTimeout, ctx.Err())
+		}
+		return nil, GetterFill, ctx.Err()
 	}
-	return g.get(ctx, key)
 }
 
-// Sink
-func (g *group) Set(key string, val any) {
-	data := data{
-		val:     val,
-		ttlTime: time.Now().Add(g.defttl),
+func (g *group) getWithSource(ctx context.Context, key string) (_ any, src Source, _ error) {
+	// getter 에는 원래 key 를, 그 외에는 정규화된 key 를 사용
+	nkey := g.normalizeKey(key)
+	if g.keyTooLong(nkey) {
+		return nil, GetterFill, fmt.Errorf("%w: %d bytes, max %d", ErrKeyTooLong, len(nkey), g.maxKeyLength)
 	}
-	g.mtx.Lock()
-	g.data[key] = data
-	g.mtx.Unlock()
+	if g.refreshAhead > 0 {
+		g.refreshIfDue(key, nkey)
+	}
+	// fill 이 끝날 때까지 group 에 남겨 두는 만료된 값, transient 오류 시 반환
+	var stale data
+	var hasStale bool
+	if g.keepsStale() {
+		stale, hasStale = g.entry(nkey)
+	}
+	val, err := g.lookup(nkey, hasStale)
+	if err == nil || errors.Is(err, errMissing) {
+		g.stats.hits.Add(1)
+		g.debug("cache hit", key, "source", LocalHit.String(), "missing", err != nil)
+		if hasStale && stale.stale {
+			g.stats.staleHits.Add(1)
+			return val, StaleHit, err
+		}
+		return val, LocalHit, err
+	}
+	if errors.Is(err, ErrExpired) && g.staleOnExpiry > 0 {
+		if val, ok := g.serveExpired(key, nkey); ok {
+			g.stats.hits.Add(1)
+			g.stats.staleHits.Add(1)
+			g.debug("cache hit", key, "source", StaleHit.String(), "refill", true)
+			return val, StaleHit, nil
+		}
+	}
+	g.stats.misses.Add(1)
+	if hasStale {
+		defer func() {
+			// getterFailed 가 다시 넣어 반환한 값은 남긴다
+			if src != StaleHit {
+				g.dropExpired(nkey, stale.generation)
+			}
+		}()
+	}
+
+	if val, src, ok, err := g.fetchRemote(ctx, key, nkey); ok || err != nil {
+		return val, src, err
+	}
+
+	// cache-only group, getter not set yet, or read-only replica
+	if g.currentGetter() == nil || g.readOnly {
+		g.debug("cache miss", key, "getter", false)
+		return nil, GetterFill, err
+	}
+	if g.inMaintenance() {
+		g.stats.maintenanceMisses.Add(1)
+		g.debug("cache miss", key, "getter", false, "maintenance", true)
+		return nil, GetterFill, fmt.Errorf("%s %w", key, ErrNotFound)
+	}
+
+	return g.fillMissShared(ctx, nkey, func() (any, Source, error) {
+		return g.fillMiss(ctx, key, nkey, stale, hasStale)
+	})
 }
 
-func (g *group) Del(key string) {
-	g.mtx.Lock()
-	delete(g.data, key)
-	g.mtx.Unlock()
+// missFill is the fill of a missed key in progress, see fillMissShared.
+type missFill struct {
+	done chan struct{}
+	val  any
+	src  Source
+	err  error
+}
 
-	g.deleteChan <- deleteEvent{group: g.name, key: key}
-	// cache peer send delete
+// fillMissShared runs fill for nkey, unless a Get of this node is already filling
+// nkey: it then waits for that fill and returns its result, so that the
+// concurrent misses of a key take the FillCoordinator lock and call the getter
+// once per node. A waiting Get whose fill was canceled by the context of the Get
+// that started it fills the key again.
+func (g *group) fillMissShared(ctx context.Context, nkey string, fill func() (any, Source, error)) (any, Source, error) {
+	for {
+		g.mtx.Lock()
+		call, running := g.misses[nkey]
+		if !running {
+			call = &missFill{done: make(chan struct{})}
+			g.misses[nkey] = call
+		}
+		g.mtx.Unlock()
+
+		if !running {
+			g.runMissFill(nkey, call, fill)
+			return call.val, call.src, call.err
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, GetterFill, ctx.Err()
+		}
+		// WithGetterTimeout 은 getter 의 실패이므로 다시 채우지 않는다
+		canceled := errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)
+		if canceled && !errors.Is(call.err, ErrTimeout) && ctx.Err() == nil {
+			continue
+		}
+		return call.val, call.src, call.err
+	}
 }
 
-func (g *group) ttlCleanUp(now time.Time) {
-	g.mtx.Lock()
-	defer g.mtx.Unlock()
+// runMissFill runs fill for call and wakes the Gets waiting for it, even if the
+// getter panics.
+func (g *group) runMissFill(nkey string, call *missFill, fill func() (any, Source, error)) {
+	defer func() {
+		g.mtx.Lock()
+		delete(g.misses, nkey)
+		g.mtx.Unlock()
+		close(call.done)
+	}()
+	call.src, call.err = GetterFill, fmt.Errorf("%s %w: fill did not complete", nkey, ErrNotFound)
+	call.val, call.src, call.err = fill()
+}
 
-	for key, val := range g.data {
-		if now.After(val.ttlTime) {
-			delete(g.data, key)
+// fillMiss fills a missed key with the getter, under the FillCoordinator lock.
+func (g *group) fillMiss(ctx context.Context, key, nkey string, stale data, hasStale bool) (any, Source, error) {
+	if g.fillCoordinator != nil {
+		release, val, src, ok, err := g.acquireFill(ctx, key, nkey)
+		if ok || err != nil {
+			return val, src, err
 		}
+		// store 에 쓴 뒤 release 되도록 defer
+		defer release()
+	}
+
+	sink := g.newFillSink(ctx, key)
+	if err := g.callGetterWithTimeout(ctx, sink); err != nil {
+		g.debug("cache miss", key, "getter", true, "err", err)
+		return g.getterFailed(key, nkey, sink, stale, hasStale, err)
 	}
+	g.debug("cache fill", key, "getter", true, "filled", sink.filled)
+	if !sink.filled {
+		val, err := g.get(ctx, nkey)
+		return val, GetterFill, err
+	}
+
+	if g.store != nil && g.cacheable(nkey, sink.val) {
+		g.store.Set(ctx, g.name, nkey, sink.val, sink.ttl)
+	}
+	return sink.val, GetterFill, nil
+}
+
+// fetchRemote looks nkey up in the store and then at the peers. ok is false when
+// neither has it; err is a store error other than ErrNotFound.
+func (g *group) fetchRemote(ctx context.Context, key, nkey string) (val any, src Source, ok bool, err error) {
+	if g.store != nil {
+		val, err := g.store.Get(ctx, g.name, nkey)
+		if err == nil {
+			g.setWithTTL(nkey, val, g.defaultTTL())
+			g.debug("cache hit", key, "source", StoreHit.String())
+			return val, StoreHit, true, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, StoreHit, false, err
+		}
+	}
+
+	if g.peerFetch != nil {
+		start := time.Now()
+		entry, err := g.peerFetch(ctx, nkey)
+		g.stats.recordPeerFetch(time.Since(start), err == nil)
+		if err == nil {
+			// peer 에서 가져온 값은 다시 propagate 하지 않는다
+			g.setEntries([]setEntry{entry})
+			g.debug("cache hit", key, "source", PeerHit.String())
+			return entry.Value, PeerHit, true, nil
+		}
+	}
+	return nil, GetterFill, false, nil
+}
+
+// needsTouch reports whether a read has to take the write lock to slide the ttl
+// of d. Without WithTTLRefreshThreshold, reads of an entry already touched within
+// the last 1% of its ttl (at most a second) only take the read lock; the ttl then
+// slides slightly less than on every read. Groups with WithMaxEntries touch on
+// every read, which the exact eviction policy relies on, and so do the groups of a cache
+// with Config.MaxTotalBytes and the groups counting hits for WithPropagateAfterHits
+// or WithAdaptiveTTL.
+func (g *group) needsTouch(d data, now time.Time) bool {
+	if g.ttlRefreshThreshold > 0 {
+		return d.ttlTime.Sub(now) < g.ttlRefreshThreshold
+	}
+	if (g.maxEntries > 0 && g.evictionSamples == 0) || g.budget != nil || g.propagateHits > 0 || g.adaptiveTTL != nil {
+		return true
+	}
+	return now.Sub(d.lastAccess) >= min(d.ttl/100, time.Second)
+}
+
+// normalizeKey maps a key given to the public methods to the key it is stored under.
+func (g *group) normalizeKey(key string) string {
+	if g.keyNormalizer == nil {
+		return key
+	}
+	return g.keyNormalizer(key)
+}
+
+func (g *group) GetInto(ctx context.Context, key string, dest any) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("dest must be a non-nil pointer, got %T", dest)
+	}
+
+	val, err := g.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	elem := rv.Elem()
+	if val != nil && reflect.TypeOf(val).AssignableTo(elem.Type()) {
+		elem.Set(reflect.ValueOf(val))
+		return nil
+	}
+	// 다른 타입 (peer 에서 codec 으로 decode 된 값 등) 은 codec 으로 변환
+	dat, err := g.codec.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("%w: %T into %T: %v", ErrTypeMismatch, val, dest, err)
+	}
+	if err := g.codec.Unmarshal(dat, dest); err != nil {
+		return fmt.Errorf("%w: %T into %T: %v", ErrTypeMismatch, val, dest, err)
+	}
+	return nil
+}
+
+// fillSink is the Sink handed to the getter. It records the value set for the
+// requested key, so the value reaches the caller even when it is not cached.
+type fillSink struct {
+	group *group
+	key   string
+	// Set 에 사용되는 ttl, context 의 ttl 또는 group 기본 ttl
+	defttl time.Duration
+	val    any
+	ttl    time.Duration
+	filled bool
+	// getter 호출 시작 시각, tombstone 보다 오래된 값인지 확인
+	started time.Time
+
+	// getter 가 set 한 key 와 WithMaxFillKeys 로 버린 수
+	keysMtx sync.Mutex
+	keys    map[string]struct{}
+	dropped int
+
+	// WithCancelFillsOnDelete 사용 시 설정, g.mtx 로 보호
+	nkey      string
+	cancel    context.CancelFunc
+	discarded bool
+}
+
+func (g *group) newFillSink(ctx context.Context, key string) *fillSink {
+	ttl, ok := TTLFromContext(ctx)
+	if !ok {
+		ttl = g.defaultTTL()
+	}
+	return &fillSink{group: g, key: key, defttl: ttl, started: time.Now()}
+}
+
+func (s *fillSink) Set(key string, val any) {
+	s.SetWithTTL(key, val, s.defttl)
+}
+
+func (s *fillSink) SetWithTTL(key string, val any, ttl time.Duration) {
+	if key == s.key {
+		s.val, s.ttl, s.filled = val, ttl, true
+	}
+	nkey := s.group.normalizeKey(key)
+	if !s.admit(key, nkey) {
+		return
+	}
+	entries := []setEntry{{Key: nkey, Value: val, TTL: ttl, started: s.started, fill: s}}
+	s.group.propagateSet(s.group.setEntries(entries))
+}
+
+func (s *fillSink) SetWithTTLRange(key string, val any, min, max time.Duration) {
+	s.SetWithTTL(key, val, randomTTL(min, max))
+}
+
+func (s *fillSink) SetWithPriority(key string, val any, priority int) {
+	if key == s.key {
+		s.val, s.ttl, s.filled = val, s.defttl, true
+	}
+	nkey := s.group.normalizeKey(key)
+	if !s.admit(key, nkey) {
+		return
+	}
+	entries := []setEntry{{Key: nkey, Value: val, TTL: s.defttl, Priority: priority, started: s.started, fill: s}}
+	s.group.propagateSet(s.group.setEntries(entries))
+}
+
+func (s *fillSink) SetMissing(key string) {
+	if key == s.key {
+		s.filled = false
+	}
+	if !s.admit(key, s.group.normalizeKey(key)) {
+		return
+	}
+	s.group.SetMissing(key)
+}
+
+// admit records nkey as set by the getter call, and reports whether it may be
+// stored under WithMaxFillKeys. Keys set again and the requested key are always
+// admitted.
+func (s *fillSink) admit(key, nkey string) bool {
+	s.keysMtx.Lock()
+	defer s.keysMtx.Unlock()
+	if _, ok := s.keys[nkey]; ok {
+		return true
+	}
+	if limit := s.group.maxFillKeys; limit > 0 && len(s.keys) >= limit && key != s.key {
+		s.dropped++
+		return false
+	}
+	if s.keys == nil {
+		s.keys = make(map[string]struct{})
+	}
+	s.keys[nkey] = struct{}{}
+	return true
+}
+
+func (s *fillSink) SetTags(key string, tags ...string) {
+	s.group.SetTags(key, tags...)
+}
+
+func (g *group) callGetter(ctx context.Context, sink *fillSink) error {
+	if g.breaker != nil && !g.breaker.allow(time.Now()) {
+		return ErrCircuitOpen
+	}
+
+	key := sink.key
+	if g.keyLimiter != nil && !g.keyLimiter.allow(key, time.Now()) {
+		g.stats.getterThrottled.Add(1)
+		return fmt.Errorf("%s %w", key, ErrRateLimited)
+	}
+	g.mtx.Lock()
+	getter := g.getter
+	if getter == nil {
+		// SetGetter(nil) 로 제거된 경우
+		g.mtx.Unlock()
+		return fmt.Errorf("%s %w", key, ErrNotFound)
+	}
+	g.inflight[key]++
+	if g.fills != nil {
+		ctx, sink.cancel = context.WithCancel(ctx)
+		sink.nkey = g.normalizeKey(key)
+		g.fills[sink.nkey] = append(g.fills[sink.nkey], sink)
+	}
+	g.mtx.Unlock()
+
+	defer func() {
+		g.mtx.Lock()
+		if g.inflight[key]--; g.inflight[key] <= 0 {
+			delete(g.inflight, key)
+		}
+		if g.fills != nil {
+			sink.cancel()
+			g.fills[sink.nkey] = slices.DeleteFunc(g.fills[sink.nkey], func(s *fillSink) bool { return s == sink })
+			if len(g.fills[sink.nkey]) == 0 {
+				delete(g.fills, sink.nkey)
+			}
+		}
+		g.mtx.Unlock()
+	}()
+
+	g.stats.getterCalls.Add(1)
+	start := time.Now()
+	err := getter.Get(ctx, key, sink)
+	if elapsed := time.Since(start); g.slowGetterThreshold > 0 && elapsed > g.slowGetterThreshold {
+		g.stats.slowGetterCalls.Add(1)
+		g.slowGetter(key, elapsed)
+	}
+	sink.keysMtx.Lock()
+	keys, dropped := len(sink.keys), sink.dropped
+	sink.keysMtx.Unlock()
+	g.stats.recordFillKeys(keys)
+	if dropped > 0 {
+		g.stats.fillKeysDropped.Add(int64(dropped))
+		g.fillKeyLimit(key, dropped)
+	}
+	if err != nil {
+		g.stats.getterErrors.Add(1)
+	}
+	if g.breaker != nil {
+		g.breaker.record(err, time.Now())
+	}
+	return err
+}
+
+// callGetterWithTimeout is callGetter bounded by WithGetterTimeout.
+func (g *group) callGetterWithTimeout(ctx context.Context, sink *fillSink) error {
+	if g.getterTimeout <= 0 {
+		return g.callGetter(ctx, sink)
+	}
+
+	gctx, cancel := context.WithTimeout(ctx, g.getterTimeout)
+	defer cancel()
+
+	// getter 가 ctx 를 무시하더라도 timeout 에 반환, 채워진 값은 그대로 cache 된다
+	done := make(chan error, 1)
+	go func() {
+		done <- g.callGetter(gctx, sink)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+		if err == nil {
+			return nil
+		}
+	case <-gctx.Done():
+		err = gctx.Err()
+	}
+	// 호출한 쪽의 ctx 가 끝난 경우는 getter timeout 이 아님
+	if ctx.Err() == nil && errors.Is(gctx.Err(), context.DeadlineExceeded) {
+		g.stats.getterTimeouts.Add(1)
+		return fmt.Errorf("%s %w: getter call took longer than %s: %w", sink.key, ErrTimeout, g.getterTimeout, err)
+	}
+	return err
+}
+
+// SetGetter attaches or replaces the getter. Misses return ErrNotFound while no getter is set.
+func (g *group) SetGetter(getter Getter) {
+	g.mtx.Lock()
+	g.getter = getter
+	g.mtx.Unlock()
+}
+
+// slowGetter reports a getter call slower than slowGetterThreshold.
+func (g *group) slowGetter(key string, elapsed time.Duration) {
+	err := fmt.Errorf("%s %w in group %s: %s", key, ErrSlowGetter, g.name, elapsed)
+	switch {
+	case g.reportError != nil:
+		g.reportError(err)
+	case g.logger != nil:
+		g.logger.Warn(err.Error())
+	}
+}
+
+// fillKeyLimit reports a getter call for key that set more keys than maxFillKeys.
+func (g *group) fillKeyLimit(key string, dropped int) {
+	err := fmt.Errorf("%s %w in group %s: %d dropped, max %d", key, ErrFillKeyLimit, g.name, dropped, g.maxFillKeys)
+	switch {
+	case g.reportError != nil:
+		g.reportError(err)
+	case g.logger != nil:
+		g.logger.Warn(err.Error())
+	}
+}
+
+func (g *group) inMaintenance() bool {
+	return g.maintenance != nil && g.maintenance.Load()
+}
+
+func (g *group) currentGetter() Getter {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	return g.getter
+}
+
+// refreshIfDue starts a background refresh of key when it expires within refreshAhead.
+// At most one refresh per key runs at a time.
+func (g *group) refreshIfDue(key, nkey string) {
+	if g.currentGetter() == nil || g.readOnly || g.inMaintenance() {
+		return
+	}
+	now := time.Now()
+	data, ok := g.entry(nkey)
+	if !ok || data.missing || now.After(data.ttlTime) || data.ttlTime.Sub(now) > g.refreshAhead {
+		return
+	}
+
+	g.mtx.Lock()
+	if _, ok := g.refreshing[nkey]; ok {
+		g.mtx.Unlock()
+		return
+	}
+	g.refreshing[nkey] = struct{}{}
+	g.mtx.Unlock()
+
+	g.startRefresh(key, nkey)
+}
+
+// startRefresh runs refresh in the background, as a goroutine of the cache of
+// the group, which Close waits for. It returns false when the cache is closing.
+func (g *group) startRefresh(key, nkey string) bool {
+	if g.spawn == nil {
+		go g.refresh(key, nkey)
+		return true
+	}
+	if g.spawn(func() { g.refresh(key, nkey) }) {
+		return true
+	}
+	g.mtx.Lock()
+	delete(g.refreshing, nkey)
+	g.mtx.Unlock()
+	return false
+}
+
+func (g *group) refresh(key, nkey string) {
+	defer func() {
+		g.mtx.Lock()
+		delete(g.refreshing, nkey)
+		g.mtx.Unlock()
+	}()
+
+	// cache 종료 시 getter 호출을 취소
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-g.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	g.fill(ctx, key, nkey)
+}
+
+func (g *group) Refresh(ctx context.Context, key string) (any, error) {
+	nkey := g.normalizeKey(key)
+	if g.keyTooLong(nkey) {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrKeyTooLong, len(nkey), g.maxKeyLength)
+	}
+	if g.currentGetter() == nil || g.readOnly || g.inMaintenance() {
+		return nil, fmt.Errorf("%s %w", key, ErrNotFound)
+	}
+	sink, err := g.fill(ctx, key, nkey)
+	if err != nil {
+		return nil, err
+	}
+	val := sink.val
+	if !sink.filled {
+		// getter 가 SetMissing 한 경우 등
+		if val, err = g.get(ctx, nkey); err != nil {
+			return nil, err
+		}
+	}
+	if g.cloneOnRead != nil {
+		return g.cloneValue(key, val)
+	}
+	return val, nil
+}
+
+// fill calls the getter for key and writes a filled value through to the store.
+func (g *group) fill(ctx context.Context, key, nkey string) (*fillSink, error) {
+	sink := g.newFillSink(ctx, key)
+	if err := g.callGetterWithTimeout(ctx, sink); err != nil {
+		return sink, err
+	}
+	if sink.filled && g.store != nil && g.cacheable(nkey, sink.val) {
+		g.store.Set(ctx, g.name, nkey, sink.val, sink.ttl)
+	}
+	return sink, nil
+}
+
+func (g *group) InFlight() []string {
+	g.mtx.RLock()
+	keys := make([]string, 0, len(g.inflight))
+	for key := range g.inflight {
+		keys = append(keys, key)
+	}
+	g.mtx.RUnlock()
+
+	slices.Sort(keys)
+	return keys
+}
+
+func (g *group) GetIfPresent(key string) (any, bool) {
+	val, err := g.get(context.Background(), g.normalizeKey(key))
+	// Get 과 같이 SetMissing 된 key 도 hit
+	if err == nil || errors.Is(err, errMissing) {
+		g.stats.hits.Add(1)
+	} else {
+		g.stats.misses.Add(1)
+	}
+	if err == nil && g.cloneOnRead != nil {
+		val, err = g.cloneValue(key, val)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// Sink
+func (g *group) Set(key string, val any) {
+	g.SetWithTTL(key, val, g.defaultTTL())
+}
+
+func (g *group) SetWithTTL(key string, val any, ttl time.Duration) {
+	g.setWithTTL(g.normalizeKey(key), val, ttl)
+}
+
+// setWithTTL stores an already normalized key and propagates it.
+func (g *group) setWithTTL(key string, val any, ttl time.Duration) {
+	entries := []setEntry{{Key: key, Value: val, TTL: ttl}}
+	g.propagateSet(g.setEntries(entries))
+}
+
+func (g *group) SetWithTTLRange(key string, val any, min, max time.Duration) {
+	g.SetWithTTL(key, val, randomTTL(min, max))
+}
+
+func (g *group) SetWithPriority(key string, val any, priority int) {
+	entries := []setEntry{{Key: g.normalizeKey(key), Value: val, TTL: g.defaultTTL(), Priority: priority}}
+	g.propagateSet(g.setEntries(entries))
+}
+
+func (g *group) defaultTTL() time.Duration {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	return g.defttl
+}
+
+func (g *group) SetDefaultTTL(ttl time.Duration) {
+	g.mtx.Lock()
+	g.defttl = ttl
+	g.mtx.Unlock()
+}
+
+func (g *group) SetMulti(items map[string]any) {
+	g.SetMultiWithTTL(items, g.defaultTTL())
+}
+
+func (g *group) SetMultiWithTTL(items map[string]any, ttl time.Duration) {
+	entries := make([]setEntry, 0, len(items))
+	for key, val := range items {
+		entries = append(entries, setEntry{Key: g.normalizeKey(key), Value: val, TTL: ttl})
+	}
+	g.propagateSet(g.setEntries(entries))
+}
+
+func (g *group) cacheable(key string, val any) bool {
+	return g.shouldCache == nil || g.shouldCache(key, val)
+}
+
+// setEntries stores entries locally without propagating them to peers.
+// It returns the entries that were actually stored.
+func (g *group) setEntries(entries []setEntry) []setEntry {
+	if g.writeBatchSize <= 0 || len(entries) <= g.writeBatchSize {
+		return g.setBatch(entries)
+	}
+	var stored []setEntry
+	for len(entries) != 0 {
+		batch := entries[:min(g.writeBatchSize, len(entries))]
+		entries = entries[len(batch):]
+		stored = append(stored, g.setBatch(batch)...)
+		// 기다리던 reader 가 다음 batch 전에 lock 을 잡도록 양보
+		runtime.Gosched()
+	}
+	return stored
+}
+
+// setBatch stores entries under one write lock.
+func (g *group) setBatch(entries []setEntry) []setEntry {
+	entries = slices.DeleteFunc(slices.Clone(entries), func(e setEntry) bool {
+		return g.keyTooLong(e.Key) || !g.cacheable(e.Key, e.Value)
+	})
+	for i, e := range entries {
+		entries[i].size = g.sizeOf(e.Key, e.Value)
+	}
+
+	now := time.Now()
+	stored := entries[:0]
+	// local 에서 값을 바꾼 key, 의존하는 key 를 지우는 데 사용
+	var bases []string
+	g.mtx.Lock()
+	lockedAt := time.Now()
+	for _, e := range entries {
+		var ok bool
+		if e.TTL, ok = g.boundTTL(e.TTL); !ok {
+			continue
+		}
+		if g.tombstoneTTL > 0 && g.buriedLocked(e, now) {
+			continue
+		}
+		// 값을 읽는 중에 삭제된 key
+		if e.fill != nil && e.fill.discarded && e.Key == e.fill.nkey {
+			continue
+		}
+		if e.Generation == 0 {
+			e.Generation = g.nextGeneration(now)
+			e.SchemaVersion = g.schemaVersion
+			bases = append(bases, e.Key)
+		} else {
+			// peer 가 보낸 값이 local 값보다 오래된 경우 무시 (last-writer-wins)
+			if old, ok := g.data[e.Key]; ok && old.generation > e.Generation {
+				continue
+			}
+			g.generation = max(g.generation, e.Generation)
+		}
+		g.putLocked(e.Key, data{
+			val:        e.Value,
+			ttl:        e.TTL,
+			ttlTime:    expiresAt(now, e.TTL),
+			createdAt:  now,
+			generation: e.Generation,
+			priority:   e.Priority,
+			dependsOn:  e.DependsOn,
+			lastAccess: now,
+			hits:       g.data[e.Key].hits,
+			size:       e.size,
+			// peer 가 보낸 값은 다른 version 일 수 있으며 읽을 때 확인
+			schemaVersion: e.SchemaVersion,
+		})
+		stored = append(stored, e)
+	}
+	deps := g.dependentsLocked(bases)
+	if len(deps) != 0 {
+		// 같은 batch 에서 저장한 key 는 남긴다
+		batch := make(map[string]struct{}, len(stored))
+		for _, e := range stored {
+			batch[e.Key] = struct{}{}
+		}
+		deps = slices.DeleteFunc(deps, func(key string) bool {
+			_, ok := batch[key]
+			return ok
+		})
+		g.deleteDependentsLocked(deps, now)
+	}
+	evicted := g.evictLocked(now)
+	g.writes++
+	g.stats.recordWriteLock(time.Since(lockedAt))
+	g.mtx.Unlock()
+	entries = stored
+
+	for _, e := range entries {
+		g.notifyFrom(e.Key, OpSet, e.Value, e.origin)
+	}
+	g.dependentsDeleted(deps)
+	g.notifyEvicted(evicted)
+	g.enforceBudget()
+	return entries
+}
+
+// nextGeneration returns a generation greater than every generation seen by the group.
+// Generations follow the wall clock, so they are comparable across nodes. Must be called with g.mtx held.
+func (g *group) nextGeneration(now time.Time) uint64 {
+	g.generation = max(g.generation+1, uint64(now.UnixNano()))
+	return g.generation
+}
+
+// propagateSet queues entries to be pushed to peers; it is a no-op when set propagation is disabled.
+func (g *group) propagateSet(entries []setEntry) {
+	if g.setChan == nil || len(entries) == 0 {
+		return
+	}
+	if g.propagateHits > 0 {
+		// 아직 threshold 만큼 읽히지 않은 key 는 읽힐 때 전파
+		g.mtx.RLock()
+		entries = slices.DeleteFunc(entries, func(e setEntry) bool {
+			return g.data[e.Key].hits <= g.propagateHits
+		})
+		g.mtx.RUnlock()
+	}
+	g.sendSets(entries)
+}
+
+// sendSets queues entries for the peers.
+func (g *group) sendSets(entries []setEntry) {
+	if g.setChan == nil || len(entries) == 0 {
+		return
+	}
+	select {
+	case g.setChan <- setEvent{group: g.name, entries: entries}:
+	default:
+		// peer 는 miss 시 getter 로 채우므로 버퍼가 가득 차면 버린다
+	}
+}
+
+func (g *group) Append(key string, val any) {
+	g.Update(key, func(old any, existed bool) (any, bool) {
+		list, _ := old.([]any)
+		// 읽는 쪽이 가진 기존 list 를 변경하지 않도록 항상 새로 할당
+		return append(slices.Clip(list), val), true
+	})
+}
+
+func (g *group) Update(key string, fn func(old any, existed bool) (any, bool)) error {
+	key = g.normalizeKey(key)
+	if g.keyTooLong(key) {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrKeyTooLong, len(key), g.maxKeyLength)
+	}
+	now := time.Now()
+
+	g.mtx.Lock()
+	old, ok := g.data[key]
+	live := ok && !old.missing && !now.After(old.ttlTime) && old.schemaVersion == g.schemaVersion
+	var cur any
+	if live {
+		cur = old.val
+	}
+	val, store := fn(cur, live)
+	// 저장하지 않는 값은 peer 도 버리므로 전파하지 않는다
+	if !store || !g.cacheable(key, val) {
+		g.mtx.Unlock()
+		return nil
+	}
+
+	ttl, ok := g.boundTTL(g.defttl)
+	if !ok {
+		g.mtx.Unlock()
+		return nil
+	}
+	entry := data{
+		val:        val,
+		ttl:        ttl,
+		ttlTime:    expiresAt(now, ttl),
+		createdAt:  now,
+		generation: g.nextGeneration(now),
+		lastAccess: now,
+		hits:       old.hits,
+	}
+	entry.schemaVersion = g.schemaVersion
+	if live {
+		entry.createdAt = old.createdAt
+		entry.priority = old.priority
+		entry.tags = old.tags
+		entry.dependsOn = old.dependsOn
+	}
+	// local 쓰기는 tombstone 을 지운다
+	delete(g.tombstones, key)
+	g.putLocked(key, entry)
+	deps := g.dependentsLocked([]string{key})
+	g.deleteDependentsLocked(deps, now)
+	evicted := g.evictLocked(now)
+	g.writes++
+	g.mtx.Unlock()
+
+	g.resize(key, entry.generation, val)
+	g.notify(key, OpSet, val)
+	g.dependentsDeleted(deps)
+	g.notifyEvicted(evicted)
+	g.enforceBudget()
+	g.propagateSet([]setEntry{{Key: key, Value: val, TTL: entry.ttl, Generation: entry.generation, Priority: entry.priority, DependsOn: entry.dependsOn, SchemaVersion: entry.schemaVersion}})
+	return nil
+}
+
+func (g *group) GetAll(key string) ([]any, error) {
+	val, err := g.get(context.Background(), g.normalizeKey(key))
+	if err != nil {
+		return nil, err
+	}
+	list, ok := val.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s %w: %T is not a list", key, ErrTypeMismatch, val)
+	}
+	list = slices.Clone(list)
+	if g.cloneOnRead != nil {
+		for i, item := range list {
+			if list[i], err = g.cloneValue(key, item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func (g *group) SetMissing(key string) {
+	key = g.normalizeKey(key)
+	if g.keyTooLong(key) {
+		return
+	}
+	now := time.Now()
+	ttl := g.defaultTTL()
+	data := data{
+		missing:   true,
+		ttl:       ttl,
+		ttlTime:   expiresAt(now, ttl),
+		createdAt: now,
+		size:      g.sizeOf(key, nil),
+	}
+	g.mtx.Lock()
+	data.generation = g.nextGeneration(now)
+	data.lastAccess = now
+	data.schemaVersion = g.schemaVersion
+	g.putLocked(key, data)
+	evicted := g.evictLocked(now)
+	g.writes++
+	g.mtx.Unlock()
+	g.notifyEvicted(evicted)
+	g.enforceBudget()
+}
+
+func (g *group) SetTags(key string, tags ...string) {
+	key = g.normalizeKey(key)
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	data, ok := g.data[key]
+	if !ok {
+		return
+	}
+	data.tags = append([]string(nil), tags...)
+	g.data[key] = data
+	g.writes++
+}
+
+func (g *group) Del(key string) {
+	key = g.normalizeKey(key)
+	keys := g.deleteKeys([]string{key})
+
+	// cache peer send delete
+	g.delRemote(keys)
+}
+
+// DeleteResult reports how a delete propagated to the peers, see DelWithResult.
+type DeleteResult struct {
+	// peers that applied the delete
+	Acknowledged int
+	// error of each peer that could not be reached or failed to apply the delete
+	Failed map[string]error
+}
+
+// Complete reports whether every peer applied the delete.
+func (r DeleteResult) Complete() bool {
+	return len(r.Failed) == 0
+}
+
+func (r *DeleteResult) add(peer string, err error) {
+	if err == nil {
+		r.Acknowledged++
+		return
+	}
+	if r.Failed == nil {
+		r.Failed = make(map[string]error)
+	}
+	r.Failed[peer] = err
+}
+
+func (g *group) DelWithResult(key string) DeleteResult {
+	keys := g.deleteKeys([]string{g.normalizeKey(key)})
+	if g.store != nil {
+		for _, key := range keys {
+			g.store.Del(context.Background(), g.name, key)
+		}
+	}
+	if g.peerDelete == nil {
+		return DeleteResult{}
+	}
+	return g.peerDelete(keys)
+}
+
+func (g *group) GetAndDelete(key string) (any, bool) {
+	key = g.normalizeKey(key)
+	g.mtx.Lock()
+	now := time.Now()
+	data, found := g.data[key]
+	keys := g.deleteKeysLocked([]string{key}, now)
+	g.mtx.Unlock()
+
+	for _, key := range keys {
+		g.notify(key, OpDelete, nil)
+	}
+	g.delRemote(keys)
+
+	if !found || data.missing || now.After(data.ttlTime) {
+		return nil, false
+	}
+	return data.val, true
+}
+
+func (g *group) DelBatch(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if g.keyNormalizer != nil {
+		keys = slices.Clone(keys)
+		for i, key := range keys {
+			keys[i] = g.keyNormalizer(key)
+		}
+	}
+	keys = g.deleteKeys(keys)
+	g.delRemote(keys)
+}
+
+// deleteKeys removes keys and their dependents locally under a single lock
+// without propagating to peers. It returns keys followed by the dependents.
+func (g *group) deleteKeys(keys []string) []string {
+	return g.deleteKeysFrom(keys, "")
+}
+
+// deleteKeysFrom is deleteKeys on behalf of origin, see notifyFrom.
+func (g *group) deleteKeysFrom(keys []string, origin string) []string {
+	g.mtx.Lock()
+	keys = g.deleteKeysLocked(keys, time.Now())
+	g.mtx.Unlock()
+
+	for _, key := range keys {
+		g.notifyFrom(key, OpDelete, nil, origin)
+	}
+	return keys
+}
+
+// deleteKeysLocked is deleteKeys without the notifications. Must be called with g.mtx held.
+func (g *group) deleteKeysLocked(keys []string, now time.Time) []string {
+	keys = append(slices.Clip(keys), g.dependentsLocked(keys)...)
+	for _, key := range keys {
+		g.deleteLocked(key)
+		g.tombstoneLocked(key, now)
+		g.discardFillsLocked(key)
+	}
+	g.writes++
+	return keys
+}
+
+// propagateDelete queues a delete event for the peers. It is a no-op on a
+// single node cache, and never blocks past the cache being closed.
+func (g *group) propagateDelete(event deleteEvent) {
+	if g.deleteChan == nil {
+		return
+	}
+
+	if g.dropDeletes {
+		select {
+		case g.deleteChan <- event:
+		default:
+		}
+		return
+	}
+	select {
+	case g.deleteChan <- event:
+	case <-g.done:
+	}
+}
+
+// flush removes every entry of the group and returns the number of removed entries.
+func (g *group) flush() int {
+	g.mtx.Lock()
+	keys := make([]string, 0, len(g.data))
+	for key := range g.data {
+		keys = append(keys, key)
+	}
+	clear(g.data)
+	clear(g.index)
+	clear(g.dependents)
+	g.evictOrder = nil
+	g.addBytes(-g.bytes)
+	g.writes++
+	g.mtx.Unlock()
+
+	for _, key := range keys {
+		g.notify(key, OpDelete, nil)
+	}
+	return len(keys)
+}
+
+// entry returns the stored entry of key without refreshing its ttl.
+func (g *group) entry(key string) (data, bool) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	data, ok := g.data[key]
+	return data, ok
+}
+
+func (g *group) ttlCleanUp(now time.Time) (scanned, removed int) {
+	start := time.Now()
+	var expired map[string]data
+	if g.copyOnCleanup {
+		scanned, expired = g.cleanupCopy(now)
+	} else {
+		g.mtx.Lock()
+		scanned = len(g.data)
+		expired = make(map[string]data)
+		for key, val := range g.data {
+			if g.removable(val, now) {
+				g.deleteLocked(key)
+				expired[key] = val
+			}
+		}
+		g.writes++
+		g.mtx.Unlock()
+	}
+	if g.tombstoneTTL > 0 {
+		g.mtx.Lock()
+		g.purgeTombstones(now)
+		g.mtx.Unlock()
+	}
+	g.stats.lastCleanupScanned.Store(int64(scanned))
+	g.stats.lastCleanupRemoved.Store(int64(len(expired)))
+	g.stats.lastCleanupDuration.Store(int64(time.Since(start)))
+	g.autoCompact(scanned, g.compactRatio)
+
+	keys := make([]string, 0, len(expired))
+	for key, val := range expired {
+		g.expired(key, val)
+		keys = append(keys, key)
+	}
+	g.deleteDependents(keys)
+	return scanned, len(expired)
+}
+
+// expired reports an entry removed because its ttl expired. Must be called without g.mtx held.
+// dropExpired removes the entry of key stored with generation if it is expired
+// past its retention, see removable, and reports the expiry. An entry replaced or
+// deleted meanwhile is left alone. Must be called without g.mtx held.
+func (g *group) dropExpired(key string, generation uint64) {
+	now := time.Now()
+	g.mtx.Lock()
+	cur, ok := g.data[key]
+	if !ok || cur.generation != generation || !g.removable(cur, now) {
+		g.mtx.Unlock()
+		return
+	}
+	g.deleteLocked(key)
+	g.writes++
+	g.mtx.Unlock()
+	g.expired(key, cur)
+	g.deleteDependents([]string{key})
+}
+
+func (g *group) expired(key string, d data) {
+	// cleanup 지연과 무관하게 만료 시점까지의 수명
+	g.stats.expiredLifetimes[lifetimeBucket(d.ttlTime.Sub(d.createdAt), d.ttl)].Add(1)
+	g.debug("cache expired", key)
+	g.notify(key, OpExpire, d.val)
+	if g.onExpire != nil && !d.missing {
+		g.onExpire(key, d.val)
+	}
+}
+
+// cleanupCopy builds the surviving entries under the read lock and swaps them in
+// under the write lock. When the group was written in the meantime, the copy is
+// stale and the expired keys are deleted one by one instead.
+func (g *group) cleanupCopy(now time.Time) (int, map[string]data) {
+	g.mtx.RLock()
+	scanned := len(g.data)
+	writes := g.writes
+	expired := make(map[string]data)
+	for key, val := range g.data {
+		if g.removable(val, now) {
+			expired[key] = val
+		}
+	}
+	// 만료된 entry 가 없으면 복사하지 않는다
+	var survivors map[string]data
+	if len(expired) != 0 {
+		survivors = make(map[string]data, len(g.data)-len(expired))
+		for key, val := range g.data {
+			if _, ok := expired[key]; !ok {
+				survivors[key] = val
+			}
+		}
+	}
+	g.mtx.RUnlock()
+
+	if len(expired) == 0 {
+		return scanned, expired
+	}
+
+	g.mtx.Lock()
+	if g.writes == writes {
+		g.data = survivors
+		for key, val := range expired {
+			g.evictOrder.remove(key)
+			g.indexRemove(key, val)
+			g.dependencyRemove(key, val)
+			g.addBytes(-val.size)
+		}
+	} else {
+		for key := range expired {
+			// 그 사이 다시 설정된 key 는 남긴다
+			if val, ok := g.data[key]; ok && g.removable(val, now) {
+				g.deleteLocked(key)
+			} else {
+				delete(expired, key)
+			}
+		}
+	}
+	g.writes++
+	g.mtx.Unlock()
+	return scanned, expired
+}
+
+func (g *group) Compact() {
+	g.mtx.Lock()
+	g.compactLocked()
+	g.mtx.Unlock()
+}
+
+// compactLocked must be called with g.mtx held.
+func (g *group) compactLocked() {
+	// maps.Clone 은 기존 bucket 크기를 유지하므로 직접 복사
+	data := make(map[string]data, len(g.data))
+	for key, val := range g.data {
+		data[key] = val
+	}
+	g.data = data
+	g.peakEntries = len(data)
+	g.writes++
+}
+
+// autoCompact compacts the group when the live entries dropped below ratio
+// of the peak. The peak is sampled on each write and each cleanup, scanned being
+// the entries the cleanup found.
+func (g *group) autoCompact(scanned int, ratio float64) {
+	if ratio <= 0 {
+		return
+	}
+	g.mtx.Lock()
+	g.peakEntries = max(g.peakEntries, scanned)
+	if g.peakEntries >= minAutoCompactEntries && float64(len(g.data)) < ratio*float64(g.peakEntries) {
+		if g.logger != nil {
+			g.logger.Debug("cache compacted", "group", g.name, "entries", len(g.data), "peak", g.peakEntries)
+		}
+		g.compactLocked()
+	}
+	g.mtx.Unlock()
+}
+
+// debug logs a per-request cache event when the logger is enabled for debug level.
+func (g *group) debug(msg, key string, args ...any) {
+	if g.logger == nil || !g.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	g.logger.Debug(msg, append([]any{"group", g.name, "key", key}, args...)...)
+}
+
+// notify sends a change event without blocking; events are dropped when the buffer is full.
+func (g *group) notify(key string, op Op, val any) {
+	g.notifyFrom(key, op, val, "")
+}
+
+// notifyFrom reports a mutation made on behalf of origin, a peer, or of this
+// node when empty.
+func (g *group) notifyFrom(key string, op Op, val any, origin string) {
+	if g.audit != nil {
+		g.audit.record(g.name, key, op, origin)
+	}
+	if g.changeChan == nil {
+		return
+	}
+	select {
+	case g.changeChan <- changeEvent{group: g.name, key: key, op: op, val: val}:
+	default:
+	}
+}
+
+// Entry is a snapshot of a cached entry returned by Entries.
+type Entry struct {
+	Key   string
+	Value any
+	// remaining ttl at the time of the snapshot
+	TTL       time.Duration
+	CreatedAt time.Time
+}
+
+// EntryMeta describes a cached entry, see Group.Metadata.
+type EntryMeta struct {
+	CreatedAt time.Time
+	// remaining ttl
+	TTL time.Duration
+	// bytes measured by the sizer, 0 without WithSizer or Config.MaxTotalBytes
+	Size int64
+	// reads counted while sliding the ttl; every read is counted only in groups
+	// touching on each read, e.g. with WithMaxEntries
+	Hits       int64
+	Generation uint64
+}
+
+func (g *group) Metadata(key string) (EntryMeta, bool) {
+	now := time.Now()
+	g.mtx.RLock()
+	data, ok := g.data[g.normalizeKey(key)]
+	g.mtx.RUnlock()
+	if !ok || data.missing || now.After(data.ttlTime) {
+		return EntryMeta{}, false
+	}
+	return EntryMeta{
+		CreatedAt:  data.createdAt,
+		TTL:        data.ttlTime.Sub(now),
+		Size:       data.size,
+		Hits:       data.hits,
+		Generation: data.generation,
+	}, true
+}
+
+func (g *group) Entries() []Entry {
+	now := time.Now()
+	g.mtx.RLock()
+	entries := make([]Entry, 0, len(g.data))
+	for key, data := range g.data {
+		if data.missing || now.After(data.ttlTime) {
+			continue
+		}
+		entries = append(entries, Entry{
+			Key:       key,
+			Value:     data.val,
+			TTL:       data.ttlTime.Sub(now),
+			CreatedAt: data.createdAt,
+		})
+	}
+	g.mtx.RUnlock()
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return entries
+}
+
+// entryView is the exported representation of an entry used when marshaling a group.
+type entryView struct {
+	Value      any       `json:"value"`
+	TTL        string    `json:"ttl"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Missing    bool      `json:"missing,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Generation uint64    `json:"generation"`
+}
+
+func (d data) view() entryView {
+	return entryView{
+		Value:      d.val,
+		TTL:        d.ttl.String(),
+		ExpiresAt:  d.ttlTime,
+		CreatedAt:  d.createdAt,
+		Missing:    d.missing,
+		Tags:       d.tags,
+		Generation: d.generation,
+	}
+}
+
+func (d data) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.view())
+}
+
+func (g *group) views() map[string]entryView {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	views := make(map[string]entryView, len(g.data))
+	for key, data := range g.data {
+		views[key] = data.view()
+	}
+	return views
+}
+
+// groupPage is a page of the entries of a group, sorted by key.
+type groupPage struct {
+	// number of entries of the group, expired ones included
+	Total   int                  `json:"total"`
+	Entries map[string]entryView `json:"entries"`
+}
+
+// page returns up to limit entries starting at offset in key order. Only the
+// keys are copied for the whole group.
+func (g *group) page(offset, limit int) groupPage {
+	g.mtx.RLock()
+	keys := make([]string, 0, len(g.data))
+	for key := range g.data {
+		keys = append(keys, key)
+	}
+	g.mtx.RUnlock()
+	slices.Sort(keys)
+
+	page := groupPage{Total: len(keys)}
+	keys = keys[min(offset, len(keys)):]
+	keys = keys[:min(limit, len(keys))]
+	page.Entries = make(map[string]entryView, len(keys))
+	g.mtx.RLock()
+	for _, key := range keys {
+		// 그 사이 삭제된 key 는 건너뛴다
+		if data, ok := g.data[key]; ok {
+			page.Entries[key] = data.view()
+		}
+	}
+	g.mtx.RUnlock()
+	return page
+}
+
+func (g *group) marshal() ([]byte, error) {
+	return g.codec.Marshal(g.views())
 }
 
+// JSONMarshal encodes every entry, expired and missing ones included, as a JSON
+// object keyed and sorted by key, so that dumps of the same entries can be diffed.
 func (g *group) JSONMarshal() ([]byte, error) {
-	return json.Marshal(g.data)
+	return json.Marshal(g.views())
 }
 
 func (g *group) JSONMarshalIndent(prefix, indent string) ([]byte, error) {
-	return json.MarshalIndent(g.data, prefix, indent)
+	return json.MarshalIndent(g.views(), prefix, indent)
 }
@@ -1,7 +1,15 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -71,3 +79,2023 @@ func TestGroup_Delete(t *testing.T) {
 		t.Fatal("expected delete event")
 	}
 }
+
+func TestGroup_SinkExtensions(t *testing.T) {
+	var cnt int = 0
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt += 1
+		if key == "missingKey" {
+			SetMissing(dest, key)
+			return nil
+		}
+		SetWithTTL(dest, key, "value for "+key, time.Millisecond*100)
+		SetTags(dest, key, "tag1")
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, []string{"tag1"}, group.data["testKey"].tags)
+	assert.Equal(t, time.Millisecond*100, group.data["testKey"].ttl)
+
+	// negative cached key does not call the getter again
+	_, err = group.Get(context.Background(), "missingKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = group.Get(context.Background(), "missingKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, cnt)
+}
+
+type mapStore map[string]any
+
+func (s mapStore) Get(ctx context.Context, group, key string) (any, error) {
+	val, ok := s[group+"/"+key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return val, nil
+}
+
+func (s mapStore) Set(ctx context.Context, group, key string, val any, ttl time.Duration) error {
+	s[group+"/"+key] = val
+	return nil
+}
+
+func (s mapStore) Del(ctx context.Context, group, key string) error {
+	delete(s, group+"/"+key)
+	return nil
+}
+
+func TestGroup_Store(t *testing.T) {
+	var cnt int = 0
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt += 1
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	store := mapStore{"testGroup/storedKey": "stored value"}
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithStore(store)(group)
+
+	// local miss -> store hit
+	val, err := group.Get(context.Background(), "storedKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "stored value", val)
+	assert.Equal(t, 0, cnt)
+
+	// local miss -> store miss -> getter, written through to the store
+	val, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, "value for testKey", store["testGroup/testKey"])
+}
+
+// lockCoordinator is a FillCoordinator backed by a mutex shared by the groups.
+type lockCoordinator struct {
+	mtx     sync.Mutex
+	waiting chan string
+}
+
+func (c *lockCoordinator) Acquire(ctx context.Context, group, key string) (func(), error) {
+	c.waiting <- key
+	c.mtx.Lock()
+	return c.mtx.Unlock, nil
+}
+
+func TestGroup_FillCoordinator(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	fc := &lockCoordinator{waiting: make(chan string, 2)}
+	nodeA := newGroup("testGroup", getter, time.Minute, nil)
+	nodeB := newGroup("testGroup", getter, time.Minute, nil)
+	WithFillCoordinator(fc)(nodeA)
+	WithFillCoordinator(fc)(nodeB)
+	// node B fetches from node A, the owner of the key
+	nodeB.peerFetch = func(ctx context.Context, key string) (setEntry, error) {
+		val, ok := nodeA.GetIfPresent(key)
+		if !ok {
+			return setEntry{}, ErrNotFound
+		}
+		return setEntry{Key: key, Value: val, TTL: time.Minute}, nil
+	}
+
+	go nodeA.Get(context.Background(), "testKey")
+	<-started
+
+	type result struct {
+		val any
+		src Source
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, src, err := nodeB.GetWithSource(context.Background(), "testKey")
+		done <- result{val, src, err}
+	}()
+	// node B waits for the lock held by node A
+	assert.Equal(t, "testKey", <-fc.waiting)
+	assert.Equal(t, "testKey", <-fc.waiting)
+	close(release)
+
+	r := <-done
+	assert.NoError(t, r.err)
+	assert.Equal(t, "value for testKey", r.val)
+	assert.Equal(t, PeerHit, r.src)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+type failingCoordinator struct{}
+
+func (failingCoordinator) Acquire(ctx context.Context, group, key string) (func(), error) {
+	return nil, errors.New("lock backend down")
+}
+
+func TestGroup_FillCoordinatorError(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithFillCoordinator(failingCoordinator{})(group)
+	var reported []error
+	group.reportError = func(err error) { reported = append(reported, err) }
+
+	// the key is filled without the lock
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Len(t, reported, 1)
+
+	WithFillCoordinator(NopFillCoordinator{})(group)
+	val, err = group.Get(context.Background(), "otherKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for otherKey", val)
+	assert.Len(t, reported, 1)
+}
+
+func TestGroup_SizerUnlocked(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	var locked int
+	WithSizer(func(key string, val any) int64 {
+		if !group.mtx.TryLock() {
+			locked++
+			return 0
+		}
+		group.mtx.Unlock()
+		return int64(len(key))
+	})(group)
+
+	group.Set("key1", "value")
+	group.SetMissing("key22")
+	assert.NoError(t, group.Update("key333", func(old any, existed bool) (any, bool) {
+		return "value", true
+	}))
+	assert.Zero(t, locked)
+	assert.EqualValues(t, 4+5+6, group.Stats().Bytes)
+	assert.EqualValues(t, 6, group.data["key333"].size)
+}
+
+func TestGroup_Metadata(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithMaxEntries(10)(group)
+	WithSizer(func(key string, val any) int64 { return 42 })(group)
+
+	_, ok := group.Metadata("testKey")
+	assert.False(t, ok)
+
+	group.Set("testKey", "value")
+	group.Set("testKey", "value2")
+	group.GetIfPresent("testKey")
+	group.GetIfPresent("testKey")
+
+	meta, ok := group.Metadata("testKey")
+	assert.True(t, ok)
+	assert.EqualValues(t, 42, meta.Size)
+	assert.EqualValues(t, 2, meta.Hits)
+	assert.Equal(t, group.data["testKey"].generation, meta.Generation)
+	assert.WithinDuration(t, time.Now(), meta.CreatedAt, time.Second)
+	assert.InDelta(t, time.Minute, meta.TTL, float64(time.Second))
+
+	// expired and missing entries have no metadata
+	group.SetWithTTL("expiredKey", "value", time.Millisecond)
+	group.SetMissing("missingKey")
+	time.Sleep(5 * time.Millisecond)
+	_, ok = group.Metadata("expiredKey")
+	assert.False(t, ok)
+	_, ok = group.Metadata("missingKey")
+	assert.False(t, ok)
+}
+
+func TestGroup_Snapshot(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("key2", "value2")
+	group.Set("key1", "value1")
+	group.SetMissing("missingKey")
+
+	view := group.Snapshot()
+	group.Set("key1", "changed")
+	group.Set("key3", "value3")
+	group.Del("key2")
+
+	assert.Equal(t, 2, view.Len())
+	e, ok := view.Get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "value1", e.Value)
+	_, ok = view.Get("key3")
+	assert.False(t, ok)
+	_, ok = view.Get("missingKey")
+	assert.False(t, ok)
+
+	var keys []string
+	view.Range(func(e Entry) bool {
+		keys = append(keys, e.Key)
+		return true
+	})
+	assert.Equal(t, []string{"key1", "key2"}, keys)
+
+	// keys are normalized like Get
+	WithKeyNormalizer(strings.ToLower)(group)
+	e, ok = group.Snapshot().Get("KEY1")
+	assert.True(t, ok)
+	assert.Equal(t, "changed", e.Value)
+}
+
+func TestGroup_CloneOnRead(t *testing.T) {
+	type profile struct {
+		Name string
+		Tags []string
+	}
+	ctx := context.Background()
+
+	shared := newGroup("testGroup", nil, time.Minute, nil)
+	shared.Set("key1", &profile{Name: "a", Tags: []string{"x"}})
+	val, err := shared.Get(ctx, "key1")
+	assert.NoError(t, err)
+	val.(*profile).Name = "changed"
+	val, _ = shared.Get(ctx, "key1")
+	assert.Equal(t, "changed", val.(*profile).Name)
+
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithCloneOnRead(nil)(group)
+	group.Set("key1", &profile{Name: "a", Tags: []string{"x"}})
+	val, err = group.Get(ctx, "key1")
+	assert.NoError(t, err)
+	val.(*profile).Name = "changed"
+	val.(*profile).Tags[0] = "changed"
+	val, _ = group.GetIfPresent("key1")
+	assert.Equal(t, &profile{Name: "a", Tags: []string{"x"}}, val)
+
+	group.Set("key2", func() {})
+	_, err = group.Get(ctx, "key2")
+	assert.Error(t, err)
+
+	custom := newGroup("testGroup", nil, time.Minute, nil)
+	WithCloneOnRead(func(val any) any {
+		return slices.Clone(val.([]int))
+	})(custom)
+	custom.Set("key1", []int{1, 2})
+	val, _, err = custom.GetWithSource(ctx, "key1")
+	assert.NoError(t, err)
+	val.([]int)[0] = 9
+	val, _ = custom.GetIfPresent("key1")
+	assert.Equal(t, []int{1, 2}, val)
+
+	// GetByIndex and the items of GetAll are copied too
+	indexed := newGroup("testGroup", nil, time.Minute, nil)
+	WithCloneOnRead(nil)(indexed)
+	WithIndex(func(val any) []string {
+		if p, ok := val.(*profile); ok {
+			return []string{p.Name}
+		}
+		return nil
+	})(indexed)
+	indexed.Set("key1", &profile{Name: "a"})
+	indexed.Append("list", &profile{Name: "b"})
+	indexed.GetByIndex("a")[0].(*profile).Name = "changed"
+	assert.Equal(t, []any{&profile{Name: "a"}}, indexed.GetByIndex("a"))
+	list, err := indexed.GetAll("list")
+	assert.NoError(t, err)
+	list[0].(*profile).Name = "changed"
+	list, _ = indexed.GetAll("list")
+	assert.Equal(t, []any{&profile{Name: "b"}}, list)
+}
+
+func TestGroup_NilGetter(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, val)
+
+	group.Set("testKey", "testValue")
+	val, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "testValue", val)
+}
+
+func TestGroup_GetIfPresent(t *testing.T) {
+	var cnt int = 0
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt += 1
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+
+	val, ok := group.GetIfPresent("testKey")
+	assert.False(t, ok)
+	assert.Nil(t, val)
+	assert.Equal(t, 0, cnt)
+
+	group.Get(context.Background(), "testKey")
+	val, ok = group.GetIfPresent("testKey")
+	assert.True(t, ok)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, 1, cnt)
+
+	// one miss each for GetIfPresent and Get, one hit for GetIfPresent
+	stats := group.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+}
+
+func TestGroup_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		close(started)
+		<-release
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+
+	done := make(chan struct{})
+	go func() {
+		group.Get(context.Background(), "testKey")
+		close(done)
+	}()
+
+	<-started
+	assert.Equal(t, []string{"testKey"}, group.InFlight())
+	assert.Equal(t, []string{"testKey"}, group.Stats().InFlightKeys)
+	close(release)
+	<-done
+	assert.Empty(t, group.InFlight())
+	assert.Empty(t, group.Stats().InFlightKeys)
+}
+
+func TestGroup_FillDeduplicated(t *testing.T) {
+	var replica, source atomic.Int32
+	release := make(chan struct{})
+	chain := getterChain{
+		GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			replica.Add(1)
+			<-release
+			return errors.New("replica miss")
+		}),
+		GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			source.Add(1)
+			dest.Set(key, "value for "+key)
+			return nil
+		}),
+	}
+	group := newGroup("testGroup", chain, time.Minute, nil)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := group.Get(context.Background(), "testKey")
+			assert.NoError(t, err)
+			assert.Equal(t, "value for testKey", val)
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		group.mtx.RLock()
+		defer group.mtx.RUnlock()
+		return group.misses["testKey"] != nil
+	}, time.Second, time.Millisecond)
+	// let the other Gets reach the running fill
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// the whole chain ran once for the concurrent misses
+	assert.Equal(t, int32(1), replica.Load())
+	assert.Equal(t, int32(1), source.Load())
+	assert.Empty(t, group.misses)
+	assert.Equal(t, int64(1), group.Stats().GetterCalls)
+}
+
+func TestGroup_FillDeduplicatedCanceled(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 2)
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if calls.Add(1) == 1 {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		dest.Set(key, "value for "+key)
+		return nil
+	}), time.Minute, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := group.Get(ctx, "testKey")
+		first <- err
+	}()
+	<-started
+	second := make(chan any)
+	go func() {
+		val, _ := group.Get(context.Background(), "testKey")
+		second <- val
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	// the waiting Get fills the key again instead of failing with the other's context
+	assert.ErrorIs(t, <-first, context.Canceled)
+	assert.Equal(t, "value for testKey", <-second)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGroup_SetMulti(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.setChan = make(chan setEvent, 1)
+
+	group.SetMulti(map[string]any{"key1": "value1", "key2": "value2"})
+
+	val, ok := group.GetIfPresent("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "value1", val)
+	val, ok = group.GetIfPresent("key2")
+	assert.True(t, ok)
+	assert.Equal(t, "value2", val)
+
+	// entries are propagated in a single event
+	select {
+	case event := <-group.setChan:
+		assert.Equal(t, "testGroup", event.group)
+		assert.Len(t, event.entries, 2)
+	default:
+		t.Fatal("expected set event")
+	}
+}
+
+func TestGroup_PropagateAfterHits(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithPropagateAfterHits(2)(group)
+	group.setChan = make(chan setEvent, 10)
+
+	group.Set("key", "value")
+	assert.Empty(t, group.setChan)
+
+	ctx := context.Background()
+	for range 2 {
+		group.Get(ctx, "key")
+	}
+	assert.Empty(t, group.setChan)
+
+	// the third read makes the key hot
+	group.Get(ctx, "key")
+	assert.Len(t, group.setChan, 1)
+	event := <-group.setChan
+	assert.Equal(t, "key", event.entries[0].Key)
+	assert.Equal(t, "value", event.entries[0].Value)
+
+	group.Get(ctx, "key")
+	assert.Empty(t, group.setChan)
+
+	// writes of a hot key are propagated right away
+	group.Set("key", "value2")
+	assert.Len(t, group.setChan, 1)
+	event = <-group.setChan
+	assert.Equal(t, "value2", event.entries[0].Value)
+}
+
+func TestGroup_ShouldCache(t *testing.T) {
+	var cnt int = 0
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt += 1
+		dest.Set(key, "")
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithShouldCache(func(key string, val any) bool {
+		return val != ""
+	})(group)
+
+	// the value is returned but not cached
+	for range 2 {
+		val, err := group.Get(context.Background(), "testKey")
+		assert.NoError(t, err)
+		assert.Equal(t, "", val)
+	}
+	assert.Equal(t, 2, cnt)
+	assert.NotContains(t, group.data, "testKey")
+
+	// Update and Append store nothing the predicate rejects, nor propagate it
+	setChan := make(chan setEvent, 1)
+	group.setChan = setChan
+	group.Set("updateKey", "old")
+	<-setChan
+	assert.NoError(t, group.Update("updateKey", func(old any, existed bool) (any, bool) {
+		return "", true
+	}))
+	val, _ := group.GetIfPresent("updateKey")
+	assert.Equal(t, "old", val)
+	assert.Empty(t, setChan)
+
+	WithShouldCache(func(key string, val any) bool {
+		_, ok := val.([]any)
+		return !ok
+	})(group)
+	group.Append("listKey", "item")
+	assert.NotContains(t, group.data, "listKey")
+	assert.Empty(t, setChan)
+}
+
+func TestGroup_DeleteWithoutPeers(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("testKey", "testValue")
+
+	// single node cache does not block on delete propagation
+	group.Del("testKey")
+	_, ok := group.GetIfPresent("testKey")
+	assert.False(t, ok)
+
+	// a full queue drops the propagation when configured
+	group.deleteChan = make(chan deleteEvent)
+	group.dropDeletes = true
+	group.Del("testKey")
+}
+
+func TestGroup_SetWithTTLRange(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		SetWithTTLRange(dest, key, "value for "+key, time.Minute, time.Minute*2)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Hour, nil)
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		group.Get(context.Background(), key)
+		data, ok := group.entry(key)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, data.ttl, time.Minute)
+		assert.Less(t, data.ttl, time.Minute*2)
+	}
+}
+
+func TestGroup_SetDefaultTTL(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("key1", "value1")
+
+	group.SetDefaultTTL(time.Second)
+	group.Set("key2", "value2")
+
+	data1, _ := group.entry("key1")
+	data2, _ := group.entry("key2")
+	assert.Equal(t, time.Minute, data1.ttl)
+	assert.Equal(t, time.Second, data2.ttl)
+}
+
+func TestGroup_Generation(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("testKey", "value1")
+	first, _ := group.entry("testKey")
+	group.Set("testKey", "value2")
+	second, _ := group.entry("testKey")
+	assert.Greater(t, second.generation, first.generation)
+
+	// an older value pushed by a peer is ignored
+	group.setEntries([]setEntry{{Key: "testKey", Value: "stale", TTL: time.Minute, Generation: first.generation}})
+	val, _ := group.GetIfPresent("testKey")
+	assert.Equal(t, "value2", val)
+
+	// a newer one wins and later local sets stay ahead of it
+	group.setEntries([]setEntry{{Key: "testKey", Value: "newer", TTL: time.Minute, Generation: second.generation + 100}})
+	val, _ = group.GetIfPresent("testKey")
+	assert.Equal(t, "newer", val)
+	group.Set("testKey", "value3")
+	third, _ := group.entry("testKey")
+	assert.Greater(t, third.generation, second.generation+100)
+}
+
+func TestGroup_RefreshAhead(t *testing.T) {
+	var cnt atomic.Int32
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		n := cnt.Add(1)
+		dest.Set(key, fmt.Sprintf("value%d", n))
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Millisecond*200, nil)
+	WithRefreshAhead(time.Millisecond * 150)(group)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	// outside the window nothing is refreshed
+	group.Get(context.Background(), "testKey")
+	assert.Equal(t, int32(1), cnt.Load())
+
+	// within the window the current value is served while it is refreshed
+	time.Sleep(time.Millisecond * 100)
+	val, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	assert.Eventually(t, func() bool {
+		val, _ := group.GetIfPresent("testKey")
+		return val == "value2"
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, int32(2), cnt.Load())
+}
+
+func TestGroup_WithKeyLock(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+
+	// calls for the same key never overlap
+	var running, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.WithKeyLock("testKey", func() {
+				if running.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+			})
+		}()
+	}
+
+	// another key is not blocked by a held lock
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go group.WithKeyLock("heldKey", func() {
+		close(held)
+		<-release
+	})
+	<-held
+	done := make(chan struct{})
+	go group.WithKeyLock("otherKey", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("otherKey blocked by heldKey")
+	}
+	close(release)
+
+	wg.Wait()
+	assert.Zero(t, overlaps.Load())
+	assert.Eventually(t, func() bool {
+		group.keyLocksMtx.Lock()
+		defer group.keyLocksMtx.Unlock()
+		return len(group.keyLocks) == 0
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestGroup_NoExpiry(t *testing.T) {
+	group := newGroup("testGroup", nil, 10*time.Millisecond, nil)
+	group.SetWithTTL("permanentKey", "value", NoExpiry)
+	group.Set("ephemeralKey", "value")
+	time.Sleep(20 * time.Millisecond)
+
+	group.ttlCleanUp(time.Now())
+	_, ok := group.GetIfPresent("ephemeralKey")
+	assert.False(t, ok)
+	val, ok := group.GetIfPresent("permanentKey")
+	assert.True(t, ok)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, NoExpiry, group.Entries()[0].TTL)
+
+	// kept across a snapshot
+	var buf bytes.Buffer
+	assert.NoError(t, group.SnapshotTo(&buf))
+	restored := newGroup("testGroup", nil, 10*time.Millisecond, nil)
+	assert.NoError(t, restored.RestoreFrom(&buf))
+	assert.Equal(t, NoExpiry, restored.data["permanentKey"].ttl)
+
+	// still evicted by capacity and deleted explicitly
+	WithMaxEntries(1)(restored)
+	restored.SetWithTTL("otherKey", "value", NoExpiry)
+	assert.Len(t, restored.data, 1)
+	restored.Del("otherKey")
+	assert.Empty(t, restored.data)
+}
+
+func TestGroup_AdaptiveTTL(t *testing.T) {
+	group := newGroup("testGroup", nil, 10*time.Second, nil)
+	WithAdaptiveTTL(nil)(group)
+	ctx := context.Background()
+
+	remaining := func(key string) time.Duration {
+		return time.Until(group.data[key].ttlTime)
+	}
+	group.Set("hotKey", "value")
+	group.Set("coldKey", "value")
+	group.Get(ctx, "coldKey")
+	for range 7 {
+		group.Get(ctx, "hotKey")
+	}
+	assert.InDelta(t, 10*time.Second, remaining("coldKey"), float64(time.Second))
+	assert.InDelta(t, 20*time.Second, remaining("hotKey"), float64(time.Second))
+
+	// clamped by the ttl bounds
+	WithTTLBounds(0, 15*time.Second)(group)
+	group.Get(ctx, "hotKey")
+	assert.InDelta(t, 15*time.Second, remaining("hotKey"), float64(time.Second))
+
+	// custom scale, the stored ttl is not compounded
+	WithAdaptiveTTL(func(ttl time.Duration, hits int64) time.Duration {
+		return ttl / 2
+	})(group)
+	group.Get(ctx, "hotKey")
+	group.Get(ctx, "hotKey")
+	assert.InDelta(t, 5*time.Second, remaining("hotKey"), float64(time.Second))
+}
+
+func TestGroup_WriteBatchSize(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithWriteBatchSize(10)(group)
+
+	// count the lock acquisitions through the writes counter
+	items := make(map[string]any)
+	for i := range 25 {
+		items[fmt.Sprintf("key%d", i)] = i
+	}
+	group.SetMulti(items)
+	assert.EqualValues(t, 3, group.writes)
+	assert.Len(t, group.data, 25)
+
+	var buf bytes.Buffer
+	assert.NoError(t, group.SnapshotTo(&buf))
+	restored := newGroup("testGroup", nil, time.Minute, nil)
+	WithWriteBatchSize(10)(restored)
+	assert.NoError(t, restored.RestoreFrom(&buf))
+	assert.EqualValues(t, 3, restored.writes)
+	assert.Len(t, restored.data, 25)
+
+	stats := restored.ResetStats()
+	assert.Positive(t, stats.MaxWriteLock)
+	assert.Zero(t, restored.Stats().MaxWriteLock)
+}
+
+func TestGroup_ServeStaleOnExpiry(t *testing.T) {
+	var cnt atomic.Int32
+	release := make(chan struct{})
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		n := cnt.Add(1)
+		if n == 1 {
+			dest.(TTLSink).SetWithTTL(key, "value1", time.Millisecond*20)
+			return nil
+		}
+		<-release
+		dest.Set(key, fmt.Sprintf("value%d", n))
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithServeStaleOnExpiry(time.Minute)(group)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+	time.Sleep(time.Millisecond * 30)
+
+	// the expired value is returned while the getter is still blocked
+	val, src, err := group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+	assert.Equal(t, StaleHit, src)
+
+	// concurrent Gets are served from the running refill instead of calling the getter
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, src, err := group.GetWithSource(context.Background(), "testKey")
+			assert.NoError(t, err)
+			assert.Equal(t, "value1", val)
+			assert.Equal(t, StaleHit, src)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 6, group.Stats().StaleHits)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		val, _ := group.GetIfPresent("testKey")
+		return val == "value2"
+	}, time.Second, time.Millisecond*5)
+	assert.Equal(t, int32(2), cnt.Load())
+}
+
+func TestGroup_CircuitBreaker(t *testing.T) {
+	var cnt int
+	failing := true
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt++
+		if failing {
+			return errors.New("backend down")
+		}
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithCircuitBreaker(2, time.Minute, time.Millisecond*100)(group)
+
+	for range 2 {
+		_, err := group.Get(context.Background(), "testKey")
+		assert.EqualError(t, err, "backend down")
+	}
+	assert.Equal(t, BreakerOpen, group.Stats().Breaker)
+
+	// open circuit fails fast without calling the getter
+	_, err := group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, cnt)
+
+	// after the cooldown a successful probe closes the circuit
+	time.Sleep(time.Millisecond * 150)
+	assert.Equal(t, BreakerHalfOpen, group.Stats().Breaker)
+	failing = false
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+	assert.Equal(t, BreakerClosed, group.Stats().Breaker)
+
+	stats := group.Stats()
+	assert.Equal(t, int64(3), stats.GetterCalls)
+	assert.Equal(t, int64(2), stats.GetterErrors)
+	assert.Equal(t, int64(4), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestBreaker_Canceled(t *testing.T) {
+	b := &breaker{threshold: 2, window: time.Minute, cooldown: time.Minute}
+	now := time.Now()
+
+	// a canceled call neither resets nor adds to the failures
+	b.record(errors.New("backend down"), now)
+	b.record(context.Canceled, now)
+	b.record(errors.New("backend down"), now)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// a canceled probe leaves the circuit half-open for the next probe
+	later := now.Add(time.Minute)
+	assert.True(t, b.allow(later))
+	b.record(fmt.Errorf("get: %w", context.Canceled), later)
+	assert.Equal(t, BreakerHalfOpen, b.state)
+	assert.True(t, b.allow(later))
+}
+
+func TestGroup_DebugLog(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	var buf bytes.Buffer
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	group.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	group.Get(context.Background(), "testKey")
+	group.Get(context.Background(), "testKey")
+	assert.Contains(t, buf.String(), "msg=\"cache fill\" group=testGroup key=testKey getter=true")
+	assert.Contains(t, buf.String(), "msg=\"cache hit\" group=testGroup key=testKey source=local")
+
+	// nothing is logged above debug level
+	buf.Reset()
+	group.logger = slog.New(slog.NewTextHandler(&buf, nil))
+	group.Get(context.Background(), "testKey")
+	assert.Empty(t, buf.String())
+}
+
+func TestGroup_KeyNormalizer(t *testing.T) {
+	var keys []string
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		keys = append(keys, key)
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithKeyNormalizer(strings.ToLower)(group)
+
+	val, err := group.Get(context.Background(), "TestKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for TestKey", val)
+	val, err = group.Get(context.Background(), "TESTKEY")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for TestKey", val)
+
+	// the getter sees the original key, the entry is stored under the normalized one
+	assert.Equal(t, []string{"TestKey"}, keys)
+	assert.Contains(t, group.data, "testkey")
+
+	group.Del("TESTkey")
+	assert.Empty(t, group.data)
+}
+
+func TestGroup_Entries(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("key2", "value2")
+	group.Set("key1", "value1")
+	group.SetWithTTL("expired", "value", -time.Second)
+	group.SetMissing("missing")
+
+	entries := group.Entries()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "key1", entries[0].Key)
+		assert.Equal(t, "value1", entries[0].Value)
+		assert.Equal(t, "key2", entries[1].Key)
+		assert.InDelta(t, time.Minute, entries[1].TTL, float64(time.Second))
+		assert.False(t, entries[1].CreatedAt.IsZero())
+	}
+}
+
+func TestGroup_SetGetter(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+
+	_, err := group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	group.SetGetter(GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		dest.Set(key, "value for "+key)
+		return nil
+	}))
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+}
+
+func TestGroup_ContextTTL(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if key == "explicitKey" {
+			SetWithTTL(dest, key, "value for "+key, time.Second)
+			return nil
+		}
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	ctx := ContextWithTTL(context.Background(), time.Hour)
+
+	group.Get(ctx, "testKey")
+	group.Get(ctx, "explicitKey")
+	group.Get(context.Background(), "defaultKey")
+
+	data, _ := group.entry("testKey")
+	assert.Equal(t, time.Hour, data.ttl)
+	data, _ = group.entry("explicitKey")
+	assert.Equal(t, time.Second, data.ttl)
+	data, _ = group.entry("defaultKey")
+	assert.Equal(t, time.Minute, data.ttl)
+}
+
+func TestGroup_GetInto(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("user", user{Name: "test"})
+	// the value a peer decoded with JSONCodec
+	group.Set("peerUser", map[string]any{"name": "peer"})
+
+	var u user
+	assert.NoError(t, group.GetInto(context.Background(), "user", &u))
+	assert.Equal(t, "test", u.Name)
+	assert.NoError(t, group.GetInto(context.Background(), "peerUser", &u))
+	assert.Equal(t, "peer", u.Name)
+
+	var n int
+	assert.ErrorIs(t, group.GetInto(context.Background(), "user", &n), ErrTypeMismatch)
+	assert.ErrorIs(t, group.GetInto(context.Background(), "missingKey", &u), ErrNotFound)
+	assert.Error(t, group.GetInto(context.Background(), "user", u))
+}
+
+func TestGroup_Eviction(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithMaxEntries(2)(group)
+
+	group.SetWithPriority("important", "value", 10)
+	group.Set("key1", "value1")
+	group.Set("key2", "value2")
+	// the lowest priority goes first, then the least recently used
+	assert.Len(t, group.data, 2)
+	assert.Contains(t, group.data, "important")
+	assert.Contains(t, group.data, "key2")
+
+	group.GetIfPresent("key2")
+	group.Set("key3", "value3")
+	assert.Contains(t, group.data, "key3")
+	assert.NotContains(t, group.data, "key2")
+	assert.Equal(t, int64(2), group.Stats().Evictions)
+}
+
+func TestGroup_EvictionLFU(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithMaxEntries(2)(group)
+	WithEvictionPolicy(EvictLFU)(group)
+
+	group.Set("key1", "value1")
+	group.Set("key2", "value2")
+	group.GetIfPresent("key1")
+	group.GetIfPresent("key1")
+	group.GetIfPresent("key2")
+
+	group.Set("key3", "value3")
+	assert.Contains(t, group.data, "key1")
+	assert.NotContains(t, group.data, "key2")
+}
+
+func TestGroup_EvictionSamples(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithMaxEntries(100)(group)
+	WithEvictionSamples(5)(group)
+
+	for i := range 90 {
+		group.Set(fmt.Sprintf("key%d", i), i)
+	}
+	group.SetWithPriority("important", "value", 10)
+	for i := range 200 {
+		group.Set(fmt.Sprintf("new%d", i), i)
+	}
+	assert.Len(t, group.data, 100)
+	assert.Equal(t, int64(191), group.Stats().Evictions)
+	// the priority is still honored among the samples, which are never all important
+	assert.Contains(t, group.data, "important")
+
+	// reads only take the write lock when the ttl is due to slide
+	assert.False(t, group.needsTouch(group.data["important"], time.Now()))
+}
+
+func TestGroup_EvictionOrder(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+		t.Run(policy.String(), func(t *testing.T) {
+			group := newGroup("testGroup", nil, time.Minute, nil)
+			WithMaxEntries(50)(group)
+			WithEvictionPolicy(policy)(group)
+
+			for i := range 200 {
+				key := fmt.Sprintf("key%d", i%80)
+				switch i % 7 {
+				case 0:
+					group.Del(fmt.Sprintf("key%d", i%13))
+				case 1:
+					group.SetWithPriority(key, i, i%3)
+				case 2:
+					group.SetWithTTL(key, i, -time.Second)
+				default:
+					group.Set(key, i)
+					group.GetIfPresent(fmt.Sprintf("key%d", i%11))
+				}
+			}
+			group.ttlCleanUp(time.Now())
+			if !assert.NotNil(t, group.evictOrder) {
+				return
+			}
+			assert.Len(t, group.evictOrder.items, len(group.data))
+
+			// no entry goes before the victim the order names
+			now := time.Now()
+			victim := group.victimLocked(now)
+			for key, d := range group.data {
+				assert.False(t, group.evictsBefore(d, group.data[victim], now), key)
+			}
+
+			group.flush()
+			assert.Nil(t, group.evictOrder)
+		})
+	}
+}
+
+func TestGroup_CopyOnCleanup(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithCopyOnCleanup()(group)
+	group.Set("key1", "value1")
+	group.SetWithTTL("expired1", "value", -time.Second)
+	group.SetWithTTL("expired2", "value", -time.Second)
+
+	scanned, removed := group.ttlCleanUp(time.Now())
+	assert.Equal(t, 3, scanned)
+	assert.Equal(t, 2, removed)
+	assert.Len(t, group.data, 1)
+	assert.Contains(t, group.data, "key1")
+}
+
+func TestGroup_CleanupStats(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("key1", "value1")
+	group.SetWithTTL("expired1", "value", -time.Second)
+
+	group.ttlCleanUp(time.Now())
+	stats := group.Stats()
+	assert.Equal(t, 2, stats.LastCleanupScanned)
+	assert.Equal(t, 1, stats.LastCleanupRemoved)
+	assert.Greater(t, stats.LastCleanupDuration, time.Duration(0))
+}
+
+func BenchmarkGroup_Get(b *testing.B) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	for i := range 1000 {
+		group.Set(fmt.Sprintf("key%d", i), i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			group.Get(context.Background(), fmt.Sprintf("key%d", i%1000))
+			i++
+		}
+	})
+}
+
+func BenchmarkGroup_TTLCleanUp(b *testing.B) {
+	for _, copyOnCleanup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("copy=%v", copyOnCleanup), func(b *testing.B) {
+			group := newGroup("testGroup", nil, time.Minute, nil)
+			group.copyOnCleanup = copyOnCleanup
+			for i := range 100000 {
+				group.Set(fmt.Sprintf("key%d", i), i)
+			}
+			b.ResetTimer()
+			for range b.N {
+				// 10% of the entries expire on every sweep
+				b.StopTimer()
+				for i := range 10000 {
+					group.SetWithTTL(fmt.Sprintf("expired%d", i), i, -time.Second)
+				}
+				b.StartTimer()
+				group.ttlCleanUp(time.Now())
+			}
+		})
+	}
+}
+
+func TestGroup_OperationTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if key == "slowKey" {
+			// ignores ctx
+			<-release
+		}
+		if key == "ctxKey" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		dest.Set(key, "value for "+key)
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithOperationTimeout(time.Millisecond * 50)(group)
+
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value for testKey", val)
+
+	for _, key := range []string{"slowKey", "ctxKey"} {
+		start := time.Now()
+		_, err = group.Get(context.Background(), key)
+		assert.ErrorIs(t, err, ErrTimeout, key)
+		assert.ErrorIs(t, err, context.DeadlineExceeded, key)
+		assert.Less(t, time.Since(start), time.Second, key)
+	}
+}
+
+func TestGroup_Multimap(t *testing.T) {
+	setChan := make(chan setEvent, 10)
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.setChan = setChan
+
+	_, err := group.GetAll("testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	group.Append("testKey", "event1")
+	group.Append("testKey", "event2")
+	list, err := group.GetAll("testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"event1", "event2"}, list)
+
+	// the whole list is propagated
+	<-setChan
+	event := <-setChan
+	assert.Equal(t, []any{"event1", "event2"}, event.entries[0].Value)
+
+	group.Set("otherKey", "value")
+	_, err = group.GetAll("otherKey")
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	group.Del("testKey")
+	_, err = group.GetAll("testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGroup_MaxKeyLength(t *testing.T) {
+	var calls int
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		dest.Set(key, "value")
+		return nil
+	}), time.Minute, nil)
+	WithMaxKeyLength(8)(group)
+
+	_, err := group.Get(context.Background(), "too-long-key")
+	assert.ErrorIs(t, err, ErrKeyTooLong)
+	assert.Equal(t, 0, calls)
+
+	group.Set("too-long-key", "value")
+	_, ok := group.GetIfPresent("too-long-key")
+	assert.False(t, ok)
+
+	val, err := group.Get(context.Background(), "short")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	// the limit applies to the normalized key
+	WithKeyNormalizer(func(key string) string { return key[:4] })(group)
+	_, err = group.Get(context.Background(), "too-long-key")
+	assert.NoError(t, err)
+}
+
+func TestGroup_GetAndDelete(t *testing.T) {
+	deleteChan := make(chan deleteEvent, 10)
+	group := newGroup("testGroup", nil, time.Minute, deleteChan)
+	group.Set("testKey", "testValue")
+
+	var wg sync.WaitGroup
+	var popped atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if val, ok := group.GetAndDelete("testKey"); ok {
+				assert.Equal(t, "testValue", val)
+				popped.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), popped.Load())
+	assert.Equal(t, deleteEvent{group: "testGroup", key: "testKey"}, <-deleteChan)
+
+	group.SetWithTTL("expired", "value", -time.Second)
+	_, ok := group.GetAndDelete("expired")
+	assert.False(t, ok)
+	assert.NotContains(t, group.data, "expired")
+	for len(deleteChan) != 0 {
+		<-deleteChan
+	}
+
+	// dependents are deleted like Del
+	group.Set("base", "value")
+	group.SetWithDependencies("derived", "value", "base")
+	val, ok := group.GetAndDelete("base")
+	assert.True(t, ok)
+	assert.Equal(t, "value", val)
+	assert.Empty(t, group.data)
+	assert.Equal(t, []string{"base", "derived"}, (<-deleteChan).keys)
+}
+
+func TestGroup_Compact(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithAutoCompact(0.25)(group)
+	keys := make([]string, 0, 2000)
+	for i := range 2000 {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+		group.Set(keys[i], i)
+	}
+	group.ttlCleanUp(time.Now())
+	assert.Equal(t, 2000, group.peakEntries)
+
+	group.DelBatch(keys[100:])
+	group.ttlCleanUp(time.Now())
+	assert.Equal(t, 100, group.peakEntries)
+
+	group.Compact()
+	assert.Len(t, group.data, 100)
+	val, ok := group.GetIfPresent("key99")
+	assert.True(t, ok)
+	assert.Equal(t, 99, val)
+}
+
+func TestGroup_TTLRefreshThreshold(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithTTLRefreshThreshold(10 * time.Second)(group)
+	group.Set("testKey", "testValue")
+	ttlTime := group.data["testKey"].ttlTime
+
+	_, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, ttlTime, group.data["testKey"].ttlTime)
+
+	// less than the threshold remains
+	data := group.data["testKey"]
+	data.ttlTime = time.Now().Add(5 * time.Second)
+	group.data["testKey"] = data
+	_, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), group.data["testKey"].ttlTime, time.Second)
+}
+
+func TestGroup_GetSkipsRecentTouch(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("testKey", "testValue")
+	writes := group.writes
+
+	_, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, writes, group.writes)
+
+	data := group.data["testKey"]
+	data.lastAccess = time.Now().Add(-time.Second)
+	group.data["testKey"] = data
+	_, err = group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, writes+1, group.writes)
+}
+
+func TestGroup_OnExpire(t *testing.T) {
+	expired := map[string]any{}
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithOnExpire(func(key string, val any) {
+		expired[key] = val
+	})(group)
+
+	group.SetWithTTL("readKey", "value1", -time.Second)
+	group.SetWithTTL("sweptKey", "value2", -time.Second)
+	group.Set("deletedKey", "value3")
+
+	_, err := group.Get(context.Background(), "readKey")
+	assert.ErrorIs(t, err, ErrExpired)
+	group.Del("deletedKey")
+	group.ttlCleanUp(time.Now())
+
+	assert.Equal(t, map[string]any{"readKey": "value1", "sweptKey": "value2"}, expired)
+}
+
+func TestGroup_ClassifyError(t *testing.T) {
+	errTimeout := errors.New("upstream timeout")
+	errGone := errors.New("upstream 404")
+	var calls int
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		if key == "goneKey" {
+			return errGone
+		}
+		return errTimeout
+	}), time.Minute, nil)
+	WithClassifyError(func(err error) ErrorClass {
+		if errors.Is(err, errGone) {
+			return ErrorNotFound
+		}
+		return ErrorTransient
+	})(group)
+
+	// transient errors serve the expired value
+	group.SetWithTTL("testKey", "staleValue", -time.Second)
+	val, stale, err := group.GetStale(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "staleValue", val)
+	assert.True(t, stale)
+	_, stale, _ = group.GetStale(context.Background(), "testKey")
+	assert.True(t, stale)
+	_, err = group.Get(context.Background(), "unknownKey")
+	assert.ErrorIs(t, err, errTimeout)
+
+	// not found errors are negatively cached
+	_, err = group.Get(context.Background(), "goneKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, errGone)
+	calls = 0
+	_, err = group.Get(context.Background(), "goneKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 0, calls)
+}
+
+func TestGroup_StaleIfError(t *testing.T) {
+	errDown := errors.New("upstream down")
+	var fail atomic.Bool
+	fail.Store(true)
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if fail.Load() {
+			return errDown
+		}
+		dest.Set(key, "freshValue")
+		return nil
+	}), time.Minute, nil)
+	WithStaleIfError(time.Hour)(group)
+
+	// expired values are kept by the cleanup until the retention ends
+	group.SetWithTTL("testKey", "oldValue", -time.Second)
+	group.ttlCleanUp(time.Now())
+	assert.Contains(t, group.data, "testKey")
+
+	val, src, err := group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "oldValue", val)
+	assert.Equal(t, StaleHit, src)
+
+	fail.Store(false)
+	_, err = group.Refresh(context.Background(), "testKey")
+	assert.NoError(t, err)
+	val, src, err = group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "freshValue", val)
+	assert.Equal(t, LocalHit, src)
+
+	group.SetWithTTL("oldKey", "oldValue", -time.Second)
+	group.ttlCleanUp(time.Now().Add(2 * time.Hour))
+	assert.NotContains(t, group.data, "oldKey")
+}
+
+func TestGroup_StaleIfErrorDeleted(t *testing.T) {
+	errDown := errors.New("upstream down")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		close(started)
+		<-release
+		return errDown
+	}), time.Minute, nil)
+	WithStaleIfError(time.Hour)(group)
+	group.SetWithTTL("testKey", "oldValue", -time.Second)
+
+	done := make(chan error)
+	go func() {
+		_, err := group.Get(context.Background(), "testKey")
+		done <- err
+	}()
+	<-started
+	// the expired value stays in the group while the getter runs
+	assert.Contains(t, group.data, "testKey")
+	group.Del("testKey")
+	close(release)
+
+	// the deleted value is not brought back by the failed getter
+	assert.ErrorIs(t, <-done, errDown)
+	assert.NotContains(t, group.data, "testKey")
+}
+
+func TestGroup_StaleOnTimeout(t *testing.T) {
+	errDown := errors.New("upstream down")
+	release := make(chan struct{})
+	defer close(release)
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if key == "failKey" {
+			return errDown
+		}
+		// ignores the context
+		<-release
+		dest.Set(key, "freshValue")
+		return nil
+	}), time.Minute, nil)
+	WithGetterTimeout(20 * time.Millisecond)(group)
+	WithStaleOnTimeout(time.Hour)(group)
+
+	group.SetWithTTL("testKey", "oldValue", -time.Second)
+	group.ttlCleanUp(time.Now())
+	start := time.Now()
+	val, src, err := group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "oldValue", val)
+	assert.Equal(t, StaleHit, src)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// no expired value to serve
+	_, err = group.Get(context.Background(), "newKey")
+	assert.ErrorIs(t, err, ErrTimeout)
+
+	// other getter errors are returned
+	group.SetWithTTL("failKey", "oldValue", -time.Second)
+	_, err = group.Get(context.Background(), "failKey")
+	assert.ErrorIs(t, err, errDown)
+
+	stats := group.Stats()
+	assert.Equal(t, int64(2), stats.GetterTimeouts)
+	assert.Equal(t, int64(1), stats.StaleHits)
+}
+
+func TestGroup_TTLBounds(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithTTLBounds(time.Second, time.Hour)(group)
+
+	group.SetWithTTL("shortKey", "value", time.Millisecond)
+	group.SetWithTTL("longKey", "value", 24*time.Hour)
+	group.SetWithTTL("zeroKey", "value", 0)
+	group.SetWithTTL("okKey", "value", 10*time.Minute)
+	assert.Equal(t, time.Second, group.data["shortKey"].ttl)
+	assert.Equal(t, time.Hour, group.data["longKey"].ttl)
+	assert.Equal(t, time.Minute, group.data["zeroKey"].ttl)
+	assert.Equal(t, 10*time.Minute, group.data["okKey"].ttl)
+
+	WithDropNonPositiveTTL()(group)
+	group.SetWithTTL("negativeKey", "value", -time.Second)
+	assert.NotContains(t, group.data, "negativeKey")
+}
+
+func TestGroup_Index(t *testing.T) {
+	type session struct{ User string }
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithIndex(func(val any) []string {
+		return []string{val.(session).User}
+	})(group)
+
+	group.Set("s1", session{User: "alice"})
+	group.Set("s2", session{User: "bob"})
+	group.Set("s3", session{User: "alice"})
+	assert.Equal(t, []any{session{User: "alice"}, session{User: "alice"}}, group.GetByIndex("alice"))
+
+	// replaced, deleted and expired values leave the index
+	group.Set("s3", session{User: "bob"})
+	group.Del("s2")
+	group.SetWithTTL("s4", session{User: "alice"}, -time.Second)
+	group.ttlCleanUp(time.Now())
+	assert.Equal(t, []any{session{User: "alice"}}, group.GetByIndex("alice"))
+	assert.Equal(t, []any{session{User: "bob"}}, group.GetByIndex("bob"))
+
+	group.flush()
+	assert.Nil(t, group.GetByIndex("alice"))
+	assert.Empty(t, group.index)
+}
+
+func TestGroup_SetWithDependencies(t *testing.T) {
+	deleteChan := make(chan deleteEvent, 10)
+	group := newGroup("testGroup", nil, time.Minute, deleteChan)
+
+	group.Set("user", "alice")
+	group.SetWithDependencies("profile", "alice's profile", "user")
+	group.SetWithDependencies("page", "alice's page", "profile", "user")
+	group.Set("other", "value")
+
+	group.Del("user")
+	assert.Len(t, group.data, 1)
+	assert.Contains(t, group.data, "other")
+	event := <-deleteChan
+	assert.ElementsMatch(t, []string{"user", "profile", "page"}, event.keys)
+	assert.Empty(t, group.dependents)
+
+	// an expired base deletes its dependents, cycles included
+	group.SetWithTTL("base", "value", -time.Second)
+	// setting b would delete a, so the cycle is stored in one batch
+	group.setEntries([]setEntry{
+		{Key: "a", Value: "value", TTL: time.Minute, DependsOn: []string{"base", "b"}},
+		{Key: "b", Value: "value", TTL: time.Minute, DependsOn: []string{"a"}},
+	})
+	group.ttlCleanUp(time.Now())
+	assert.Len(t, group.data, 1)
+	assert.Contains(t, group.data, "other")
+	event = <-deleteChan
+	assert.ElementsMatch(t, []string{"a", "b"}, event.keys)
+
+	// setting a key again replaces its dependencies
+	group.SetWithDependencies("derived", "value", "other")
+	group.Set("derived", "value")
+	group.Del("other")
+	assert.Contains(t, group.data, "derived")
+	<-deleteChan
+
+	// a local Set or Update of a base deletes its dependents
+	group.Set("user", "alice")
+	group.SetWithDependencies("profile", "alice's profile", "user")
+	group.Set("user", "bob")
+	assert.NotContains(t, group.data, "profile")
+	event = <-deleteChan
+	assert.Equal(t, "profile", event.key)
+	group.SetWithDependencies("profile", "bob's profile", "user")
+	assert.NoError(t, group.Update("user", func(old any, existed bool) (any, bool) {
+		return "carol", true
+	}))
+	assert.NotContains(t, group.data, "profile")
+	assert.Contains(t, group.data, "user")
+}
+
+func TestGroup_KeyRateLimit(t *testing.T) {
+	var calls int
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		return errors.New("upstream error")
+	}), time.Minute, nil)
+	WithKeyRateLimit(2, time.Hour)(group)
+
+	for range 5 {
+		group.Get(context.Background(), "hotKey")
+	}
+	assert.Equal(t, 2, calls)
+	_, err := group.Get(context.Background(), "hotKey")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int64(4), group.Stats().GetterThrottled)
+
+	// other keys have their own budget
+	group.Get(context.Background(), "otherKey")
+	assert.Equal(t, 3, calls)
+
+	// throttled keys serve their expired value
+	group.SetWithTTL("hotKey", "staleValue", -time.Second)
+	val, src, err := group.GetWithSource(context.Background(), "hotKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "staleValue", val)
+	assert.Equal(t, StaleHit, src)
+}
+
+func TestGroup_SnapshotTo(t *testing.T) {
+	src := newGroup("testGroup", nil, time.Minute, nil)
+	for i := range 2500 {
+		src.Set(fmt.Sprintf("key%04d", i), i)
+	}
+	src.SetWithTTL("expiredKey", "value", -time.Second)
+	src.SetWithDependencies("derived", "value", "key0000")
+
+	var buf bytes.Buffer
+	assert.NoError(t, src.SnapshotTo(&buf))
+	assert.Equal(t, 2501, strings.Count(buf.String(), "\n"))
+
+	dst := newGroup("testGroup", nil, time.Minute, nil)
+	dst.Set("key0001", "newer")
+	// an older snapshot does not overwrite newer values
+	assert.NoError(t, dst.RestoreFrom(strings.NewReader(buf.String())))
+	assert.Len(t, dst.data, 2501)
+	assert.Equal(t, "newer", dst.data["key0001"].val)
+	assert.Equal(t, float64(42), dst.data["key0042"].val)
+	assert.NotContains(t, dst.data, "expiredKey")
+	assert.InDelta(t, time.Minute, time.Until(dst.data["key0042"].ttlTime), float64(time.Second))
+
+	dst.Del("key0000")
+	assert.NotContains(t, dst.data, "derived")
+
+	assert.Error(t, dst.RestoreFrom(strings.NewReader("{")))
+}
+
+func TestGroup_SnapshotBinaryValues(t *testing.T) {
+	src := newGroup("testGroup", nil, time.Minute, nil)
+	WithBinaryValues(newBinaryPoint)(src)
+	src.Set("point", &binaryPoint{1, 2})
+	src.Set("plain", "value")
+
+	var buf bytes.Buffer
+	assert.NoError(t, src.SnapshotTo(&buf))
+	assert.Contains(t, buf.String(), `"binary":true`)
+
+	dst := newGroup("testGroup", nil, time.Minute, nil)
+	WithBinaryValues(newBinaryPoint)(dst)
+	assert.NoError(t, dst.RestoreFrom(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, &binaryPoint{1, 2}, dst.data["point"].val)
+	assert.Equal(t, "value", dst.data["plain"].val)
+
+	// binary values cannot be restored without WithBinaryValues
+	assert.Error(t, newGroup("testGroup", nil, time.Minute, nil).RestoreFrom(bytes.NewReader(buf.Bytes())))
+}
+
+func TestGroup_SlowGetterThreshold(t *testing.T) {
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if key == "slow" {
+			time.Sleep(20 * time.Millisecond)
+			return errors.New("backend error")
+		}
+		dest.Set(key, "value")
+		return nil
+	}), time.Minute, nil)
+	WithSlowGetterThreshold(10 * time.Millisecond)(group)
+	var reported []error
+	group.reportError = func(err error) {
+		reported = append(reported, err)
+	}
+
+	group.Get(context.Background(), "fast")
+	group.Get(context.Background(), "slow")
+	assert.Equal(t, int64(1), group.Stats().SlowGetterCalls)
+	if assert.Len(t, reported, 1) {
+		assert.ErrorIs(t, reported[0], ErrSlowGetter)
+		assert.Contains(t, reported[0].Error(), "slow")
+	}
+}
+
+func TestGroup_MaxFillKeys(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		for i := range 5 {
+			dest.Set(fmt.Sprintf("row%d", i), i)
+		}
+		dest.Set("row0", 0)
+		dest.Set(key, "value")
+		return nil
+	})
+	group := newGroup("testGroup", getter, time.Minute, nil)
+	WithMaxFillKeys(3)(group)
+	var reported []error
+	group.reportError = func(err error) {
+		reported = append(reported, err)
+	}
+
+	val, err := group.Get(context.Background(), "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 4, group.Stats().Entries)
+	_, ok := group.GetIfPresent("row3")
+	assert.False(t, ok)
+
+	stats := group.Stats()
+	assert.Equal(t, int64(4), stats.MaxFillKeys)
+	assert.Equal(t, int64(2), stats.FillKeysDropped)
+	if assert.Len(t, reported, 1) {
+		assert.ErrorIs(t, reported[0], ErrFillKeyLimit)
+	}
+}
+
+func TestGroup_PeerFetchStats(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.peerFetch = func(ctx context.Context, key string) (setEntry, error) {
+		if key == "slowKey" {
+			time.Sleep(30 * time.Millisecond)
+			return setEntry{}, ErrNotFound
+		}
+		return setEntry{Key: key, Value: "peerValue", TTL: time.Minute}, nil
+	}
+
+	val, src, err := group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "peerValue", val)
+	assert.Equal(t, PeerHit, src)
+	_, err = group.Get(context.Background(), "slowKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	// served locally, not fetched again
+	group.Get(context.Background(), "testKey")
+
+	stats := group.Stats()
+	assert.Equal(t, int64(2), stats.PeerFetches)
+	assert.Equal(t, int64(1), stats.PeerFetchHits)
+	var fetches int64
+	for _, n := range stats.PeerFetchLatency.Buckets {
+		fetches += n
+	}
+	assert.Equal(t, int64(2), fetches)
+	// the slow miss took between 25ms and 1s
+	assert.Zero(t, stats.PeerFetchLatency.Buckets[3])
+	assert.Equal(t, int64(1), stats.PeerFetchLatency.Buckets[4]+stats.PeerFetchLatency.Buckets[5]+stats.PeerFetchLatency.Buckets[6]+stats.PeerFetchLatency.Buckets[7])
+	assert.GreaterOrEqual(t, stats.PeerFetchLatency.Total, 30*time.Millisecond)
+}
+
+func TestGroup_Lifetimes(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	WithMaxEntries(2)(group)
+	group.Set("evicted", "value")
+	group.Set("expired", "value")
+	group.Set("new", "value")
+	group.ttlCleanUp(time.Now().Add(2 * time.Minute))
+
+	lifetimes := group.Stats().Lifetimes
+	assert.Equal(t, [6]int64{1, 0, 0, 0, 0, 0}, lifetimes.Evicted)
+	assert.Equal(t, [6]int64{0, 0, 0, 2, 0, 0}, lifetimes.Expired)
+
+	assert.Equal(t, 0, lifetimeBucket(time.Second, time.Minute))
+	assert.Equal(t, 2, lifetimeBucket(30*time.Second, time.Minute))
+	assert.Equal(t, 5, lifetimeBucket(3*time.Minute, time.Minute))
+}
+
+func TestGroup_ResetStats(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+	group.Set("testKey", "testValue")
+
+	const workers, gets = 4, 1000
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range gets {
+				group.Get(context.Background(), "testKey")
+			}
+		}()
+	}
+	var hits int64
+	for range 10 {
+		hits += group.ResetStats().Hits
+	}
+	wg.Wait()
+	stats := group.ResetStats()
+	hits += stats.Hits
+	// every hit is counted exactly once across the resets
+	assert.Equal(t, int64(workers*gets), hits)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Zero(t, group.Stats().Hits)
+}
+
+func TestTimeBuckets(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Hour, nil)
+	buckets := NewTimeBuckets(group, "requests", time.Minute, 3)
+	now := time.Now()
+	increment := func(old any, existed bool) (any, bool) {
+		n, _ := old.(int)
+		return n + 1, true
+	}
+
+	for i := range 4 {
+		at := now.Add(-time.Duration(i) * time.Minute)
+		for range i + 1 {
+			assert.NoError(t, buckets.Update(at, increment))
+		}
+	}
+	assert.Equal(t, buckets.Key(now), buckets.Key(now.Truncate(time.Minute)))
+	assert.NotEqual(t, buckets.Key(now), buckets.Key(now.Add(-time.Minute)))
+
+	// the bucket of 3 minutes ago is out of the window
+	recent := buckets.Recent(now)
+	var counts []any
+	for _, b := range recent {
+		counts = append(counts, b.Value)
+	}
+	assert.Equal(t, []any{1, 2, 3}, counts)
+	assert.Equal(t, now.Truncate(time.Minute), recent[0].Start)
+
+	// Set expires the bucket when it leaves the window
+	buckets.Set(now.Add(-2*time.Minute), 10)
+	assert.LessOrEqual(t, time.Until(group.data[buckets.Key(now.Add(-2*time.Minute))].ttlTime), time.Minute)
+	buckets.Set(now.Add(-5*time.Minute), 10)
+	assert.NotContains(t, group.data, buckets.Key(now.Add(-5*time.Minute)))
+}
+
+func TestGroup_Tombstones(t *testing.T) {
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		close(fetching)
+		<-release
+		dest.Set(key, "value read before the delete")
+		return nil
+	}), time.Minute, nil)
+	WithTombstones(time.Minute)(group)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		group.Get(context.Background(), "testKey")
+	}()
+	<-fetching
+	group.Del("testKey")
+	close(release)
+	<-done
+	assert.NotContains(t, group.data, "testKey")
+
+	// values set by a peer before the delete are ignored, later ones are stored
+	before := uint64(time.Now().Add(-time.Second).UnixNano())
+	group.setEntries([]setEntry{{Key: "testKey", Value: "old", TTL: time.Minute, Generation: before}})
+	assert.NotContains(t, group.data, "testKey")
+	group.setEntries([]setEntry{{Key: "testKey", Value: "new", TTL: time.Minute, Generation: uint64(time.Now().UnixNano())}})
+	assert.Equal(t, "new", group.data["testKey"].val)
+
+	// a local set clears the tombstone
+	group.Del("otherKey")
+	group.Set("otherKey", "value")
+	assert.Contains(t, group.data, "otherKey")
+	assert.NotContains(t, group.tombstones, "otherKey")
+
+	group.ttlCleanUp(time.Now().Add(2 * time.Minute))
+	assert.Empty(t, group.tombstones)
+}
+
+func TestGroup_CancelFillsOnDelete(t *testing.T) {
+	resurrected := func(opts ...GroupOption) (bool, error) {
+		fetching := make(chan struct{})
+		release := make(chan struct{})
+		var fillErr error
+		group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			close(fetching)
+			<-release
+			fillErr = ctx.Err()
+			dest.Set(key, "value read before the delete")
+			return nil
+		}), time.Minute, nil)
+		for _, opt := range opts {
+			opt(group)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			group.Get(context.Background(), "testKey")
+		}()
+		<-fetching
+		group.Del("testKey")
+		close(release)
+		<-done
+		_, ok := group.data["testKey"]
+		return ok, fillErr
+	}
+
+	ok, err := resurrected()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	ok, err = resurrected(WithCancelFillsOnDelete())
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGroup_SchemaVersion(t *testing.T) {
+	var calls int
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		calls++
+		dest.Set(key, "v2 value")
+		return nil
+	}), time.Minute, nil)
+	WithSchemaVersion(2)(group)
+
+	// a value pushed by a node still running version 1
+	group.setEntries([]setEntry{{Key: "testKey", Value: "v1 value", TTL: time.Minute, Generation: 1, SchemaVersion: 1}})
+	val, err := group.Get(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2 value", val)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 2, group.data["testKey"].schemaVersion)
+
+	// snapshots of another version are not restored
+	old := newGroup("testGroup", nil, time.Minute, nil)
+	old.Set("oldKey", "v0 value")
+	var buf bytes.Buffer
+	assert.NoError(t, old.SnapshotTo(&buf))
+	assert.NoError(t, group.RestoreFrom(&buf))
+	assert.NotContains(t, group.data, "oldKey")
+}
+
+func TestGroup_StaleWhileCircuitOpen(t *testing.T) {
+	group := newGroup("testGroup", GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		return errors.New("backend down")
+	}), time.Minute, nil)
+	WithCircuitBreaker(1, time.Minute, time.Hour)(group)
+	WithStaleIfError(time.Minute)(group)
+
+	group.Get(context.Background(), "unknownKey")
+	assert.Equal(t, BreakerOpen, group.Stats().Breaker)
+
+	group.SetWithTTL("testKey", "staleValue", -time.Second)
+	val, src, err := group.GetWithSource(context.Background(), "testKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "staleValue", val)
+	assert.Equal(t, StaleHit, src)
+	assert.Equal(t, int64(1), group.Stats().StaleHits)
+
+	// values that expired longer ago than the grace period are not served
+	group.SetWithTTL("oldKey", "oldValue", -2*time.Minute)
+	_, err = group.Get(context.Background(), "oldKey")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// re-serving a stale value does not extend the grace period
+	group.mtx.Lock()
+	d := group.data["testKey"]
+	d.expiredAt = time.Now().Add(-2 * time.Minute)
+	d.ttlTime = time.Now().Add(-time.Second)
+	group.data["testKey"] = d
+	group.mtx.Unlock()
+	_, err = group.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestGroup_Update(t *testing.T) {
+	group := newGroup("testGroup", nil, time.Minute, nil)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.Update("counter", func(old any, existed bool) (any, bool) {
+				n, _ := old.(int)
+				return n + 1, true
+			})
+		}()
+	}
+	wg.Wait()
+	val, err := group.Get(context.Background(), "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, val)
+
+	// fn sees whether the key exists and may decline to store
+	group.SetWithTTL("expiredKey", "value", -time.Second)
+	assert.NoError(t, group.Update("expiredKey", func(old any, existed bool) (any, bool) {
+		assert.False(t, existed)
+		assert.Nil(t, old)
+		return nil, false
+	}))
+	_, err = group.Get(context.Background(), "expiredKey")
+	assert.Error(t, err)
+
+	WithMaxKeyLength(4)(group)
+	assert.ErrorIs(t, group.Update("longKey", func(old any, existed bool) (any, bool) { return 1, true }), ErrKeyTooLong)
+}
@@ -0,0 +1,61 @@
+package groupcache
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ByteView holds an immutable view of bytes, like groupcache.ByteView.
+type ByteView struct {
+	// b 또는 s 중 하나만 사용
+	b []byte
+	s string
+}
+
+func (v ByteView) Len() int {
+	if v.b != nil {
+		return len(v.b)
+	}
+	return len(v.s)
+}
+
+// ByteSlice returns a copy of the data as a byte slice.
+func (v ByteView) ByteSlice() []byte {
+	if v.b != nil {
+		return bytes.Clone(v.b)
+	}
+	return []byte(v.s)
+}
+
+func (v ByteView) String() string {
+	if v.b != nil {
+		return string(v.b)
+	}
+	return v.s
+}
+
+// At returns the byte at index i.
+func (v ByteView) At(i int) byte {
+	if v.b != nil {
+		return v.b[i]
+	}
+	return v.s[i]
+}
+
+// MarshalJSON encodes the view as a JSON string, so it can be sent to peers with cache.JSONCodec.
+func (v ByteView) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// toByteView converts a cached value, which may have been decoded from a peer, to a ByteView.
+func toByteView(val any) (ByteView, bool) {
+	switch v := val.(type) {
+	case ByteView:
+		return v, true
+	case string:
+		return ByteView{s: v}, true
+	case []byte:
+		return ByteView{b: bytes.Clone(v)}, true
+	}
+	return ByteView{}, false
+}
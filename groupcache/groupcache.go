@@ -0,0 +1,108 @@
+// Package groupcache provides a groupcache-style API on top of go-cache,
+// to ease the migration of groupcache users.
+//
+//	var thumbnails = groupcache.NewGroup("thumbnail", 64<<20, groupcache.GetterFunc(
+//		func(ctx context.Context, key string, dest groupcache.Sink) error {
+//			return dest.SetBytes(generateThumbnail(key))
+//		}))
+//
+//	var data []byte
+//	err := thumbnails.Get(ctx, "big-file.png", groupcache.AllocatingByteSliceSink(&data))
+//
+// Groups are created on the cache set with SetCache, or on a single node cache by default.
+// Peers, TTL and propagation are configured on that cache.
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/winey-dev/go-cache"
+)
+
+// Getter loads data for a key, like groupcache.Getter.
+type Getter interface {
+	Get(ctx context.Context, key string, dest Sink) error
+}
+
+type GetterFunc func(ctx context.Context, key string, dest Sink) error
+
+func (f GetterFunc) Get(ctx context.Context, key string, dest Sink) error {
+	return f(ctx, key, dest)
+}
+
+var (
+	mtx    sync.RWMutex
+	c      cache.Cache
+	groups = make(map[string]*Group)
+)
+
+// SetCache sets the cache the groups are created on. It must be called before NewGroup.
+func SetCache(cache cache.Cache) {
+	mtx.Lock()
+	c = cache
+	mtx.Unlock()
+}
+
+// Group is a cache namespace, like groupcache.Group.
+type Group struct {
+	name  string
+	group cache.Group
+}
+
+// NewGroup creates a group backed by the go-cache group of the same name.
+// cacheBytes is accepted for compatibility and ignored; entries expire by TTL instead.
+func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if _, dup := groups[name]; dup {
+		panic("duplicate registration of group " + name)
+	}
+	if c == nil {
+		c = cache.NewCache(&cache.Config{})
+	}
+
+	g := &Group{
+		name: name,
+		group: c.NewGroup(name, cache.GetterFunc(func(ctx context.Context, key string, dest cache.Sink) error {
+			var v ByteView
+			if err := getter.Get(ctx, key, ByteViewSink(&v)); err != nil {
+				return err
+			}
+			dest.Set(key, v)
+			return nil
+		})),
+	}
+	if g.group == nil {
+		// Config.MaxGroups 에 도달했거나 예약된 이름인 경우
+		panic("go-cache refused group " + name)
+	}
+	groups[name] = g
+	return g
+}
+
+// GetGroup returns the named group previously created with NewGroup, or nil.
+func GetGroup(name string) *Group {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return groups[name]
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get loads key into dest, calling the getter on a miss.
+func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
+	val, err := g.group.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	v, ok := toByteView(val)
+	if !ok {
+		return fmt.Errorf("groupcache: unexpected value type %T for key %s", val, key)
+	}
+	return setSinkView(dest, v)
+}
@@ -0,0 +1,30 @@
+package groupcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Get(t *testing.T) {
+	var cnt int = 0
+	g := NewGroup("testGroup", 64<<20, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		cnt += 1
+		return dest.SetBytes([]byte("value for " + key))
+	}))
+	assert.Equal(t, g, GetGroup("testGroup"))
+
+	var s string
+	assert.NoError(t, g.Get(context.Background(), "testKey", StringSink(&s)))
+	assert.Equal(t, "value for testKey", s)
+
+	var b []byte
+	assert.NoError(t, g.Get(context.Background(), "testKey", AllocatingByteSliceSink(&b)))
+	assert.Equal(t, []byte("value for testKey"), b)
+
+	var v ByteView
+	assert.NoError(t, g.Get(context.Background(), "testKey", ByteViewSink(&v)))
+	assert.Equal(t, "value for testKey", v.String())
+	assert.Equal(t, 1, cnt)
+}
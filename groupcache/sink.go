@@ -0,0 +1,77 @@
+package groupcache
+
+// Sink receives the data loaded by a Getter, like groupcache.Sink.
+type Sink interface {
+	SetString(s string) error
+	SetBytes(v []byte) error
+}
+
+type stringSink struct {
+	sp *string
+}
+
+// StringSink returns a Sink that populates the provided string pointer.
+func StringSink(sp *string) Sink {
+	return &stringSink{sp: sp}
+}
+
+func (s *stringSink) SetString(v string) error {
+	*s.sp = v
+	return nil
+}
+
+func (s *stringSink) SetBytes(v []byte) error {
+	return s.SetString(string(v))
+}
+
+type byteViewSink struct {
+	dst *ByteView
+}
+
+// ByteViewSink returns a Sink that populates a ByteView.
+func ByteViewSink(dst *ByteView) Sink {
+	return &byteViewSink{dst: dst}
+}
+
+func (s *byteViewSink) SetString(v string) error {
+	*s.dst = ByteView{s: v}
+	return nil
+}
+
+func (s *byteViewSink) SetBytes(v []byte) error {
+	*s.dst = ByteView{b: cloneBytes(v)}
+	return nil
+}
+
+type allocBytesSink struct {
+	dst *[]byte
+}
+
+// AllocatingByteSliceSink returns a Sink that allocates a byte slice to hold the received value.
+func AllocatingByteSliceSink(dst *[]byte) Sink {
+	return &allocBytesSink{dst: dst}
+}
+
+func (s *allocBytesSink) SetString(v string) error {
+	*s.dst = []byte(v)
+	return nil
+}
+
+func (s *allocBytesSink) SetBytes(v []byte) error {
+	*s.dst = cloneBytes(v)
+	return nil
+}
+
+func cloneBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// setSinkView copies v into dest.
+func setSinkView(dest Sink, v ByteView) error {
+	if v.b != nil {
+		return dest.SetBytes(v.b)
+	}
+	return dest.SetString(v.s)
+}
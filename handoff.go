@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// handoffBatchSize bounds the entries sent in one request, which peers limit with MaxRequestBytes.
+const handoffBatchSize = 500
+
+// handoff pushes the unexpired entries of every group to the peers, giving up after timeout.
+func (c *cache) handoff(timeout time.Duration) {
+	c.mtx.RLock()
+	groups := make([]*group, 0, len(c.group))
+	for _, g := range c.group {
+		groups = append(groups, g)
+	}
+	hasPeers := len(c.peerAddresses) != 0
+	c.mtx.RUnlock()
+	if !hasPeers {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	var sent int
+	for _, g := range groups {
+		entries := g.liveEntries(time.Now())
+		for len(entries) != 0 && ctx.Err() == nil {
+			batch := entries[:min(handoffBatchSize, len(entries))]
+			entries = entries[len(batch):]
+			c.pushEntries(ctx, g, batch)
+			sent += len(batch)
+		}
+	}
+	c.logger.Info("cache handed off to peers", "entries", sent, "elapsed", time.Since(start), "timeout", ctx.Err() != nil)
+}
+
+// liveEntries returns the unexpired entries of the group with their remaining ttl.
+func (g *group) liveEntries(now time.Time) []setEntry {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	entries := make([]setEntry, 0, len(g.data))
+	for key, d := range g.data {
+		if d.missing || now.After(d.ttlTime) {
+			continue
+		}
+		entries = append(entries, setEntry{Key: key, Value: d.val, TTL: d.ttlTime.Sub(now), Generation: d.generation, Priority: d.priority, DependsOn: d.dependsOn, SchemaVersion: d.schemaVersion})
+	}
+	return entries
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+// WithIndex maintains a secondary index of the group for GetByIndex: fn returns
+// the index values of a value, e.g. the owner ID of a session. The index follows
+// every Set, Del, expiry and eviction. fn is called with the group locked, so it
+// must be fast and must not use the group.
+func WithIndex(fn func(val any) []string) GroupOption {
+	return func(g *group) {
+		g.indexFunc = fn
+		g.index = make(map[string]map[string]struct{})
+	}
+}
+
+func (g *group) GetByIndex(indexValue string) []any {
+	now := time.Now()
+	g.mtx.RLock()
+	keys := make([]string, 0, len(g.index[indexValue]))
+	for key := range g.index[indexValue] {
+		if !now.After(g.data[key].ttlTime) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	vals := make([]any, len(keys))
+	for i, key := range keys {
+		vals[i] = g.data[key].val
+	}
+	g.mtx.RUnlock()
+
+	if len(keys) == 0 {
+		return nil
+	}
+	// lock 밖에서 복사
+	if g.cloneOnRead != nil {
+		return g.cloneIndexed(keys, vals)
+	}
+	return vals
+}
+
+// cloneIndexed copies the values of GetByIndex, leaving out and reporting the
+// values that cannot be copied, see WithCloneOnRead.
+func (g *group) cloneIndexed(keys []string, vals []any) []any {
+	cloned := vals[:0]
+	for i, val := range vals {
+		val, err := g.cloneValue(keys[i], val)
+		if err != nil {
+			switch {
+			case g.reportError != nil:
+				g.reportError(err)
+			case g.logger != nil:
+				g.logger.Warn(err.Error())
+			}
+			continue
+		}
+		cloned = append(cloned, val)
+	}
+	return cloned
+}
+
+// putLocked stores d under key, replacing its index entries and dependencies.
+// d.size must already be measured with sizeOf. Must be called with g.mtx held.
+func (g *group) putLocked(key string, d data) {
+	if old, ok := g.data[key]; ok {
+		g.indexRemove(key, old)
+		g.dependencyRemove(key, old)
+		g.addBytes(-old.size)
+	}
+	g.addBytes(d.size)
+	g.indexAdd(key, d)
+	g.dependencyAdd(key, d)
+	g.data[key] = d
+	g.evictOrder.update(key, d)
+	g.peakEntries = max(g.peakEntries, len(g.data))
+}
+
+// deleteLocked removes key, its index entries and dependencies. Must be called with g.mtx held.
+func (g *group) deleteLocked(key string) (data, bool) {
+	d, ok := g.data[key]
+	if ok {
+		delete(g.data, key)
+		g.evictOrder.remove(key)
+		g.indexRemove(key, d)
+		g.dependencyRemove(key, d)
+		g.addBytes(-d.size)
+	}
+	return d, ok
+}
+
+func (g *group) indexAdd(key string, d data) {
+	if g.indexFunc == nil || d.missing {
+		return
+	}
+	for _, v := range g.indexFunc(d.val) {
+		keys, ok := g.index[v]
+		if !ok {
+			keys = make(map[string]struct{})
+			g.index[v] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (g *group) indexRemove(key string, d data) {
+	if g.indexFunc == nil || d.missing {
+		return
+	}
+	for _, v := range g.indexFunc(d.val) {
+		delete(g.index[v], key)
+		if len(g.index[v]) == 0 {
+			delete(g.index, v)
+		}
+	}
+}
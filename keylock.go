@@ -0,0 +1,36 @@
+package cache
+
+import "sync"
+
+// keyLock is the lock of a key held or awaited by refs WithKeyLock calls.
+type keyLock struct {
+	mtx  sync.Mutex
+	refs int
+}
+
+func (g *group) WithKeyLock(key string, fn func()) {
+	key = g.normalizeKey(key)
+	g.keyLocksMtx.Lock()
+	if g.keyLocks == nil {
+		g.keyLocks = make(map[string]*keyLock)
+	}
+	l := g.keyLocks[key]
+	if l == nil {
+		l = &keyLock{}
+		g.keyLocks[key] = l
+	}
+	l.refs++
+	g.keyLocksMtx.Unlock()
+
+	l.mtx.Lock()
+	defer func() {
+		l.mtx.Unlock()
+		g.keyLocksMtx.Lock()
+		// 기다리는 호출이 없으면 제거
+		if l.refs--; l.refs == 0 {
+			delete(g.keyLocks, key)
+		}
+		g.keyLocksMtx.Unlock()
+	}()
+	fn()
+}
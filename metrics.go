@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// metric is a per-group value rendered in the Prometheus text format.
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(s Stats) float64
+}
+
+var metrics = []metric{
+	{"gocache_hits_total", "counter", "Gets served from the local cache.", func(s Stats) float64 { return float64(s.Hits) }},
+	{"gocache_misses_total", "counter", "Gets not found in the local cache.", func(s Stats) float64 { return float64(s.Misses) }},
+	{"gocache_getter_calls_total", "counter", "Calls to the group getter.", func(s Stats) float64 { return float64(s.GetterCalls) }},
+	{"gocache_getter_errors_total", "counter", "Getter calls that returned an error.", func(s Stats) float64 { return float64(s.GetterErrors) }},
+	{"gocache_getter_throttled_total", "counter", "Getter calls skipped by WithKeyRateLimit.", func(s Stats) float64 { return float64(s.GetterThrottled) }},
+	{"gocache_maintenance_misses_total", "counter", "Misses not filled because of the maintenance mode.", func(s Stats) float64 { return float64(s.MaintenanceMisses) }},
+	{"gocache_stale_hits_total", "counter", "Expired values served in place of a failed getter call.", func(s Stats) float64 { return float64(s.StaleHits) }},
+	{"gocache_getter_timeouts_total", "counter", "Getter calls longer than WithGetterTimeout.", func(s Stats) float64 { return float64(s.GetterTimeouts) }},
+	{"gocache_slow_getter_calls_total", "counter", "Getter calls slower than WithSlowGetterThreshold.", func(s Stats) float64 { return float64(s.SlowGetterCalls) }},
+	{"gocache_peer_fetches_total", "counter", "Misses looked up at the peers with PeerFetch.", func(s Stats) float64 { return float64(s.PeerFetches) }},
+	{"gocache_peer_fetch_hits_total", "counter", "Peer fetches that found the value.", func(s Stats) float64 { return float64(s.PeerFetchHits) }},
+	{"gocache_peer_fetch_seconds_total", "counter", "Time spent in peer fetches.", func(s Stats) float64 { return s.PeerFetchLatency.Total.Seconds() }},
+	{"gocache_evictions_total", "counter", "Entries evicted by WithMaxEntries.", func(s Stats) float64 { return float64(s.Evictions) }},
+	{"gocache_entries", "gauge", "Entries currently stored, including expired ones not yet cleaned up.", func(s Stats) float64 { return float64(s.Entries) }},
+	{"gocache_bytes", "gauge", "Bytes of the entries measured by WithSizer or Config.MaxTotalBytes.", func(s Stats) float64 { return float64(s.Bytes) }},
+	{"gocache_in_flight", "gauge", "Keys with an active getter call.", func(s Stats) float64 { return float64(s.InFlight) }},
+	{"gocache_last_cleanup_removed", "gauge", "Expired entries removed by the last cleanup.", func(s Stats) float64 { return float64(s.LastCleanupRemoved) }},
+	{"gocache_max_fill_keys", "gauge", "Most keys set by one getter call.", func(s Stats) float64 { return float64(s.MaxFillKeys) }},
+	{"gocache_fill_keys_dropped_total", "counter", "Keys dropped by WithMaxFillKeys.", func(s Stats) float64 { return float64(s.FillKeysDropped) }},
+	{"gocache_max_write_lock_seconds", "gauge", "Longest hold of the write lock by a set.", func(s Stats) float64 { return s.MaxWriteLock.Seconds() }},
+	{"gocache_last_cleanup_duration_seconds", "gauge", "Duration of the last cleanup.", func(s Stats) float64 { return s.LastCleanupDuration.Seconds() }},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics renders the stats of every group in the Prometheus text exposition format.
+func (c *cache) writeMetrics(w io.Writer) {
+	c.mtx.RLock()
+	names := make([]string, 0, len(c.group))
+	stats := make(map[string]Stats, len(c.group))
+	for name, g := range c.group {
+		names = append(names, name)
+		stats[name] = g.Stats()
+	}
+	c.mtx.RUnlock()
+	slices.Sort(names)
+
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{group=\"%s\"} %v\n", m.name, labelEscaper.Replace(name), m.value(stats[name]))
+		}
+	}
+}
+
+func (c *cache) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.writeMetrics(w)
+}
+
+// clusterStats is the node level part of GET /_cache/stats.
+type clusterStats struct {
+	Peers        int `json:"peers"`
+	HealthyPeers int `json:"healthy_peers"`
+	// 모든 group 의 Stats.Bytes 합계
+	TotalBytes    int64 `json:"total_bytes"`
+	MaxTotalBytes int64 `json:"max_total_bytes,omitempty"`
+	// 주소와 오류 메시지를 포함하므로 admin 에게만 반환
+	PeerHealth []PeerHealth `json:"peer_health,omitempty"`
+}
+
+// statsHandler serves the Stats of every group keyed by group name, and the
+// peers of this node. The in-flight keys and the health of each peer are only
+// included for admins, see Config.AdminToken.
+func (c *cache) statsHandler(w http.ResponseWriter, r *http.Request) {
+	var resp struct {
+		Groups  map[string]Stats `json:"groups"`
+		Cluster clusterStats     `json:"cluster"`
+	}
+
+	admin := c.isAdmin(r)
+	c.mtx.RLock()
+	resp.Groups = make(map[string]Stats, len(c.group))
+	for name, g := range c.group {
+		stats := g.Stats()
+		if !admin {
+			stats.InFlightKeys = nil
+		}
+		resp.Groups[name] = stats
+		resp.Cluster.TotalBytes += stats.Bytes
+	}
+	c.mtx.RUnlock()
+	if c.budget != nil {
+		resp.Cluster.MaxTotalBytes = c.budget.max
+	}
+
+	health := c.PeerHealth()
+	resp.Cluster.Peers = len(health)
+	for _, h := range health {
+		if h.Healthy {
+			resp.Cluster.HealthyPeers++
+		}
+	}
+	if admin {
+		resp.Cluster.PeerHealth = health
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
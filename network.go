@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"encoding/base32"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixHostSuffix marks the host of a peer url that encodes a unix socket path.
+const unixHostSuffix = ".unix"
+
+var unixHostEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// unixHost encodes the socket path of a peer as the host of its urls, which
+// cannot contain a path.
+func unixHost(path string) string {
+	return strings.ToLower(unixHostEncoding.EncodeToString([]byte(path))) + unixHostSuffix
+}
+
+// peerOfHost returns the peer address the host of a peer url was built from.
+func peerOfHost(host string) string {
+	enc, ok := strings.CutSuffix(host, unixHostSuffix)
+	if !ok {
+		return host
+	}
+	path, err := unixHostEncoding.DecodeString(strings.ToUpper(enc))
+	if err != nil {
+		return host
+	}
+	return string(path)
+}
+
+// dialUnix connects to the unix socket encoded in the host of addr.
+func dialUnix(ctx context.Context, _, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", peerOfHost(host))
+}
+
+// listen opens the listener of the http server. A unix socket left behind by a
+// process that did not shut down cleanly is removed first.
+func (c *cache) listen() (net.Listener, error) {
+	if c.network == "unix" {
+		if fi, err := os.Stat(c.httpServ.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(c.httpServ.Addr)
+		}
+	}
+	return net.Listen(c.network, c.httpServ.Addr)
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrInvalidConfig is returned by NewCacheWithOptions for contradicting options.
+var ErrInvalidConfig = errors.New("invalid cache config")
+
+// Option configures a cache created with NewCacheWithOptions.
+type Option func(*Config)
+
+// NewCacheWithOptions is NewCache configured with options instead of a Config,
+// which it validates first, e.g. that static peers and headless services are
+// not both set. Options without a dedicated function are set with WithConfig.
+func NewCacheWithOptions(opts ...Option) (Cache, error) {
+	var config Config
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return NewCache(&config), nil
+}
+
+// validate reports contradicting fields, which NewCache would otherwise silently ignore.
+func (config *Config) validate() error {
+	headless := config.HeadlessServiceName != "" || len(config.HeadlessServiceNames) != 0
+	switch {
+	case config.SingleNode && (headless || len(config.PeerAddresses) != 0):
+		return fmt.Errorf("%w: a single node has no peers", ErrInvalidConfig)
+	case headless && len(config.PeerAddresses) != 0:
+		return fmt.Errorf("%w: static peers and headless services are mutually exclusive", ErrInvalidConfig)
+	case headless && config.HeadlessServicePort <= 0:
+		return fmt.Errorf("%w: headless services require a port", ErrInvalidConfig)
+	case len(config.PeerAddresses) != 0 && config.Addr == "":
+		return fmt.Errorf("%w: static peers require the address of this node", ErrInvalidConfig)
+	case config.TLSConfig != nil && config.PeerTransport != nil:
+		return fmt.Errorf("%w: TLS has no effect with a PeerTransport", ErrInvalidConfig)
+	case config.Network != "" && config.Network != "tcp" && config.Network != "unix":
+		return fmt.Errorf("%w: unsupported network %q", ErrInvalidConfig, config.Network)
+	case config.Network == "unix" && headless:
+		return fmt.Errorf("%w: headless services resolve tcp peers", ErrInvalidConfig)
+	case config.PeerSelector != nil && config.DeleteReplicas > 0:
+		return fmt.Errorf("%w: DeleteReplicas has no effect with a PeerSelector", ErrInvalidConfig)
+	case config.CacheCleanupIntervalSec > 0 && config.LazyCleanupOnly:
+		return fmt.Errorf("%w: a cleanup interval has no effect with lazy cleanup only", ErrInvalidConfig)
+	case len(config.EncryptionKey) == 0 && len(config.DecryptionKeys) != 0:
+		return fmt.Errorf("%w: decryption keys require an encryption key", ErrInvalidConfig)
+	case len(config.EncryptionKey) != 0 && !validEncryptionKeys(config.EncryptionKey, config.DecryptionKeys):
+		return fmt.Errorf("%w: encryption keys must be 16, 24 or 32 bytes", ErrInvalidConfig)
+	case config.MaxTotalBytes < 0:
+		return fmt.Errorf("%w: negative MaxTotalBytes", ErrInvalidConfig)
+	case config.CompactRatio < 0 || config.CompactRatio >= 1:
+		return fmt.Errorf("%w: the compact ratio must be between 0 and 1", ErrInvalidConfig)
+	case config.CompactRatio > 0 && config.CompactIntervalSec <= 0:
+		return fmt.Errorf("%w: a compact ratio has no effect without a compact interval", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// WithStaticPeers runs the node on addr with a fixed list of peers, which may include addr.
+func WithStaticPeers(addr string, peers ...string) Option {
+	return func(config *Config) {
+		config.Addr = addr
+		config.PeerAddresses = append(config.PeerAddresses, peers...)
+	}
+}
+
+// WithHeadlessService discovers the peers from the addresses of Kubernetes headless services.
+func WithHeadlessService(port int, names ...string) Option {
+	return func(config *Config) {
+		config.HeadlessServicePort = port
+		config.HeadlessServiceNames = append(config.HeadlessServiceNames, names...)
+	}
+}
+
+// WithTLS serves and calls the peers over https, see Config.TLSConfig.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(config *Config) {
+		config.TLSConfig = tlsConfig
+	}
+}
+
+// WithSingleNode runs the cache without peers, see Config.SingleNode.
+func WithSingleNode() Option {
+	return func(config *Config) {
+		config.SingleNode = true
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(config *Config) {
+		config.Logger = logger
+	}
+}
+
+func WithPathPrefix(prefix string) Option {
+	return func(config *Config) {
+		config.PathPrefix = prefix
+	}
+}
+
+func WithAdminToken(token string) Option {
+	return func(config *Config) {
+		config.AdminToken = token
+	}
+}
+
+// WithCleanupInterval fixes the interval of the background cleanup, rounded down
+// to seconds, instead of adapting it.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(config *Config) {
+		config.CacheCleanupIntervalSec = int(d / time.Second)
+	}
+}
+
+// WithConfig sets any Config field, e.g.
+//
+//	cache.WithConfig(func(c *cache.Config) { c.PropagateSets = true })
+func WithConfig(fn func(*Config)) Option {
+	return Option(fn)
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCacheWithOptions(t *testing.T) {
+	c, err := NewCacheWithOptions(
+		WithPathPrefix("/cache"),
+		WithConfig(func(config *Config) { config.MaxGroups = 1 }),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "/cache", c.(*cache).pathPrefix)
+	assert.Equal(t, 1, c.(*cache).maxGroups)
+
+	for _, opts := range [][]Option{
+		{WithStaticPeers("localhost:8080", "localhost:8081"), WithHeadlessService(4567, "service-headless")},
+		{WithHeadlessService(0, "service-headless")},
+		{WithStaticPeers("", "localhost:8081")},
+		{WithCleanupInterval(time.Minute), WithConfig(func(config *Config) { config.LazyCleanupOnly = true })},
+		{WithSingleNode(), WithStaticPeers("localhost:8080", "localhost:8081")},
+		{WithConfig(func(config *Config) { config.EncryptionKey = []byte("short") })},
+		{WithConfig(func(config *Config) { config.DecryptionKeys = [][]byte{make([]byte, 32)} })},
+	} {
+		_, err := NewCacheWithOptions(opts...)
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	}
+}
+
+func TestCache_SingleNode(t *testing.T) {
+	c, err := NewCacheWithOptions(WithSingleNode(), WithConfig(func(config *Config) { config.Addr = "localhost:0" }))
+	assert.NoError(t, err)
+	assert.Nil(t, c.(*cache).httpServ)
+	assert.Nil(t, c.(*cache).deleteChan)
+
+	g := c.NewGroup("testGroup", nil)
+	g.Set("testKey", "testValue")
+	g.Set("otherKey", "otherValue")
+	g.Del("testKey")
+	_, err = g.Get(context.Background(), "testKey")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.True(t, g.DelWithResult("otherKey").Complete())
+	_, ok := g.GetIfPresent("otherKey")
+	assert.False(t, ok)
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+}
+
+func TestCache_PeerURLTLS(t *testing.T) {
+	c := newTestHTTPCache("")
+	c.tlsConfig = &tls.Config{}
+	assert.Equal(t, "https://peer:4567/testGroup/testKey", c.peerURL("peer:4567", "testGroup", "testKey"))
+}
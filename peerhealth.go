@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// PeerHealth is what this node observed of the requests it sent to a peer.
+type PeerHealth struct {
+	Addr string `json:"addr"`
+	// false after the last request to the peer failed (network error or 5xx)
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastFailure         time.Time `json:"last_failure"`
+	LastError           string    `json:"last_error,omitempty"`
+	// clock of the peer minus the clock of this node, estimated at the middle of
+	// the last request, so within half its round trip. measured with
+	// Config.MaxClockSkewMs only
+	ClockSkew time.Duration `json:"clock_skew"`
+	// responses whose skew exceeded Config.MaxClockSkewMs
+	ClockSkewExceeded int64 `json:"clock_skew_exceeded"`
+}
+
+// sendTime sets the clock of this node on every response, see PeerHealth.ClockSkew.
+func sendTime(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerTime, strconv.FormatInt(time.Now().UnixNano(), 10))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordClockSkew estimates the clock skew of peer from its response to a
+// request sent at start and received at end, and warns when it starts
+// exceeding Config.MaxClockSkewMs.
+func (c *cache) recordClockSkew(peer string, resp *http.Response, start, end time.Time) {
+	nanos, err := strconv.ParseInt(resp.Header.Get(headerTime), 10, 64)
+	if err != nil {
+		// 이전 version 의 peer
+		return
+	}
+	skew := time.Unix(0, nanos).Sub(start.Add(end.Sub(start) / 2))
+	exceeded := skew > c.maxClockSkew || skew < -c.maxClockSkew
+
+	c.healthMtx.Lock()
+	if c.peerHealth == nil {
+		c.peerHealth = make(map[string]PeerHealth)
+	}
+	h := c.peerHealth[peer]
+	wasExceeded := h.ClockSkew > c.maxClockSkew || h.ClockSkew < -c.maxClockSkew
+	h.ClockSkew = skew
+	if exceeded {
+		h.ClockSkewExceeded++
+	}
+	c.peerHealth[peer] = h
+	c.healthMtx.Unlock()
+
+	if exceeded && !wasExceeded {
+		c.logger.Warn("peer clock skew exceeds MaxClockSkewMs, check NTP", "peer", peer, "skew", skew)
+	}
+}
+
+// recordPeerResult updates the health of peer after a request to it.
+func (c *cache) recordPeerResult(peer string, resp *http.Response, err error) {
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		err = fmt.Errorf("%s", resp.Status)
+	}
+	now := time.Now()
+
+	c.healthMtx.Lock()
+	defer c.healthMtx.Unlock()
+	if c.peerHealth == nil {
+		c.peerHealth = make(map[string]PeerHealth)
+	}
+	h := c.peerHealth[peer]
+	if err != nil {
+		h.ConsecutiveFailures++
+		h.LastFailure = now
+		h.LastError = err.Error()
+	} else {
+		h.ConsecutiveFailures = 0
+		h.LastSuccess = now
+	}
+	c.peerHealth[peer] = h
+}
+
+func (c *cache) PeerHealth() []PeerHealth {
+	c.mtx.RLock()
+	peers := slices.Clone(c.peerAddresses)
+	c.mtx.RUnlock()
+
+	c.healthMtx.Lock()
+	defer c.healthMtx.Unlock()
+	health := make([]PeerHealth, len(peers))
+	for i, peer := range peers {
+		h := c.peerHealth[peer]
+		h.Addr = peer
+		h.Healthy = h.ConsecutiveFailures == 0
+		health[i] = h
+	}
+	return health
+}
+
+// warmUp opens a connection to peer, which the peer client keeps idle for the
+// next request to it.
+func (c *cache) warmUp(peer string) {
+	ctx, cancel := context.WithTimeout(c.ctx, defaultPeerRequestTimeout)
+	defer cancel()
+	req, err := c.newPeerRequest(ctx, http.MethodGet, c.peerURL(peer, "_cache", "healthz"), nil)
+	if err != nil {
+		return
+	}
+	c.doPeerRequest(req)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned instead of calling the getter for a key that reached
+// its WithKeyRateLimit.
+var ErrRateLimited = errors.New("getter rate limited")
+
+// WithKeyRateLimit allows at most limit getter calls per key in each window, so
+// that a key whose fills keep failing or are not cached cannot hammer the backend.
+// Throttled misses return the expired value of the key as a StaleHit when the
+// group still has it, and an error wrapping ErrRateLimited otherwise.
+func WithKeyRateLimit(limit int, window time.Duration) GroupOption {
+	return func(g *group) {
+		g.keyLimiter = &keyLimiter{
+			limit:  limit,
+			window: window,
+			calls:  make(map[string]int),
+		}
+	}
+}
+
+// keyLimiter counts getter calls per key in fixed windows shared by every key,
+// so the counts of idle keys are dropped when a window ends.
+type keyLimiter struct {
+	mtx    sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	calls  map[string]int
+}
+
+func (l *keyLimiter) allow(key string, now time.Time) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if now.Sub(l.start) >= l.window {
+		clear(l.calls)
+		l.start = now
+	}
+	if l.calls[key] >= l.limit {
+		return false
+	}
+	l.calls[key]++
+	return true
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readiness is the body of GET /_cache/readyz.
+type readiness struct {
+	Ready    bool `json:"ready"`
+	Draining bool `json:"draining"`
+	Server   struct {
+		Addr      string `json:"addr"`
+		Listening bool   `json:"listening"`
+	} `json:"server"`
+	Discovery struct {
+		// false with static peers
+		Enabled bool `json:"enabled"`
+		Paused  bool `json:"paused,omitempty"`
+		// nil until the headless services were resolved without error
+		LastResolved *time.Time `json:"last_resolved,omitempty"`
+		LastError    string     `json:"last_error,omitempty"`
+		Peers        int        `json:"peers"`
+	} `json:"discovery"`
+}
+
+// readiness reports whether the node serves its peers: the http server listens,
+// the node is not being closed, and the headless services, if any, have been
+// resolved at least once.
+func (c *cache) readiness() readiness {
+	var r readiness
+	r.Draining = c.draining.Load()
+	if c.httpServ != nil {
+		r.Server.Addr = c.httpServ.Addr
+	}
+	r.Server.Listening = c.listening.Load()
+
+	c.mtx.RLock()
+	r.Discovery.Enabled = len(c.headlessServiceNames) != 0
+	r.Discovery.Peers = len(c.peerAddresses)
+	if !c.resolvedAt.IsZero() {
+		resolvedAt := c.resolvedAt
+		r.Discovery.LastResolved = &resolvedAt
+	}
+	if c.resolveErr != nil {
+		r.Discovery.LastError = c.resolveErr.Error()
+	}
+	c.mtx.RUnlock()
+	r.Discovery.Paused = r.Discovery.Enabled && c.discoveryPaused.Load()
+
+	r.Ready = !r.Draining && r.Server.Listening &&
+		(!r.Discovery.Enabled || r.Discovery.LastResolved != nil)
+	return r
+}
+
+// readyzHandler serves the readiness details, with 503 when the node is not ready.
+func (c *cache) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp := c.readiness()
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,92 @@
+// Package redisstore provides a cache.Store backed by Redis.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/winey-dev/go-cache"
+)
+
+// ErrNil must be returned by Client.Get when the key does not exist.
+var ErrNil = errors.New("redis: nil")
+
+// Client is the subset of redis commands used by Store.
+// With github.com/redis/go-redis/v9 it can be implemented as:
+//
+//	func (c client) Get(ctx context.Context, key string) ([]byte, error) {
+//		b, err := c.rdb.Get(ctx, key).Bytes()
+//		if err == redis.Nil {
+//			return nil, redisstore.ErrNil
+//		}
+//		return b, err
+//	}
+//
+//	func (c client) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+//		return c.rdb.Set(ctx, key, val, ttl).Err()
+//	}
+//
+//	func (c client) Del(ctx context.Context, key string) error {
+//		return c.rdb.Del(ctx, key).Err()
+//	}
+type Client interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// Store stores values in redis under "<prefix><group>/<key>".
+// Values are encoded with encoding/json unless Marshal/Unmarshal are replaced.
+type Store struct {
+	client Client
+	prefix string
+
+	Marshal   func(v any) ([]byte, error)
+	Unmarshal func(data []byte) (any, error)
+}
+
+var _ cache.Store = (*Store)(nil)
+
+func New(client Client, prefix string) *Store {
+	return &Store{
+		client:  client,
+		prefix:  prefix,
+		Marshal: json.Marshal,
+		Unmarshal: func(data []byte) (any, error) {
+			var val any
+			if err := json.Unmarshal(data, &val); err != nil {
+				return nil, err
+			}
+			return val, nil
+		},
+	}
+}
+
+func (s *Store) key(group, key string) string {
+	return s.prefix + group + "/" + key
+}
+
+func (s *Store) Get(ctx context.Context, group, key string) (any, error) {
+	b, err := s.client.Get(ctx, s.key(group, key))
+	if errors.Is(err, ErrNil) {
+		return nil, cache.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Unmarshal(b)
+}
+
+func (s *Store) Set(ctx context.Context, group, key string, val any, ttl time.Duration) error {
+	b, err := s.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key(group, key), b, ttl)
+}
+
+func (s *Store) Del(ctx context.Context, group, key string) error {
+	return s.client.Del(ctx, s.key(group, key))
+}
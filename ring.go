@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"cmp"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// defaultVirtualNodes is the number of points each node has on the hash ring.
+const defaultVirtualNodes = 128
+
+// hashRing maps keys to nodes with consistent hashing, so that a membership
+// change only moves the keys of the nodes that joined or left.
+type hashRing struct {
+	// ring 을 만든 peer 목록, 변경 여부 확인에 사용
+	peers  []string
+	self   string
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// newHashRing builds the ring of peers and self. weight, when not nil, scales the
+// number of points of each node, so that a node of weight 2 owns about twice as
+// many keys as a node of weight 1.
+func newHashRing(peers []string, self string, weight func(node string) int) *hashRing {
+	nodes := slices.Clone(peers)
+	if self != "" && !slices.Contains(nodes, self) {
+		nodes = append(nodes, self)
+	}
+
+	r := &hashRing{peers: slices.Clone(peers), self: self}
+	for _, node := range nodes {
+		points := defaultVirtualNodes
+		if weight != nil {
+			points *= max(weight(node), 1)
+		}
+		for i := range points {
+			r.points = append(r.points, ringPoint{hash: hashString(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b ringPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+	})
+	return r
+}
+
+// order returns the distinct nodes met walking the ring clockwise from the
+// hash of group and key; the first one is the owner.
+func (r *hashRing) order(group, key string) []string {
+	if len(r.points) == 0 {
+		return nil
+	}
+	h := hashString(group + "\x00" + key)
+	start, _ := slices.BinarySearchFunc(r.points, h, func(p ringPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+
+	var nodes []string
+	for i := range r.points {
+		node := r.points[(start+i)%len(r.points)].node
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func (r *hashRing) owner(group, key string) string {
+	if nodes := r.order(group, key); len(nodes) != 0 {
+		return nodes[0]
+	}
+	return r.self
+}
+
+// hashString is fnv-64a followed by a mixing step, which spreads the hashes of
+// similar strings such as "node#1" and "node#2" over the ring.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// hashRing returns the ring of the current peers, rebuilding it when they changed.
+func (c *cache) hashRing() *hashRing {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.ring == nil || !slices.Equal(c.ring.peers, c.peerAddresses) || c.ring.self != c.selfAddr() {
+		if c.ring != nil {
+			c.prevRing, c.ringChangedAt = c.ring, time.Now()
+		}
+		c.ring = newHashRing(c.peerAddresses, c.selfAddr(), c.peerWeight)
+	}
+	return c.ring
+}
+
+// previousOwner returns the owner of key before the last membership change
+// while it settles (see Config.MembershipSettleSec), when it is another peer
+// still in ring. It returns "" otherwise.
+func (c *cache) previousOwner(ring *hashRing, group, key string) string {
+	c.mtx.RLock()
+	prev, changedAt := c.prevRing, c.ringChangedAt
+	c.mtx.RUnlock()
+	if prev == nil || time.Since(changedAt) >= c.membershipSettle {
+		return ""
+	}
+	owner := prev.owner(group, key)
+	if owner == ring.self || owner == ring.owner(group, key) || !slices.Contains(ring.peers, owner) {
+		return ""
+	}
+	return owner
+}
+
+// selfAddr returns the address of this node as the peers know it, which every
+// node must use for it on the hash ring: Config.SelfAddr when set, with static
+// peers Addr, and with headless services the address of its pod ip among the
+// resolved ones, with the port and the PeerAddressMapper of the other peers. It
+// falls back to Addr until the services are resolved. Must be called with c.mtx held.
+func (c *cache) selfAddr() string {
+	switch {
+	case c.configSelf != "":
+		return c.configSelf
+	case len(c.headlessServiceNames) != 0 && c.resolvedSelf != "":
+		return c.resolvedSelf
+	}
+	return c.addr
+}
+
+func (c *cache) Owner(group, key string) (string, bool) {
+	ring := c.hashRing()
+	owner := ring.owner(group, key)
+	return owner, owner == ring.self
+}
+
+// RingState describes the hash ring of a node, see Cache.Ring.
+type RingState struct {
+	Self string `json:"self"`
+	// sorted by address
+	Nodes []RingNode `json:"nodes"`
+}
+
+// RingNode is a node of RingState.
+type RingNode struct {
+	Addr         string `json:"addr"`
+	Weight       int    `json:"weight"`
+	VirtualNodes int    `json:"virtual_nodes"`
+	// fraction of the hash space the node owns, about the fraction of the keys
+	Share float64 `json:"share"`
+	// only with ranges requested
+	Ranges []RingRange `json:"ranges,omitempty"`
+}
+
+// RingRange is a range of key hashes, from Start excluded to End included. The
+// range wrapping around the ring has Start greater than End.
+type RingRange struct {
+	Start uint64 `json:"start,string"`
+	End   uint64 `json:"end,string"`
+}
+
+// state returns the nodes of the ring with their share of the hash space, and
+// with the ranges they own when ranges is set. Adjacent points of a node are
+// merged into one range.
+func (r *hashRing) state(ranges bool, weight func(node string) int) RingState {
+	state := RingState{Self: r.self}
+	nodes := make(map[string]*RingNode)
+	for i, p := range r.points {
+		n := nodes[p.node]
+		if n == nil {
+			n = &RingNode{Addr: p.node, Weight: 1}
+			if weight != nil {
+				n.Weight = max(weight(p.node), 1)
+			}
+			nodes[p.node] = n
+		}
+		n.VirtualNodes++
+
+		// 이전 point 의 hash 부터 이 point 의 hash 까지를 소유, uint64 overflow 로 한 바퀴를 돈다
+		prev := r.points[(i+len(r.points)-1)%len(r.points)]
+		if len(r.points) == 1 {
+			n.Share = 1
+		} else {
+			n.Share += float64(p.hash-prev.hash) / math.Pow(2, 64)
+		}
+		if !ranges {
+			continue
+		}
+		if last := len(n.Ranges) - 1; last >= 0 && n.Ranges[last].End == prev.hash {
+			n.Ranges[last].End = p.hash
+		} else {
+			n.Ranges = append(n.Ranges, RingRange{Start: prev.hash, End: p.hash})
+		}
+	}
+	for _, n := range nodes {
+		state.Nodes = append(state.Nodes, *n)
+	}
+	slices.SortFunc(state.Nodes, func(a, b RingNode) int {
+		return cmp.Compare(a.Addr, b.Addr)
+	})
+	return state
+}
+
+func (c *cache) Ring() RingState {
+	return c.hashRing().state(true, c.peerWeight)
+}
+
+// ringHandler serves the hash ring, with the ranges of each node when the ranges
+// query parameter is true.
+func (c *cache) ringHandler(w http.ResponseWriter, r *http.Request) {
+	ranges, _ := strconv.ParseBool(r.URL.Query().Get("ranges"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.hashRing().state(ranges, c.peerWeight))
+}
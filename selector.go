@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// PeerSelector chooses the peers a delete of key is sent to, and the order in
+// which the peers are asked for key on a peer fetch, see Config.PeerSelector.
+// peers is the current peer list without this node; Select must not modify it.
+type PeerSelector interface {
+	Select(group, key string, peers []string) []string
+}
+
+// PeerSelectorFunc is a function implementing PeerSelector.
+type PeerSelectorFunc func(group, key string, peers []string) []string
+
+func (f PeerSelectorFunc) Select(group, key string, peers []string) []string {
+	return f(group, key, peers)
+}
+
+// BroadcastSelector selects every peer, in the order of the peer list.
+func BroadcastSelector() PeerSelector {
+	return PeerSelectorFunc(func(group, key string, peers []string) []string {
+		return peers
+	})
+}
+
+// HashRingSelector selects the first n peers met on the consistent hash ring
+// from key, i.e. its owners when this node is not one of them; every peer in
+// ring order when n is 0. The ring ignores Config.PeerWeight.
+func HashRingSelector(n int) PeerSelector {
+	return &hashRingSelector{n: n}
+}
+
+type hashRingSelector struct {
+	n    int
+	mtx  sync.Mutex
+	ring *hashRing
+}
+
+func (s *hashRingSelector) Select(group, key string, peers []string) []string {
+	s.mtx.Lock()
+	if s.ring == nil || !slices.Equal(s.ring.peers, peers) {
+		s.ring = newHashRing(peers, "", nil)
+	}
+	ring := s.ring
+	s.mtx.Unlock()
+
+	nodes := ring.order(group, key)
+	if s.n > 0 {
+		nodes = nodes[:min(s.n, len(nodes))]
+	}
+	return nodes
+}
+
+// ZoneSelector selects every peer, those for which zoneOf returns zone first,
+// e.g. to fetch from the same availability zone before crossing zones.
+func ZoneSelector(zone string, zoneOf func(peer string) string) PeerSelector {
+	return PeerSelectorFunc(func(group, key string, peers []string) []string {
+		selected := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			if zoneOf(peer) == zone {
+				selected = append(selected, peer)
+			}
+		}
+		for _, peer := range peers {
+			if zoneOf(peer) != zone {
+				selected = append(selected, peer)
+			}
+		}
+		return selected
+	})
+}
+
+// RandomSelector selects n peers at random, every peer in random order when n is 0.
+func RandomSelector(n int) PeerSelector {
+	return PeerSelectorFunc(func(group, key string, peers []string) []string {
+		selected := slices.Clone(peers)
+		rand.Shuffle(len(selected), func(i, j int) {
+			selected[i], selected[j] = selected[j], selected[i]
+		})
+		if n > 0 {
+			selected = selected[:min(n, len(selected))]
+		}
+		return selected
+	})
+}
+
+// selectPeers returns the peers chosen by the PeerSelector for key of group.
+func (c *cache) selectPeers(group, key string) []string {
+	ring := c.hashRing()
+	peers := slices.DeleteFunc(slices.Clone(ring.peers), func(peer string) bool {
+		return peer == ring.self
+	})
+	return c.peerSelector.Select(group, key, peers)
+}
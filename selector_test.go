@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerSelectors(t *testing.T) {
+	peers := []string{"a-1:4567", "b-1:4567", "a-2:4567", "b-2:4567"}
+
+	assert.Equal(t, peers, BroadcastSelector().Select("testGroup", "testKey", peers))
+
+	zoneOf := func(peer string) string { return peer[:1] }
+	assert.Equal(t, []string{"b-1:4567", "b-2:4567", "a-1:4567", "a-2:4567"},
+		ZoneSelector("b", zoneOf).Select("testGroup", "testKey", peers))
+
+	random := RandomSelector(2).Select("testGroup", "testKey", peers)
+	assert.Len(t, random, 2)
+	assert.Subset(t, peers, random)
+	assert.ElementsMatch(t, peers, RandomSelector(0).Select("testGroup", "testKey", peers))
+
+	// the hash ring selector agrees with the ring of the cluster, which includes this node
+	ring := newHashRing(peers, "self:4567", nil)
+	selector := HashRingSelector(2)
+	for i := range 100 {
+		key := fmt.Sprintf("key%d", i)
+		order := slices.DeleteFunc(ring.order("testGroup", key), func(node string) bool { return node == "self:4567" })
+		assert.Equal(t, order[:2], selector.Select("testGroup", key, peers))
+	}
+}
+
+func TestCache_PeerSelector(t *testing.T) {
+	var selected []string
+	c := &cache{
+		addr:          "self:4567",
+		peerAddresses: []string{"a:4567", "b:4567", "c:4567"},
+		peerSelector: PeerSelectorFunc(func(group, key string, peers []string) []string {
+			selected = peers
+			return slices.DeleteFunc(slices.Clone(peers), func(peer string) bool {
+				return strings.HasPrefix(peer, "b")
+			})
+		}),
+	}
+	assert.Equal(t, []string{"a:4567", "c:4567"}, c.deleteTargets("testGroup", "testKey"))
+	assert.Equal(t, []string{"a:4567", "b:4567", "c:4567"}, selected)
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"os"
+	"os/signal"
+)
+
+// watchFlushSignal flushes every group of this node when sig is received.
+// Peers are not flushed; send the signal to each node.
+func (c *cache) watchFlushSignal(sig os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+
+	c.spawn(func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				removed := c.flushAll()
+				c.logger.Info("cache flushed on signal", "signal", sig.String(), "removed", removed)
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// flushAll removes every entry of every group locally and returns the number of removed entries.
+func (c *cache) flushAll() int {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	removed := 0
+	for _, group := range c.group {
+		removed += group.flush()
+	}
+	return removed
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package cache
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_FlushSignal(t *testing.T) {
+	c := NewCache(&Config{FlushSignal: syscall.SIGUSR1})
+	defer c.Close()
+	g := c.NewGroup("testGroup", nil)
+	g.Set("testKey", "testValue")
+
+	p, _ := os.FindProcess(os.Getpid())
+	assert.NoError(t, p.Signal(syscall.SIGUSR1))
+	assert.Eventually(t, func() bool {
+		_, ok := g.GetIfPresent("testKey")
+		return !ok
+	}, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// snapshotChunkSize is the number of entries copied under one read lock by
+// SnapshotTo and stored under one write lock by RestoreFrom.
+const snapshotChunkSize = 1000
+
+// snapshotEntry is one line of the SnapshotTo format.
+type snapshotEntry struct {
+	Key        string    `json:"key"`
+	Value      any       `json:"value"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Generation uint64    `json:"generation"`
+	Priority   int       `json:"priority,omitempty"`
+	DependsOn  []string  `json:"depends_on,omitempty"`
+	// 복원 시 group 의 schema version 과 다르면 무시
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Value 가 MarshalBinary 의 결과 (base64)
+	Binary bool `json:"binary,omitempty"`
+}
+
+func (g *group) SnapshotTo(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return g.snapshot(func(e snapshotEntry) error {
+		return enc.Encode(e)
+	})
+}
+
+// snapshot calls fn with the unexpired entries, sorted by key.
+func (g *group) snapshot(fn func(e snapshotEntry) error) error {
+	g.mtx.RLock()
+	keys := make([]string, 0, len(g.data))
+	for key := range g.data {
+		keys = append(keys, key)
+	}
+	g.mtx.RUnlock()
+	slices.Sort(keys)
+
+	chunk := make([]snapshotEntry, 0, snapshotChunkSize)
+	for len(keys) != 0 {
+		batch := keys[:min(snapshotChunkSize, len(keys))]
+		keys = keys[len(batch):]
+		now := time.Now()
+		chunk = chunk[:0]
+		g.mtx.RLock()
+		for _, key := range batch {
+			// 그 사이 삭제되었거나 만료된 key 는 건너뛴다
+			d, ok := g.data[key]
+			if !ok || d.missing || now.After(d.ttlTime) {
+				continue
+			}
+			chunk = append(chunk, snapshotEntry{
+				Key:        key,
+				Value:      d.val,
+				ExpiresAt:  d.ttlTime,
+				Generation: d.generation,
+				Priority:   d.priority,
+				DependsOn:  d.dependsOn,
+				// RestoreFrom 에서 group 의 version 과 비교
+				SchemaVersion: d.schemaVersion,
+			})
+		}
+		g.mtx.RUnlock()
+
+		for _, e := range chunk {
+			dat, binary, err := g.marshalBinary(e.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", e.Key, err)
+			}
+			if binary {
+				e.Value, e.Binary = dat, true
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *group) RestoreFrom(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	chunk := make([]snapshotEntry, 0, snapshotChunkSize)
+	for {
+		var e snapshotEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk = append(chunk, e)
+		if len(chunk) == snapshotChunkSize {
+			if err := g.restore(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	return g.restore(chunk)
+}
+
+// restore stores the unexpired entries of a snapshot under one write lock.
+func (g *group) restore(chunk []snapshotEntry) error {
+	entries := make([]setEntry, 0, len(chunk))
+	for _, e := range chunk {
+		ttl := time.Until(e.ExpiresAt)
+		if ttl <= 0 || e.SchemaVersion != g.schemaVersion {
+			continue
+		}
+		entries = append(entries, setEntry{Key: e.Key, Value: e.Value, TTL: ttl, Generation: e.Generation, Priority: e.Priority, DependsOn: e.DependsOn, SchemaVersion: e.SchemaVersion, Binary: e.Binary})
+	}
+	if err := g.decodeBinaryEntries(entries); err != nil {
+		return err
+	}
+	g.setEntries(entries)
+	return nil
+}
+
+// snapshotFileEntry is one line of Config.SnapshotFile, an entry of any group.
+type snapshotFileEntry struct {
+	Group string `json:"group"`
+	snapshotEntry
+}
+
+// loadSnapshotFile reads Config.SnapshotFile, keeping the unexpired entries of
+// each group until the group is created. A missing or corrupt file is logged and
+// the cache starts empty.
+func (c *cache) loadSnapshotFile() {
+	f, err := os.Open(c.snapshotFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.logger.Info("snapshot file not found, starting empty", "path", c.snapshotFile)
+		return
+	}
+	if err != nil {
+		c.logger.Warn("failed to open snapshot file, starting empty", "path", c.snapshotFile, "err", err)
+		return
+	}
+	defer f.Close()
+
+	snapshot := make(map[string][]snapshotEntry)
+	now := time.Now()
+	var total int
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var e snapshotFileEntry
+		err := c.decodeSnapshotFileEntry(dec, &e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			c.logger.Warn("corrupt snapshot file, starting empty", "path", c.snapshotFile, "err", err)
+			return
+		}
+		if !now.Before(e.ExpiresAt) {
+			continue
+		}
+		snapshot[e.Group] = append(snapshot[e.Group], e.snapshotEntry)
+		total++
+	}
+	c.snapshot = snapshot
+	c.logger.Info("snapshot file loaded", "path", c.snapshotFile, "groups", len(snapshot), "entries", total)
+}
+
+// restoreSnapshot stores the entries of the snapshot file loaded for g, once.
+func (c *cache) restoreSnapshot(g *group) {
+	c.mtx.Lock()
+	entries := c.snapshot[g.name]
+	delete(c.snapshot, g.name)
+	c.mtx.Unlock()
+
+	for len(entries) != 0 {
+		chunk := entries[:min(snapshotChunkSize, len(entries))]
+		entries = entries[len(chunk):]
+		if err := g.restore(chunk); err != nil {
+			c.logger.Warn("failed to restore snapshot", "group", g.name, "err", err)
+			g.flush()
+			return
+		}
+	}
+}
+
+// writeSnapshotFile writes the unexpired entries of every group to
+// Config.SnapshotFile, replacing it only once the whole snapshot is written.
+func (c *cache) writeSnapshotFile() error {
+	f, err := os.CreateTemp(filepath.Dir(c.snapshotFile), filepath.Base(c.snapshotFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, g := range c.groups() {
+		err := g.snapshot(func(e snapshotEntry) error {
+			return c.encodeSnapshotFileEntry(enc, snapshotFileEntry{Group: g.name, snapshotEntry: e})
+		})
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("group %s: %w", g.name, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.snapshotFile)
+}
+
+// encodeSnapshotFileEntry writes e to the snapshot file, with an EncryptionKey
+// as the base64 string of the sealed JSON of e.
+func (c *cache) encodeSnapshotFileEntry(enc *json.Encoder, e snapshotFileEntry) error {
+	if c.encryption == nil {
+		return enc.Encode(e)
+	}
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.encryption.seal(dat)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(sealed)
+}
+
+// decodeSnapshotFileEntry reads the next entry written by encodeSnapshotFileEntry.
+func (c *cache) decodeSnapshotFileEntry(dec *json.Decoder, e *snapshotFileEntry) error {
+	if c.encryption == nil {
+		return dec.Decode(e)
+	}
+	var sealed []byte
+	if err := dec.Decode(&sealed); err != nil {
+		return err
+	}
+	dat, err := c.encryption.open(sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(dat, e)
+}
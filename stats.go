@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of a group's counters.
+type Stats struct {
+	// Get 호출 중 local 에서 찾은 수와 찾지 못한 수
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+
+	GetterCalls  int64 `json:"getter_calls"`
+	GetterErrors int64 `json:"getter_errors"`
+	Evictions    int64 `json:"evictions"`
+	// WithKeyRateLimit 로 getter 를 호출하지 않은 수
+	GetterThrottled int64 `json:"getter_throttled"`
+	// maintenance mode 로 getter 를 호출하지 않은 miss 수
+	MaintenanceMisses int64 `json:"maintenance_misses"`
+	// getter 실패 또는 circuit breaker 로 만료된 값을 반환한 수
+	StaleHits int64 `json:"stale_hits"`
+	// WithSlowGetterThreshold 보다 오래 걸린 getter 호출 수
+	SlowGetterCalls int64 `json:"slow_getter_calls"`
+	// WithGetterTimeout 을 넘긴 getter 호출 수
+	GetterTimeouts int64 `json:"getter_timeouts"`
+	// getter 호출 한 번이 set 한 가장 많은 key 수와 WithMaxFillKeys 로 버린 key 수.
+	// MaxFillKeys 는 ResetStats 로 초기화
+	MaxFillKeys     int64 `json:"max_fill_keys"`
+	FillKeysDropped int64 `json:"fill_keys_dropped"`
+
+	Entries  int `json:"entries"`
+	InFlight int `json:"in_flight"`
+	// getter 호출 중인 key 중 최대 maxStatsInFlightKeys 개, 정렬됨
+	InFlightKeys []string     `json:"in_flight_keys,omitempty"`
+	Breaker      BreakerState `json:"breaker"`
+	// WithSizer 로 측정한 entry 의 byte 합계
+	Bytes int64 `json:"bytes"`
+	// set 이 write lock 을 가장 오래 잡은 시간, map 이 커지는 동안 길어진다.
+	// ResetStats 로 초기화
+	MaxWriteLock time.Duration `json:"max_write_lock"`
+	// evict 또는 만료된 entry 의 수명 분포
+	Lifetimes Lifetimes `json:"lifetimes"`
+
+	// Config.PeerFetch 로 miss 를 peer 에게 요청한 수, 그 중 peer 가 값을 반환한 수와
+	// 요청 시간 분포. hit 이 드물다면 peer fetch 는 miss 의 지연만 늘린다
+	PeerFetches      int64            `json:"peer_fetches"`
+	PeerFetchHits    int64            `json:"peer_fetch_hits"`
+	PeerFetchLatency PeerFetchLatency `json:"peer_fetch_latency"`
+
+	// 마지막 background cleanup 한 번의 작업량.
+	// 매번 많이 삭제된다면 CacheCleanupIntervalSec 이 너무 긴 것
+	LastCleanupScanned  int           `json:"last_cleanup_scanned"`
+	LastCleanupRemoved  int           `json:"last_cleanup_removed"`
+	LastCleanupDuration time.Duration `json:"last_cleanup_duration"`
+}
+
+// maxStatsInFlightKeys bounds Stats.InFlightKeys, so that a stuck backend does
+// not blow up the stats response.
+const maxStatsInFlightKeys = 100
+
+// lifetimeBuckets are the upper bounds of the Lifetimes buckets, as a fraction of
+// the ttl of the entry. The last bucket counts the entries that lived longer.
+var lifetimeBuckets = [...]float64{0.1, 0.25, 0.5, 1, 2}
+
+// Lifetimes counts the entries removed from a group by how long they lived
+// before, relative to their ttl: at most 10%, 25%, 50%, 100% and 200% of it, and
+// longer. Entries evicted long before their ttl suggest that the group is too
+// small; reads slide the ttl, so expired entries may live longer than it.
+type Lifetimes struct {
+	Evicted [len(lifetimeBuckets) + 1]int64 `json:"evicted"`
+	Expired [len(lifetimeBuckets) + 1]int64 `json:"expired"`
+}
+
+// lifetimeBucket returns the Lifetimes bucket of an entry that lived for age.
+func lifetimeBucket(age, ttl time.Duration) int {
+	for i, bound := range lifetimeBuckets {
+		if ttl > 0 && float64(age) <= bound*float64(ttl) {
+			return i
+		}
+	}
+	return len(lifetimeBuckets)
+}
+
+// peerFetchBuckets are the upper bounds of the PeerFetchLatency buckets. The last
+// bucket counts the slower fetches.
+var peerFetchBuckets = [...]time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, time.Second,
+}
+
+// PeerFetchLatency counts the peer fetches of a group, hits and misses alike, by
+// how long they took: at most 1ms, 5ms, 10ms, 25ms, 50ms, 100ms, 250ms and 1s,
+// and longer. Misses waiting for every peer to answer end up in the last
+// buckets; tune Config.PeerFetchStaggerMs and the peer request timeout from it.
+type PeerFetchLatency struct {
+	Buckets [len(peerFetchBuckets) + 1]int64 `json:"buckets"`
+	// 모든 요청 시간의 합계, 평균은 Total / Stats.PeerFetches
+	Total time.Duration `json:"total"`
+}
+
+type groupStats struct {
+	hits         atomic.Int64
+	misses       atomic.Int64
+	getterCalls  atomic.Int64
+	getterErrors atomic.Int64
+	evictions    atomic.Int64
+	// getter rate limit 에 걸린 수
+	getterThrottled   atomic.Int64
+	maintenanceMisses atomic.Int64
+	staleHits         atomic.Int64
+	slowGetterCalls   atomic.Int64
+	getterTimeouts    atomic.Int64
+
+	maxWriteLock    atomic.Int64
+	maxFillKeys     atomic.Int64
+	fillKeysDropped atomic.Int64
+
+	lastCleanupScanned  atomic.Int64
+	lastCleanupRemoved  atomic.Int64
+	lastCleanupDuration atomic.Int64
+
+	peerFetches      atomic.Int64
+	peerFetchHits    atomic.Int64
+	peerFetchTotal   atomic.Int64
+	peerFetchLatency [len(peerFetchBuckets) + 1]atomic.Int64
+
+	evictedLifetimes [len(lifetimeBuckets) + 1]atomic.Int64
+	expiredLifetimes [len(lifetimeBuckets) + 1]atomic.Int64
+}
+
+func (g *group) Stats() Stats {
+	return g.snapshotStats(false)
+}
+
+func (g *group) ResetStats() Stats {
+	return g.snapshotStats(true)
+}
+
+// snapshotStats returns the stats of the group, and zeroes its counters when
+// reset is set. Each counter is swapped atomically, so that no increment is lost
+// or counted twice, but the counters are not swapped together: an operation
+// running meanwhile may be counted in part before and in part after the reset.
+func (g *group) snapshotStats(reset bool) Stats {
+	load := (*atomic.Int64).Load
+	if reset {
+		load = func(c *atomic.Int64) int64 { return c.Swap(0) }
+	}
+
+	g.mtx.RLock()
+	stats := Stats{
+		Entries:  len(g.data),
+		InFlight: len(g.inflight),
+		Bytes:    g.bytes,
+	}
+	for key := range g.inflight {
+		if len(stats.InFlightKeys) == maxStatsInFlightKeys {
+			break
+		}
+		stats.InFlightKeys = append(stats.InFlightKeys, key)
+	}
+	g.mtx.RUnlock()
+	slices.Sort(stats.InFlightKeys)
+
+	stats.Hits = load(&g.stats.hits)
+	stats.Misses = load(&g.stats.misses)
+	stats.GetterCalls = load(&g.stats.getterCalls)
+	stats.GetterErrors = load(&g.stats.getterErrors)
+	stats.GetterThrottled = load(&g.stats.getterThrottled)
+	stats.MaintenanceMisses = load(&g.stats.maintenanceMisses)
+	stats.StaleHits = load(&g.stats.staleHits)
+	stats.SlowGetterCalls = load(&g.stats.slowGetterCalls)
+	stats.GetterTimeouts = load(&g.stats.getterTimeouts)
+	stats.Evictions = load(&g.stats.evictions)
+	stats.MaxWriteLock = time.Duration(load(&g.stats.maxWriteLock))
+	stats.MaxFillKeys = load(&g.stats.maxFillKeys)
+	stats.FillKeysDropped = load(&g.stats.fillKeysDropped)
+	stats.LastCleanupScanned = int(g.stats.lastCleanupScanned.Load())
+	stats.LastCleanupRemoved = int(g.stats.lastCleanupRemoved.Load())
+	stats.LastCleanupDuration = time.Duration(g.stats.lastCleanupDuration.Load())
+	stats.PeerFetches = load(&g.stats.peerFetches)
+	stats.PeerFetchHits = load(&g.stats.peerFetchHits)
+	stats.PeerFetchLatency.Total = time.Duration(load(&g.stats.peerFetchTotal))
+	for i := range stats.PeerFetchLatency.Buckets {
+		stats.PeerFetchLatency.Buckets[i] = load(&g.stats.peerFetchLatency[i])
+	}
+	for i := range stats.Lifetimes.Evicted {
+		stats.Lifetimes.Evicted[i] = load(&g.stats.evictedLifetimes[i])
+		stats.Lifetimes.Expired[i] = load(&g.stats.expiredLifetimes[i])
+	}
+	if g.breaker != nil {
+		stats.Breaker = g.breaker.State()
+	}
+	return stats
+}
+
+// recordWriteLock keeps the longest hold of the write lock by a set.
+func (s *groupStats) recordWriteLock(d time.Duration) {
+	for {
+		cur := s.maxWriteLock.Load()
+		if int64(d) <= cur || s.maxWriteLock.CompareAndSwap(cur, int64(d)) {
+			return
+		}
+	}
+}
+
+// recordFillKeys keeps the most keys set by one getter call.
+func (s *groupStats) recordFillKeys(n int) {
+	for {
+		cur := s.maxFillKeys.Load()
+		if int64(n) <= cur || s.maxFillKeys.CompareAndSwap(cur, int64(n)) {
+			return
+		}
+	}
+}
+
+// recordPeerFetch counts a peer fetch that took d and found the value when hit is set.
+func (s *groupStats) recordPeerFetch(d time.Duration, hit bool) {
+	s.peerFetches.Add(1)
+	if hit {
+		s.peerFetchHits.Add(1)
+	}
+	s.peerFetchTotal.Add(int64(d))
+	i, _ := slices.BinarySearch(peerFetchBuckets[:], d)
+	s.peerFetchLatency[i].Add(1)
+}
@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store is a shared backing store consulted between the local map and the getter.
+// On a local miss the group looks up the store, and only falls back to the getter
+// when the store misses too. Values loaded by the getter are written through to the store.
+type Store interface {
+	// Get returns ErrNotFound when the key does not exist in the store.
+	Get(ctx context.Context, group, key string) (any, error)
+	Set(ctx context.Context, group, key string, val any, ttl time.Duration) error
+	Del(ctx context.Context, group, key string) error
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Stream is a value that GET /{groupName}/{key} copies to the response from the
+// reader returned by Open, instead of encoding the whole value in memory first,
+// e.g. a large rendered page kept in a file. Open is called for each request,
+// and the reader is closed when it implements io.Closer. A Stream is served as
+// application/octet-stream whatever the Accept header; peers cannot fetch it
+// and PropagateSets cannot send it, as it has no encoding.
+type Stream interface {
+	Open() (io.Reader, error)
+}
+
+// StreamFunc adapts a function to Stream.
+type StreamFunc func() (io.Reader, error)
+
+func (f StreamFunc) Open() (io.Reader, error) {
+	return f()
+}
+
+// writeStream copies s to the response.
+func (c *cache) writeStream(w http.ResponseWriter, s Stream) {
+	r, err := s.Open()
+	if err != nil {
+		c.writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("stream open failed. err=%v", err))
+		return
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	// 응답을 시작한 뒤에는 상태 코드를 바꿀 수 없으므로 기록만 한다
+	if _, err := io.Copy(w, r); err != nil {
+		c.reportError(fmt.Errorf("stream response: %w", err))
+	}
+}
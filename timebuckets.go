@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeBuckets stores the values of a base key in one key per period, e.g. a
+// counter per minute, and reads back the buckets of a sliding window. Buckets
+// older than the window are not returned any more and fall out of the group
+// when their ttl expires.
+type TimeBuckets struct {
+	group  Group
+	base   string
+	period time.Duration
+	window int
+}
+
+// Bucket is the value of one period of TimeBuckets.
+type Bucket struct {
+	Start time.Time
+	Value any
+}
+
+// NewTimeBuckets returns the buckets of base in g, of period each, keeping the
+// last window buckets including the current one.
+func NewTimeBuckets(g Group, base string, period time.Duration, window int) *TimeBuckets {
+	return &TimeBuckets{group: g, base: base, period: period, window: max(window, 1)}
+}
+
+// start returns the start of the bucket t falls in.
+func (b *TimeBuckets) start(t time.Time) time.Time {
+	return t.Truncate(b.period)
+}
+
+// Key returns the key of the bucket t falls in, the base key followed by the
+// start of the bucket in unix milliseconds, e.g. "requests@1700000040000".
+func (b *TimeBuckets) Key(t time.Time) string {
+	return fmt.Sprintf("%s@%d", b.base, b.start(t).UnixMilli())
+}
+
+// Set stores val in the bucket of t until the bucket leaves the window.
+func (b *TimeBuckets) Set(t time.Time, val any) {
+	ttl := b.start(t).Add(time.Duration(b.window) * b.period).Sub(time.Now())
+	if ttl <= 0 {
+		return
+	}
+	b.group.SetWithTTL(b.Key(t), val, ttl)
+}
+
+// Update changes the bucket of t atomically, see Group.Update, e.g. to increment
+// a counter. The bucket gets the group default ttl, which should cover the window.
+func (b *TimeBuckets) Update(t time.Time, fn func(old any, existed bool) (any, bool)) error {
+	return b.group.Update(b.Key(t), fn)
+}
+
+// Recent returns the cached buckets of the window ending with the bucket of now,
+// newest first. Buckets without a value are skipped.
+func (b *TimeBuckets) Recent(now time.Time) []Bucket {
+	var buckets []Bucket
+	start := b.start(now)
+	for i := range b.window {
+		t := start.Add(-time.Duration(i) * b.period)
+		if val, ok := b.group.GetIfPresent(b.Key(t)); ok {
+			buckets = append(buckets, Bucket{Start: t, Value: val})
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,77 @@
+package cache
+
+import "time"
+
+// WithTombstones makes a deleted key remember its deletion for ttl, during which
+// values that were read before the delete cannot bring the key back: a getter
+// call that started before the delete, a value pushed or fetched from a peer
+// that was set before it. A local Set, or any other write that happens after
+// the delete, stores the key as usual. Values from peers are dated by their
+// generation, so this relies on the clocks of the nodes being roughly in sync.
+// ttl should exceed the longest getter call and peer round trip.
+func WithTombstones(ttl time.Duration) GroupOption {
+	return func(g *group) {
+		g.tombstoneTTL = ttl
+		g.tombstones = make(map[string]time.Time)
+	}
+}
+
+// tombstoneLocked records that key was deleted at now. Must be called with g.mtx held.
+func (g *group) tombstoneLocked(key string, now time.Time) {
+	if g.tombstoneTTL > 0 {
+		g.tombstones[key] = now
+	}
+}
+
+// buriedLocked reports whether e was read before key was deleted and must not be
+// stored. A local write clears the tombstone. Must be called with g.mtx held.
+func (g *group) buriedLocked(e setEntry, now time.Time) bool {
+	deleted, ok := g.tombstones[e.Key]
+	if !ok {
+		return false
+	}
+	if now.Sub(deleted) > g.tombstoneTTL {
+		delete(g.tombstones, e.Key)
+		return false
+	}
+
+	var readAt time.Time
+	switch {
+	case !e.started.IsZero():
+		readAt = e.started
+	case e.Generation != 0:
+		// generation 은 peer 에서 값이 설정된 시각
+		readAt = time.Unix(0, int64(e.Generation))
+	default:
+		delete(g.tombstones, e.Key)
+		return false
+	}
+	return readAt.Before(deleted)
+}
+
+// WithCancelFillsOnDelete makes a delete final against the getter calls in
+// progress for the key: their context is canceled, and the value they return is
+// not stored, whatever the time the getter takes. Get still returns that value to
+// its caller. Unlike WithTombstones it does not cover values received from peers.
+func WithCancelFillsOnDelete() GroupOption {
+	return func(g *group) {
+		g.fills = make(map[string][]*fillSink)
+	}
+}
+
+// discardFillsLocked cancels the fills of key in progress. Must be called with g.mtx held.
+func (g *group) discardFillsLocked(key string) {
+	for _, sink := range g.fills[key] {
+		sink.discarded = true
+		sink.cancel()
+	}
+}
+
+// purgeTombstones removes the expired tombstones. Must be called with g.mtx held.
+func (g *group) purgeTombstones(now time.Time) {
+	for key, deleted := range g.tombstones {
+		if now.Sub(deleted) > g.tombstoneTTL {
+			delete(g.tombstones, key)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package cache
+
+import "net/http"
+
+// PeerTransport carries the requests between the nodes of a cluster in place of
+// the network, e.g. to run several caches in one process (see package cachetest).
+// When Config.PeerTransport is set, the cache does not listen on Addr; it
+// registers its handler with the transport under Addr instead, and sends its
+// peer requests with RoundTrip.
+type PeerTransport interface {
+	// RoundTrip sends a peer request to the node registered at req.URL.Host.
+	http.RoundTripper
+	// Register makes handler reachable at addr and returns a function that
+	// unregisters it, called by Close.
+	Register(addr string, handler http.Handler) (unregister func())
+}
@@ -0,0 +1,62 @@
+package cache
+
+import "time"
+
+// GroupView is a read-only point-in-time copy of the live entries of a group,
+// returned by Group.Snapshot. It can be read for as long as needed, from any
+// goroutine, without locking the group, and later changes of the group do not
+// show in it.
+//
+// Taking a view copies an Entry and a map slot per live entry, about 100 bytes
+// each plus the key, so a view of a group of millions of entries takes hundreds
+// of megabytes until it is dropped; prefer Entries or SnapshotTo for a single
+// pass, and keep views of large groups short-lived. Values are shared with the
+// group, not copied: maps or slices stored in the group must not be modified
+// through the view.
+type GroupView struct {
+	at      time.Time
+	entries []Entry
+	index   map[string]int
+	// group 의 WithKeyNormalizer, nil 이면 key 를 그대로 사용
+	normalize func(key string) string
+}
+
+// At returns the time the view was taken. The TTL of its entries is relative to it.
+func (v GroupView) At() time.Time {
+	return v.at
+}
+
+func (v GroupView) Len() int {
+	return len(v.entries)
+}
+
+// Get returns the entry of key at the time of the view. Like Group.Get, key is
+// normalized with the WithKeyNormalizer of the group.
+func (v GroupView) Get(key string) (Entry, bool) {
+	if v.normalize != nil {
+		key = v.normalize(key)
+	}
+	i, ok := v.index[key]
+	if !ok {
+		return Entry{}, false
+	}
+	return v.entries[i], true
+}
+
+// Range calls fn for the entries in key order until fn returns false.
+func (v GroupView) Range(fn func(e Entry) bool) {
+	for _, e := range v.entries {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+func (g *group) Snapshot() GroupView {
+	v := GroupView{at: time.Now(), entries: g.Entries(), normalize: g.keyNormalizer}
+	v.index = make(map[string]int, len(v.entries))
+	for i, e := range v.entries {
+		v.index[e.Key] = i
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultWebhookQueueSize = 1024
+	defaultWebhookRetries   = 3
+	webhookRetryBackoff     = 100 * time.Millisecond
+)
+
+// webhookEvent is the JSON payload posted to Config.WebhookURL.
+type webhookEvent struct {
+	Group     string    `json:"group"`
+	Key       string    `json:"key"`
+	Op        string    `json:"op"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// startWebhook posts every change event to url from a background goroutine.
+// Events are queued without blocking and dropped when the queue is full.
+func (c *cache) startWebhook(url string, queueSize int) {
+	if queueSize <= 0 {
+		queueSize = defaultWebhookQueueSize
+	}
+	events := make(chan webhookEvent, queueSize)
+	c.OnChange(func(group, key string, op Op, val any) {
+		select {
+		case events <- webhookEvent{Group: group, Key: key, Op: op.String(), Timestamp: time.Now()}:
+		default:
+		}
+	})
+
+	// peer 와 달리 외부 서비스이므로 PeerTransport 를 사용하지 않는다
+	client := &http.Client{Timeout: defaultPeerRequestTimeout}
+	c.spawn(func() {
+		for {
+			select {
+			case event := <-events:
+				if err := c.postWebhook(client, url, event); err != nil {
+					c.reportError(err)
+				}
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+func (c *cache) postWebhook(client *http.Client, url string, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("responded %s", resp.Status)
+		}
+		if attempt == defaultWebhookRetries {
+			return fmt.Errorf("webhook %s: %w", url, err)
+		}
+
+		select {
+		case <-time.After(webhookRetryBackoff << attempt):
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+}